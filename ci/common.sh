@@ -1149,7 +1149,9 @@ oper_install_yaml() {
 	local namespace="$1"
 	shift
 
-	kubectl apply $KUBECTL_APPLY_ARGS -f $AMB_OPER_CRDS
+	for f in $AMB_OPER_CRDS; do
+		kubectl apply $KUBECTL_APPLY_ARGS -f $f
+	done
 	kubectl apply -n "$namespace" $KUBECTL_APPLY_ARGS -f "$AMB_OPER_MANIF"
 
 	cat_setting_image "$AMB_OPER_MANIF" |
@@ -48,7 +48,7 @@ AMB_OPER_MANIF="${TOP_DIR}/build/artifacts/ambassador-operator.yaml"
 AMB_OPER_MANIF_DEF_IMAGE="ambassador-operator:dev"
 
 # the CRDs
-AMB_OPER_CRDS="$TOP_DIR/deploy/crds/getambassador.io_ambassadorinstallations_crd.yaml"
+AMB_OPER_CRDS="$TOP_DIR/deploy/crds/getambassador.io_ambassadorinstallations_crd.yaml $TOP_DIR/deploy/crds/getambassador.io_clusterambassadorinstallations_crd.yaml"
 
 # the name of the ambassador operator deployment
 AMB_OPER_DEPLOY="ambassador-operator"
@@ -29,8 +29,11 @@ input_crds_dir=$TOP_DIR/deploy/crds
 artifact_crds_manif="$ARTIFACTS_DIR/ambassador-operator-crds.yaml"
 artifact_oper_manif="$ARTIFACTS_DIR/ambassador-operator.yaml"
 
-# output helm manifests
+# output helm manifests: the AmbassadorInstallations CRD is rendered by a template
+# in the chart (as the `v3` version is served only with the conversion webhook)
 helm_crds_manif="$helm_dir/crds/ambassador-installation.yaml"
+helm_templated_crd="$helm_dir/files/ambassadorinstallations-crd.yaml"
+templated_crd="$input_crds_dir/getambassador.io_ambassadorinstallations_crd.yaml"
 
 # helm parameters
 release_name="ambassador"
@@ -70,18 +73,26 @@ info "Copying CRDs..."
 mkdir -p "$(dirname $artifact_crds_manif)"
 cat $input_crds_dir/*_crd.yaml >$artifact_crds_manif || abort "could not create $artifact_crds_manif"
 
-# we must copy the CRD to the Helm chart
-info "Updating CRD in Helm chart in $helm_crds_manif"
+# we must copy the CRDs to the Helm chart
+info "Updating CRDs in Helm chart in $helm_crds_manif"
 mkdir -p "$(dirname $helm_crds_manif)"
-cp -f $artifact_crds_manif $helm_crds_manif || abort "could not create $helm_crds_manif"
+rm -f $helm_templated_crd
+for crd in $input_crds_dir/*_crd.yaml; do
+	[ "$crd" = "$templated_crd" ] || cat $crd
+done >$helm_crds_manif || abort "could not create $helm_crds_manif"
 
 # then we use the Helm chart for generating the standalone manifest, using
-# the default values.yaml
+# the default values.yaml (the CRDs are in a different manifest, so this is
+# done before copying the templated CRD to the chart)
 info "Rendering Helm chart, using image '${AMB_OPER_IMAGE_FULL}'..."
 mkdir -p "$(dirname $tmp_manif)"
 helm template $release_name \
 	$args --set deploymentTool=amb-oper-manifest --skip-crds $helm_dir >$tmp_manif || abort "could not create $tmp_manif"
 
+info "Updating templated CRD in Helm chart in $helm_templated_crd"
+mkdir -p "$(dirname $helm_templated_crd)"
+cp -f $templated_crd $helm_templated_crd || abort "could not create $helm_templated_crd"
+
 info "Creating generic manifest, with namespace '${namespace}'..."
 rm -f $artifact_oper_manif
 mkdir -p "$(dirname $artifact_oper_manif)"
@@ -0,0 +1,154 @@
+// kubectl-ambassador is a kubectl plugin (`kubectl ambassador`) for inspecting and
+// operating the AmbassadorInstallations managed by the Ambassador Operator.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	"github.com/datawire/ambassador-operator/pkg/apis"
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/controller/ambassadorinstallation"
+	"github.com/datawire/ambassador-operator/version"
+)
+
+// options are the global options for all the commands
+type options struct {
+	configFlags *genericclioptions.ConfigFlags
+	cluster     bool
+}
+
+func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func newRootCommand() *cobra.Command {
+	o := &options{configFlags: genericclioptions.NewConfigFlags(true)}
+
+	root := &cobra.Command{
+		Use:          "kubectl-ambassador",
+		Short:        "Inspect and operate the AmbassadorInstallations managed by the Ambassador Operator",
+		Version:      version.Version,
+		SilenceUsage: true,
+	}
+	o.configFlags.AddFlags(root.PersistentFlags())
+	root.PersistentFlags().BoolVar(&o.cluster, "cluster", false,
+		"operate on a ClusterAmbassadorInstallation instead of an AmbassadorInstallation")
+
+	root.AddCommand(
+		newStatusCommand(o),
+		newVersionsCommand(o),
+		newApproveCommand(o),
+		newPauseCommand(o),
+		newResumeCommand(o),
+		newReconcileCommand(o),
+		newForceCommand(o),
+		newRestoreCommand(o),
+		newValidateCommand(),
+	)
+	return root
+}
+
+// gvk returns the kind of installation the commands operate on
+func (o *options) gvk() schema.GroupVersionKind {
+	if o.cluster {
+		return ambassadorinstallation.ClusterGVK
+	}
+	return ambassadorinstallation.DefaultGVK
+}
+
+// client returns a client for the cluster
+func (o *options) client() (client.Client, error) {
+	cfg, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	scheme := runtime.NewScheme()
+	if err := apis.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	// for reading the Secrets/ConfigMaps with the snapshots
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	return client.New(cfg, client.Options{Scheme: scheme})
+}
+
+// key returns the namespace/name for an installation
+func (o *options) key(name string) (types.NamespacedName, error) {
+	if o.cluster {
+		return types.NamespacedName{Name: name}, nil
+	}
+	namespace, _, err := o.configFlags.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return types.NamespacedName{}, err
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}, nil
+}
+
+// get returns an installation from the cluster
+func (o *options) get(ctx context.Context, c client.Client, name string) (*unstructured.Unstructured, error) {
+	key, err := o.key(name)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(o.gvk())
+	if err := c.Get(ctx, key, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// patch gets an installation, modifies it with `modify` and patches it in the cluster
+func (o *options) patch(name string, modify func(*unstructured.Unstructured) (string, error)) error {
+	ctx := context.Background()
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	u, err := o.get(ctx, c, name)
+	if err != nil {
+		return err
+	}
+
+	orig := u.DeepCopy()
+	message, err := modify(u)
+	if err != nil {
+		return err
+	}
+	if err := c.Patch(ctx, u, client.MergeFrom(orig)); err != nil {
+		return err
+	}
+	fmt.Printf("%s %q: %s\n", u.GetKind(), u.GetName(), message)
+	return nil
+}
+
+// toInstallation returns the typed installation for an unstructured one
+func toInstallation(u *unstructured.Unstructured) (ambassador.Installation, error) {
+	var installation ambassador.Installation
+	if u.GetKind() == ambassadorinstallation.ClusterGVK.Kind {
+		installation = &ambassador.ClusterAmbassadorInstallation{}
+	} else {
+		installation = &ambassador.AmbassadorInstallation{}
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), installation); err != nil {
+		return nil, err
+	}
+	return installation, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/datawire/ambassador-operator/pkg/controller/ambassadorinstallation"
+)
+
+func newApproveCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "approve NAME [CHART_VERSION]",
+		Short: "Approve an upgrade (by default, the next upgrade planned), ignoring the update window",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version := ""
+			if len(args) > 1 {
+				version = args[1]
+			}
+			return o.patch(args[0], func(u *unstructured.Unstructured) (string, error) {
+				return approveUpgrade(u, version)
+			})
+		},
+	}
+}
+
+func newPauseCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "pause NAME",
+		Short: "Pause the reconciliation of an installation (with spec.paused)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.patch(args[0], pauseReconciliation)
+		},
+	}
+}
+
+func newResumeCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "resume NAME",
+		Short: "Resume the reconciliation of an installation",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.patch(args[0], resumeReconciliation)
+		},
+	}
+}
+
+func newReconcileCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "reconcile NAME",
+		Short: "Request an upgrade check now, ignoring the update interval (but not the update window)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.patch(args[0], func(u *unstructured.Unstructured) (string, error) {
+				return requestReconciliation(u, time.Now())
+			})
+		},
+	}
+}
+
+func newForceCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "force NAME",
+		Short: "Force an upgrade check now, ignoring the update window",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.patch(args[0], func(u *unstructured.Unstructured) (string, error) {
+				return forceUpgrade(u, time.Now())
+			})
+		},
+	}
+}
+
+// approveUpgrade approves the upgrade to a chart version. When no version is provided,
+// the next upgrade planned by the operator is approved.
+func approveUpgrade(u *unstructured.Unstructured, version string) (string, error) {
+	if len(version) == 0 {
+		version, _, _ = unstructured.NestedString(u.Object, "status", "nextUpgrade", "version")
+		if len(version) == 0 {
+			return "", errors.New("there is no upgrade planned: a chart version must be provided")
+		}
+	}
+	setAnnotation(u, ambassadorinstallation.ApprovedVersionAnnotation, version)
+	return fmt.Sprintf("upgrade to chart %s approved", version), nil
+}
+
+// pauseReconciliation pauses the reconciliation of an installation with `spec.paused`.
+// The operator does not consider `spec.paused` a change in the spec, so pausing (or resuming)
+// does not trigger an upgrade.
+func pauseReconciliation(u *unstructured.Unstructured) (string, error) {
+	if paused, _, _ := unstructured.NestedBool(u.Object, "spec", "paused"); paused {
+		return "", errors.New("the reconciliation is already paused")
+	}
+	if err := unstructured.SetNestedField(u.Object, true, "spec", "paused"); err != nil {
+		return "", err
+	}
+	return "reconciliation paused", nil
+}
+
+// resumeReconciliation resumes the reconciliation of an installation paused with
+// `spec.paused` or with the PausedAnnotation
+func resumeReconciliation(u *unstructured.Unstructured) (string, error) {
+	paused, _, _ := unstructured.NestedBool(u.Object, "spec", "paused")
+	annotations := u.GetAnnotations()
+	_, annotated := annotations[ambassadorinstallation.PausedAnnotation]
+	if !paused && !annotated {
+		return "", errors.New("the reconciliation is not paused")
+	}
+	unstructured.RemoveNestedField(u.Object, "spec", "paused")
+	if annotated {
+		delete(annotations, ambassadorinstallation.PausedAnnotation)
+		u.SetAnnotations(annotations)
+	}
+	return "reconciliation resumed", nil
+}
+
+// requestReconciliation requests an upgrade check in the next reconciliation, ignoring the update interval
+func requestReconciliation(u *unstructured.Unstructured, now time.Time) (string, error) {
+	setAnnotation(u, ambassadorinstallation.RequestedAtAnnotation, now.UTC().Format(time.RFC3339Nano))
+	return "reconciliation requested", nil
+}
+
+// forceUpgrade requests an upgrade check in the next reconciliation, ignoring the update window
+func forceUpgrade(u *unstructured.Unstructured, now time.Time) (string, error) {
+	setAnnotation(u, ambassadorinstallation.ForceUpgradeAnnotation, now.UTC().Format(time.RFC3339Nano))
+	return "upgrade forced", nil
+}
+
+func setAnnotation(u *unstructured.Unstructured, key, value string) {
+	annotations := u.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[key] = value
+	u.SetAnnotations(annotations)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/datawire/ambassador-operator/pkg/controller/ambassadorinstallation"
+)
+
+func newTestInstallation(spec map[string]interface{}, status map[string]interface{}) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec":   spec,
+		"status": status,
+	}}
+	u.SetGroupVersionKind(ambassadorinstallation.DefaultGVK)
+	u.SetName("ambassador")
+	u.SetNamespace("ambassador")
+	return u
+}
+
+func TestPauseResume(t *testing.T) {
+	u := newTestInstallation(map[string]interface{}{"updateWindow": "0 1 * * SUN"}, nil)
+
+	if _, err := pauseReconciliation(u); err != nil {
+		t.Fatalf("could not pause: %s", err)
+	}
+	if paused, _, _ := unstructured.NestedBool(u.Object, "spec", "paused"); !paused {
+		t.Errorf("spec.paused was not set")
+	}
+	if w, _, _ := unstructured.NestedString(u.Object, "spec", "updateWindow"); w != "0 1 * * SUN" {
+		t.Errorf("the update window was modified: %q", w)
+	}
+	if _, err := pauseReconciliation(u); err == nil {
+		t.Errorf("pausing twice should fail")
+	}
+
+	if _, err := resumeReconciliation(u); err != nil {
+		t.Fatalf("could not resume: %s", err)
+	}
+	if _, found, _ := unstructured.NestedFieldNoCopy(u.Object, "spec", "paused"); found {
+		t.Errorf("spec.paused was not removed")
+	}
+	if _, err := resumeReconciliation(u); err == nil {
+		t.Errorf("resuming without pausing should fail")
+	}
+
+	// installations paused with the annotation can be resumed too
+	u.SetAnnotations(map[string]string{ambassadorinstallation.PausedAnnotation: "true"})
+	if _, err := resumeReconciliation(u); err != nil {
+		t.Fatalf("could not resume: %s", err)
+	}
+	if _, ok := u.GetAnnotations()[ambassadorinstallation.PausedAnnotation]; ok {
+		t.Errorf("the pause annotation was not removed")
+	}
+}
+
+func TestApproveForce(t *testing.T) {
+	u := newTestInstallation(map[string]interface{}{}, map[string]interface{}{})
+	if _, err := approveUpgrade(u, ""); err == nil {
+		t.Errorf("approving without a next upgrade should fail")
+	}
+
+	u = newTestInstallation(map[string]interface{}{}, map[string]interface{}{
+		"nextUpgrade": map[string]interface{}{"version": "6.5.0", "appVersion": "1.5.1"},
+	})
+	if _, err := approveUpgrade(u, ""); err != nil {
+		t.Fatalf("could not approve: %s", err)
+	}
+	if v := u.GetAnnotations()[ambassadorinstallation.ApprovedVersionAnnotation]; v != "6.5.0" {
+		t.Errorf("unexpected approved version: %q", v)
+	}
+	if _, err := approveUpgrade(u, "6.6.0"); err != nil {
+		t.Fatalf("could not approve: %s", err)
+	}
+	if v := u.GetAnnotations()[ambassadorinstallation.ApprovedVersionAnnotation]; v != "6.6.0" {
+		t.Errorf("unexpected approved version: %q", v)
+	}
+
+	now := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	if _, err := forceUpgrade(u, now); err != nil {
+		t.Fatalf("could not force: %s", err)
+	}
+	if v := u.GetAnnotations()[ambassadorinstallation.ForceUpgradeAnnotation]; v != "2020-05-01T10:00:00Z" {
+		t.Errorf("unexpected force annotation: %q", v)
+	}
+	if _, err := requestReconciliation(u, now.Add(time.Millisecond)); err != nil {
+		t.Fatalf("could not request a reconciliation: %s", err)
+	}
+	if v := u.GetAnnotations()[ambassadorinstallation.RequestedAtAnnotation]; v != "2020-05-01T10:00:00.001Z" {
+		t.Errorf("unexpected requestedAt annotation: %q", v)
+	}
+}
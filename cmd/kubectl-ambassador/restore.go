@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/controller/ambassadorinstallation"
+)
+
+func newRestoreCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "restore NAME [SNAPSHOT]",
+		Short: "Restore a snapshot of the getambassador.io resources (by default, the newest one) taken before an upgrade",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			c, err := o.client()
+			if err != nil {
+				return err
+			}
+			u, err := o.get(ctx, c, args[0])
+			if err != nil {
+				return err
+			}
+			installation, err := toInstallation(u)
+			if err != nil {
+				return err
+			}
+			name := ""
+			if len(args) > 1 {
+				name = args[1]
+			}
+			snapshot, err := findSnapshot(installation.GetStatus(), name)
+			if err != nil {
+				return err
+			}
+
+			resources, err := ambassadorinstallation.LoadSnapshot(ctx, c, snapshot)
+			if err != nil {
+				return err
+			}
+			restored, err := ambassadorinstallation.RestoreResources(ctx, c, resources)
+			fmt.Printf("%d of %d resources restored from %s %s/%s (revision %d)\n", restored, len(resources),
+				snapshot.Storage, snapshot.Namespace, snapshot.Name, snapshot.Revision)
+			return err
+		},
+	}
+}
+
+// findSnapshot returns a snapshot in the status of an installation (the newest one when no name is provided)
+func findSnapshot(status *ambassador.AmbassadorInstallationStatus, name string) (ambassador.AmbassadorSnapshotStatus, error) {
+	if len(status.Snapshots) == 0 {
+		return ambassador.AmbassadorSnapshotStatus{}, errors.New("there are no snapshots (see spec.snapshots)")
+	}
+	if len(name) == 0 {
+		return status.Snapshots[0], nil
+	}
+	for _, s := range status.Snapshots {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	return ambassador.AmbassadorSnapshotStatus{}, fmt.Errorf("snapshot %q not found", name)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+)
+
+func TestFindSnapshot(t *testing.T) {
+	status := &ambassador.AmbassadorInstallationStatus{}
+	if _, err := findSnapshot(status, ""); err == nil {
+		t.Errorf("expected an error without snapshots")
+	}
+
+	status.Snapshots = []ambassador.AmbassadorSnapshotStatus{
+		{Name: "ambassador-snapshot-4", Revision: 4},
+		{Name: "ambassador-snapshot-2", Revision: 2},
+	}
+	if s, err := findSnapshot(status, ""); err != nil || s.Revision != 4 {
+		t.Errorf("unexpected newest snapshot %+v (%v)", s, err)
+	}
+	if s, err := findSnapshot(status, "ambassador-snapshot-2"); err != nil || s.Revision != 2 {
+		t.Errorf("unexpected snapshot %+v (%v)", s, err)
+	}
+	if _, err := findSnapshot(status, "ambassador-snapshot-3"); err == nil {
+		t.Errorf("expected an error for an unknown snapshot")
+	}
+}
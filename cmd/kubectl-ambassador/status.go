@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/controller/ambassadorinstallation"
+)
+
+func newStatusCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status NAME",
+		Short: "Show the status, conditions, history and next update window of an installation",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			c, err := o.client()
+			if err != nil {
+				return err
+			}
+			u, err := o.get(ctx, c, args[0])
+			if err != nil {
+				return err
+			}
+			return printStatus(os.Stdout, u, time.Now())
+		},
+	}
+}
+
+// printStatus prints a summary of the status of an installation
+func printStatus(out io.Writer, u *unstructured.Unstructured, now time.Time) error {
+	installation, err := toInstallation(u)
+	if err != nil {
+		return err
+	}
+	spec := installation.GetSpec()
+	status := installation.GetStatus()
+	annotations := u.GetAnnotations()
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", u.GetName())
+	if len(u.GetNamespace()) > 0 {
+		fmt.Fprintf(w, "Namespace:\t%s\n", u.GetNamespace())
+	}
+	fmt.Fprintf(w, "Version:\t%s\n", valueOr(spec.Version, "*"))
+	if len(spec.ChartVersion) > 0 {
+		fmt.Fprintf(w, "Chart version:\t%s\n", spec.ChartVersion)
+	}
+	if r := status.DeployedRelease; r != nil {
+		fmt.Fprintf(w, "Deployed:\t%s (chart %s, %s) in %s\n", r.AppVersion, r.Version, r.Flavor, r.Namespace)
+	} else if status.RenderedRelease == nil {
+		fmt.Fprintf(w, "Deployed:\t<none>\n")
+	}
+	if r := status.RenderedRelease; r != nil {
+		fmt.Fprintf(w, "Rendered:\t%s (chart %s, %s) for %s\n", r.AppVersion, r.Version, r.Flavor, r.Namespace)
+		for _, location := range r.Locations {
+			fmt.Fprintf(w, "\t%s\n", location)
+		}
+	}
+	if v := status.Verification; v != nil {
+		fmt.Fprintf(w, "Verification:\t%s for revision %d (%d checks)\n", v.Outcome, v.Revision, len(v.Checks))
+		for _, c := range v.Checks {
+			if c.Outcome != ambassador.OutcomeSucceeded {
+				fmt.Fprintf(w, "\t%s %q: %s\n", c.Type, c.Name, c.Message)
+			}
+		}
+	}
+	for _, h := range status.Hooks {
+		fmt.Fprintf(w, "%s hook %s:\t%s (%s)\n", h.Phase, h.Name, h.Outcome, valueOr(h.Error, h.Job))
+	}
+	for _, s := range status.Snapshots {
+		restored := ""
+		if s.RestoreTime != nil {
+			restored = ", restored at " + s.RestoreTime.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "Snapshot %s:\t%d resources of revision %d (%s) in %s %s%s\n", s.Name, s.Resources, s.Revision,
+			valueOr(s.AppVersion, "?"), s.Storage, s.Namespace, restored)
+	}
+	if next := status.NextUpgrade; next != nil {
+		when := "never (not allowed by the update window)"
+		if next.Time != nil {
+			when = next.Time.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "Next upgrade:\t%s (chart %s) at %s\n", next.AppVersion, next.Version, when)
+	}
+	if approved, ok := annotations[ambassadorinstallation.ApprovedVersionAnnotation]; ok {
+		fmt.Fprintf(w, "Approved upgrade:\tchart %s\n", approved)
+	}
+	handled := status.LastHandledRequest
+	if handled == nil {
+		handled = &ambassador.AmbassadorHandledRequest{}
+	}
+	if forced, ok := annotations[ambassadorinstallation.ForceUpgradeAnnotation]; ok {
+		fmt.Fprintf(w, "Forced upgrade:\t%s (%s)\n", forced, requestState(forced, handled.ForceUpgradeAt))
+	}
+	if requested, ok := annotations[ambassadorinstallation.RequestedAtAnnotation]; ok {
+		fmt.Fprintf(w, "Requested reconciliation:\t%s (%s)\n", requested, requestState(requested, handled.RequestedAt))
+	}
+
+	fmt.Fprintf(w, "Update window:\t%s\n", valueOr(spec.UpdateWindow, "<always>"))
+	if window, err := ambassadorinstallation.NewUpdateWindow(spec.UpdateWindow); err != nil {
+		fmt.Fprintf(w, "Next update window:\tinvalid update window: %s\n", err)
+	} else if next, ok := window.Next(now); !ok {
+		fmt.Fprintf(w, "Next update window:\tnever\n")
+	} else if !next.After(now) {
+		fmt.Fprintf(w, "Next update window:\topen now\n")
+	} else {
+		fmt.Fprintf(w, "Next update window:\t%s (in %s)\n", next.Format(time.RFC3339), next.Sub(now).Round(time.Minute))
+	}
+	if paused := status.FindCondition(ambassador.ConditionPaused); paused != nil && paused.Status == ambassador.StatusTrue {
+		fmt.Fprintf(w, "Paused:\tyes (%s)\n", paused.Message)
+	} else if spec.Paused || annotations[ambassadorinstallation.PausedAnnotation] == "true" {
+		fmt.Fprintf(w, "Paused:\tyes (not seen by the operator yet)\n")
+	}
+	if !status.LastCheckTime.IsZero() {
+		fmt.Fprintf(w, "Last check:\t%s\n", status.LastCheckTime.Format(time.RFC3339))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "\nConditions:\n")
+	w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "  TYPE\tSTATUS\tREASON\tLAST TRANSITION\tMESSAGE\n")
+	for _, c := range status.Conditions {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", c.Type, c.Status, c.Reason,
+			c.LastTransitionTime.Format(time.RFC3339), firstLine(c.Message))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(status.History) > 0 {
+		fmt.Fprintf(out, "\nHistory:\n")
+		w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "  STARTED\tTRIGGER\tOUTCOME\tVERSION\tCHART\tREVISION\tERROR\n")
+		for _, h := range status.History {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%d\t%s\n", h.StartTime.Format(time.RFC3339), h.Trigger,
+				h.Outcome, h.AppVersion, h.Version, h.Revision, firstLine(h.Error))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printVersions prints a list of versions, marking the deployed one and the next upgrade
+func printVersions(out io.Writer, versions []ambassador.AmbassadorChartVersion, status *ambassador.AmbassadorInstallationStatus) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "CHART\tVERSION\t\n")
+	for _, v := range versions {
+		mark := ""
+		if r := status.DeployedRelease; r != nil && r.Version == v.Version && r.AppVersion == v.AppVersion {
+			mark = "(deployed)"
+		} else if next := status.NextUpgrade; next != nil && next.Version == v.Version {
+			mark = "(next upgrade)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", v.Version, v.AppVersion, mark)
+	}
+	return w.Flush()
+}
+
+// requestState returns the state of an on-demand request, given the last value handled
+func requestState(requested, handled string) string {
+	if requested == handled {
+		return "handled"
+	}
+	return "pending"
+}
+
+func valueOr(s string, def string) string {
+	if len(s) == 0 {
+		return def
+	}
+	return s
+}
+
+// firstLine returns the first line of a (possibly multi-line) message
+func firstLine(s string) string {
+	for i, c := range s {
+		if c == '\n' {
+			return s[:i] + "..."
+		}
+	}
+	return s
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	ambassadorv3 "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v3"
+	"github.com/datawire/ambassador-operator/pkg/controller/ambassadorinstallation"
+)
+
+func newValidateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate FILE",
+		Short: "Validate the installations in a file (or in the standard input, with '-') offline",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var in io.Reader = os.Stdin
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+
+			numErrs, err := validateDocuments(os.Stdout, in)
+			if err != nil {
+				return err
+			}
+			if numErrs > 0 {
+				return fmt.Errorf("%d validation error(s) found", numErrs)
+			}
+			return nil
+		},
+	}
+}
+
+// validateDocuments validates all the installations found in a (multi-document) YAML stream,
+// printing the results to `out`. Documents that are not installations are ignored.
+// It returns the number of validation errors found.
+func validateDocuments(out io.Writer, in io.Reader) (int, error) {
+	numErrs := 0
+	numInstallations := 0
+	decoder := yaml.NewYAMLOrJSONDecoder(bufio.NewReader(in), 4096)
+	for {
+		u := &unstructured.Unstructured{}
+		if err := decoder.Decode(&u.Object); err == io.EOF {
+			break
+		} else if err != nil {
+			return numErrs, fmt.Errorf("could not parse document: %w", err)
+		}
+		if len(u.Object) == 0 {
+			continue
+		}
+
+		gvk := u.GroupVersionKind()
+		if gvk.Group != ambassador.SchemeGroupVersion.Group {
+			continue
+		}
+		numInstallations++
+
+		errs := validateDocument(u)
+		if len(errs) == 0 {
+			fmt.Fprintf(out, "%s %q: valid\n", gvk.Kind, u.GetName())
+			continue
+		}
+		fmt.Fprintf(out, "%s %q: invalid\n", gvk.Kind, u.GetName())
+		for _, e := range errs {
+			fmt.Fprintf(out, "  %s\n", e.Error())
+		}
+		numErrs += len(errs)
+	}
+
+	if numInstallations == 0 {
+		return 0, fmt.Errorf("no installations found")
+	}
+	return numErrs, nil
+}
+
+// validateDocument validates an installation with the same parsers used by the
+// controller. Installations in the v3 API are defaulted and converted to the v2
+// API (the version used by the controller) before being validated.
+func validateDocument(u *unstructured.Unstructured) field.ErrorList {
+	gvk := u.GroupVersionKind()
+	switch {
+	case gvk == ambassadorinstallation.DefaultGVK || gvk == ambassadorinstallation.ClusterGVK:
+		return ambassadorinstallation.ValidateInstallation(u)
+
+	case gvk == ambassadorv3.SchemeGroupVersion.WithKind(ambassadorinstallation.DefaultGVK.Kind):
+		v3 := &ambassadorv3.AmbassadorInstallation{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), v3); err != nil {
+			return field.ErrorList{field.Invalid(field.NewPath("spec"), nil, err.Error())}
+		}
+		v3.Default()
+		v2 := &ambassador.AmbassadorInstallation{}
+		if err := v3.ConvertTo(v2); err != nil {
+			return field.ErrorList{field.Invalid(field.NewPath("spec"), nil, err.Error())}
+		}
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(v2)
+		if err != nil {
+			return field.ErrorList{field.Invalid(field.NewPath("spec"), nil, err.Error())}
+		}
+		converted := &unstructured.Unstructured{Object: content}
+		converted.SetGroupVersionKind(ambassadorinstallation.DefaultGVK)
+		return ambassadorinstallation.ValidateInstallation(converted)
+
+	default:
+		return field.ErrorList{field.NotSupported(field.NewPath("kind"), gvk.String(),
+			[]string{ambassadorinstallation.DefaultGVK.String(), ambassadorinstallation.ClusterGVK.String(),
+				ambassadorv3.SchemeGroupVersion.WithKind(ambassadorinstallation.DefaultGVK.Kind).String()})}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestValidateDocuments(t *testing.T) {
+	in := `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: ambassador
+---
+apiVersion: getambassador.io/v2
+kind: AmbassadorInstallation
+metadata:
+  name: valid
+spec:
+  version: "1.*"
+  updateWindow: "* 0-6 * * SUN"
+---
+apiVersion: getambassador.io/v2
+kind: AmbassadorInstallation
+metadata:
+  name: invalid
+spec:
+  version: "not a version"
+  updateWindow: "every sunday"
+---
+apiVersion: getambassador.io/v3
+kind: AmbassadorInstallation
+metadata:
+  name: v3
+spec:
+  version: "1.*"
+  updatePolicy:
+    window: "bad window"
+`
+	out := &bytes.Buffer{}
+	numErrs, err := validateDocuments(out, strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if numErrs != 3 {
+		t.Errorf("expected 3 errors, got %d:\n%s", numErrs, out.String())
+	}
+	for _, expected := range []string{
+		`AmbassadorInstallation "valid": valid`,
+		`AmbassadorInstallation "invalid": invalid`,
+		`spec.version`,
+		`spec.updateWindow`,
+		`AmbassadorInstallation "v3": invalid`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("%q not found in the output:\n%s", expected, out.String())
+		}
+	}
+
+	if _, err := validateDocuments(out, strings.NewReader("apiVersion: v1\nkind: Namespace\n")); err == nil {
+		t.Errorf("expected an error when there are no installations")
+	}
+}
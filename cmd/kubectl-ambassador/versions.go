@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/controller/ambassadorinstallation"
+	"github.com/datawire/ambassador-operator/pkg/helm"
+)
+
+func newVersionsCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "versions NAME",
+		Short: "List the versions in the Helm repo that are allowed by the installation",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			c, err := o.client()
+			if err != nil {
+				return err
+			}
+			u, err := o.get(ctx, c, args[0])
+			if err != nil {
+				return err
+			}
+			installation, err := toInstallation(u)
+			if err != nil {
+				return err
+			}
+
+			versions, err := findVersions(installation.GetSpec())
+			if err != nil {
+				return err
+			}
+			if len(versions) == 0 {
+				fmt.Printf("No versions allowed by %q found in the Helm repo\n", installation.GetSpec().Version)
+				return nil
+			}
+			return printVersions(os.Stdout, versions, installation.GetStatus())
+		},
+	}
+}
+
+// findVersions returns the versions in the Helm repo that are allowed by the `version`
+// and `chartVersion` rules of an installation, using the same rules as the operator
+func findVersions(spec *ambassador.AmbassadorInstallationSpec) ([]ambassador.AmbassadorChartVersion, error) {
+	versionRule, err := helm.NewChartVersionRule(spec.Version)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse version from %q: %w", spec.Version, err)
+	}
+	var chartVersionRule helm.ChartVersionRule
+	if len(spec.ChartVersion) > 0 {
+		chartVersionRule, err = helm.NewChartVersionRule(spec.ChartVersion)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse chart version from %q: %w", spec.ChartVersion, err)
+		}
+	}
+
+	chartName, _ := ambassadorinstallation.ChartNameFor(versionRule, spec.InstallOSS)
+	downloader, err := helm.NewDownloader(helm.DownloaderOptions{
+		URL:          spec.HelmRepo,
+		Version:      versionRule,
+		ChartVersion: chartVersionRule,
+		ChartName:    chartName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = downloader.Cleanup() }()
+
+	found, err := downloader.FindVersions()
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]ambassador.AmbassadorChartVersion, 0, len(found))
+	for _, v := range found {
+		versions = append(versions, ambassador.AmbassadorChartVersion{Version: v.Version, AppVersion: v.AppVersion})
+	}
+	return versions, nil
+}
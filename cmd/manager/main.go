@@ -9,6 +9,8 @@ import (
 
 	"github.com/datawire/ambassador-operator/pkg/apis"
 	"github.com/datawire/ambassador-operator/pkg/controller"
+	"github.com/datawire/ambassador-operator/pkg/controller/ambassadorinstallation"
+	"github.com/datawire/ambassador-operator/pkg/tracing"
 	"github.com/datawire/ambassador-operator/version"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -19,6 +21,7 @@ import (
 	"github.com/operator-framework/operator-sdk/pkg/log/zap"
 	sdkVersion "github.com/operator-framework/operator-sdk/version"
 	"github.com/spf13/pflag"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -33,6 +36,9 @@ var (
 )
 var log = logf.Log.WithName("cmd")
 
+// the default name of the operator (when OPERATOR_NAME is not provided)
+const defOperatorName = "ambassador-operator"
+
 func printVersion() {
 	log.Info(fmt.Sprintf("Operator Version: %s", version.Version))
 	log.Info(fmt.Sprintf("Go Version: %s", runtime.Version()))
@@ -40,6 +46,15 @@ func printVersion() {
 	log.Info(fmt.Sprintf("Version of operator-sdk: %v", sdkVersion.Version))
 }
 
+// operatorName returns the name of the operator, or a default name when not provided
+func operatorName() string {
+	name, err := k8sutil.GetOperatorName()
+	if err != nil {
+		return defOperatorName
+	}
+	return name
+}
+
 func main() {
 	// Add the zap logger flag set to the CLI. The flag set must
 	// be added before calling pflag.Parse().
@@ -63,12 +78,20 @@ func main() {
 
 	printVersion()
 
-	namespace, err := k8sutil.GetWatchNamespace()
+	// WATCH_NAMESPACE can contain a comma-separated list of namespaces
+	namespaces, err := ambassadorinstallation.GetWatchNamespaces()
 	if err != nil {
 		log.Error(err, "Failed to get watch namespace")
 		os.Exit(1)
 	}
 
+	// Setup the tracing of the reconciliations (only when an exporter has been configured)
+	tracer, err := tracing.SetupFromEnv()
+	if err != nil {
+		log.Error(err, "Failed to setup tracing")
+		os.Exit(1)
+	}
+
 	// Get a config to talk to the apiserver
 	cfg, err := config.GetConfig()
 	if err != nil {
@@ -78,17 +101,42 @@ func main() {
 
 	ctx := context.TODO()
 	// Become the leader before proceeding
-	err = leader.Become(ctx, "ambassador-operator-lock")
+	// note: use a lock per operator name, so several operators can live in the same namespace
+	err = leader.Become(ctx, fmt.Sprintf("%s-lock", operatorName()))
 	if err != nil {
 		log.Error(err, "")
 		os.Exit(1)
 	}
 
-	// Create a new Cmd to provide shared dependencies and start components
-	mgr, err := manager.New(cfg, manager.Options{
-		Namespace:          namespace,
+	options := manager.Options{
 		MetricsBindAddress: fmt.Sprintf("%s:%d", metricsHost, metricsPort),
-	})
+		Port:               ambassadorinstallation.DefaultWebhookPort,
+	}
+
+	// the namespace used for creating the ServiceMonitor
+	metricsNamespace := ""
+
+	switch len(namespaces) {
+	case 0:
+		log.Info("Watching all namespaces")
+		if ns, err := k8sutil.GetOperatorNamespace(); err == nil {
+			metricsNamespace = ns
+		}
+	case 1:
+		log.Info("Watching a single namespace", "namespace", namespaces[0])
+		options.Namespace = namespaces[0]
+		metricsNamespace = namespaces[0]
+	default:
+		log.Info("Watching multiple namespaces", "namespaces", namespaces)
+		options.NewCache = cache.MultiNamespacedCacheBuilder(namespaces)
+		metricsNamespace = namespaces[0]
+		if ns, err := k8sutil.GetOperatorNamespace(); err == nil {
+			metricsNamespace = ns
+		}
+	}
+
+	// Create a new Cmd to provide shared dependencies and start components
+	mgr, err := manager.New(cfg, options)
 	if err != nil {
 		log.Error(err, "")
 		os.Exit(1)
@@ -108,13 +156,26 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Setup the admission webhooks (only when enabled, as they require some certificates)
+	if ambassadorinstallation.WebhooksEnabled() {
+		if err := ambassadorinstallation.AddWebhooks(mgr); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+	}
+
 	// Add the Metrics Service
-	addMetrics(ctx, cfg, namespace)
+	addMetrics(ctx, cfg, metricsNamespace)
 
 	log.Info("Starting the Cmd.")
 
 	// Start the Cmd
-	if err := mgr.Start(signals.SetupSignalHandler()); err != nil {
+	err = mgr.Start(signals.SetupSignalHandler())
+
+	// make sure all the traces are exported before exiting
+	tracer.Flush()
+
+	if err != nil {
 		log.Error(err, "Manager exited non-zero")
 		os.Exit(1)
 	}
@@ -13,3 +13,27 @@ Create chart name and version as used by the chart label.
 {{- define "operator.chart" -}}
 {{- printf "%s-%s" .Chart.Name .Chart.Version | replace "+" "_" | trunc 63 | trimSuffix "-" -}}
 {{- end -}}
+
+{{/*
+Render the AmbassadorInstallations CRD (copied to the chart's `files` by `ci/create_manifests.sh`).
+The `v3` version is served only when a conversion webhook is provided in `.conversion`.
+The CRD is kept on uninstalls, so the installations are not removed with the chart.
+*/}}
+{{- define "operator.installationsCRD" -}}
+{{- $crd := .root.Files.Get "files/ambassadorinstallations-crd.yaml" | fromYaml -}}
+{{- if $crd.spec }}
+{{- range $crd.spec.versions }}
+{{- if eq .name "v3" }}
+{{- $_ := set . "served" (not (empty $.conversion)) }}
+{{- end }}
+{{- end }}
+{{- with .conversion }}
+{{- $_ := set $crd.spec "conversion" . }}
+{{- end }}
+{{- $annotations := $crd.metadata.annotations | default dict }}
+{{- $_ := set $annotations "helm.sh/resource-policy" "keep" }}
+{{- $_ := set $crd.metadata "annotations" $annotations }}
+---
+{{ toYaml $crd }}
+{{- end }}
+{{- end -}}
@@ -0,0 +1,10 @@
+package apis
+
+import (
+	v3 "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v3"
+)
+
+func init() {
+	// Register the types with the Scheme so the components can map objects to GroupVersionKinds and back
+	AddToSchemes = append(AddToSchemes, v3.SchemeBuilder.AddToScheme)
+}
@@ -0,0 +1,5 @@
+package v2
+
+// Hub marks this type as a conversion hub: all the other versions of the
+// AmbassadorInstallation are converted to/from this version.
+func (*AmbassadorInstallation) Hub() {}
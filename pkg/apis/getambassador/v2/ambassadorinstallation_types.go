@@ -35,9 +35,22 @@ type AmbassadorInstallationSpec struct {
 	//
 	Version string `json:"version,omitempty"`
 
+	// An (optional) constraint for the version of the Helm chart, using the same
+	// SemVer syntax as `version`. It is evaluated together with `version`: the
+	// operator will choose the most recent chart that is allowed by both constraints.
+	// This can be used for holding back the chart while keeping the version of
+	// Ambassador (ie, when a change in the chart templates breaks an installation).
+	ChartVersion string `json:"chartVersion,omitempty"`
+
 	// An (optional) image to use instead of the image specified in the Helm chart.
+	// The image can be pinned to a digest (ie, `quay.io/datawire/aes@sha256:...`).
 	BaseImage string `json:"baseImage,omitempty"`
 
+	// An (optional) registry (with an optional path) where all the images rendered
+	// by the Helm chart (Ambassador, the agent, Redis...) will be pulled from
+	// (ie, `registry.internal:5000/mirror`).
+	ImageRegistryMirror string `json:"imageRegistryMirror,omitempty"`
+
 	// An (optional) Helm repository.
 	HelmRepo string `json:"helmRepo,omitempty"`
 
@@ -63,6 +76,26 @@ type AmbassadorInstallationSpec struct {
 	//   sooner/later than expected.
 	UpdateWindow string `json:"updateWindow,omitempty"`
 
+	// Pauses the reconciliation of this installation: the operator will not perform
+	// any install, upgrade or repair (but it will still uninstall Ambassador when the
+	// installation is deleted). The `getambassador.io/paused: "true"` annotation has
+	// the same effect.
+	Paused bool `json:"paused,omitempty"`
+
+	// The (optional) mode of the installation:
+	//
+	// * `install` (the default): the operator installs Ambassador in the cluster.
+	// * `render`: the operator only renders the manifests of the Helm chart (with the
+	//   same version resolution, update window and values) and writes them to the
+	//   destinations in `render`, so they can be applied by some other tool (ie,
+	//   a GitOps controller). Nothing is installed in the cluster.
+	// +kubebuilder:validation:Enum=install;render
+	Mode AmbassadorInstallationMode `json:"mode,omitempty"`
+
+	// Some (optional) options for the `render` mode: where the manifests are written.
+	// +nullable
+	Render *AmbassadorRenderOptions `json:"render,omitempty"`
+
 	// Installs [Ambassador OSS](https://www.getambassador.io/docs/latest/topics/install/install-ambassador-oss/)
 	// instead of [AES](https://www.getambassador.io/docs/latest/topics/install/).
 	// Default is false which means it installs AES by default.
@@ -73,36 +106,339 @@ type AmbassadorInstallationSpec struct {
 	// 3. AES is installed and the user sets `installOSS: true`, then we point users to the docs which gives them
 	//    pointers on how to do that themselves.
 	InstallOSS bool `json:"installOSS,omitempty"`
+
+	// An (optional) name for the Helm release. It defaults to the name of the
+	// `AmbassadorInstallation`. It cannot be changed once Ambassador has been
+	// deployed: the release keeps the name it was installed with.
+	ReleaseName string `json:"releaseName,omitempty"`
+
+	// An (optional) `ambassador_id` for this installation (set in the `AMBASSADOR_ID`
+	// environment variable). Several `AmbassadorInstallation`s can live in the same
+	// namespace as long as they use different `ambassadorID`s and release names.
+	// When not provided, the `AMBASSADOR_ID` in the `helmValues` is used, or `default`
+	// if there is none.
+	AmbassadorID string `json:"ambassadorID,omitempty"`
+
+	// An (optional) namespace where Ambassador will be installed. It defaults to
+	// the namespace of the `AmbassadorInstallation` (or `ambassador` for a
+	// `ClusterAmbassadorInstallation`). Changing this value once Ambassador has
+	// been deployed has no effect.
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+
+	// Some (optional) options for the `targetNamespace`.
+	// +nullable
+	TargetNamespaceOptions *AmbassadorNamespaceOptions `json:"targetNamespaceOptions,omitempty"`
+
+	// Some (optional) values for the Helm chart
+	// (see https://github.com/datawire/ambassador-chart/#configuration).
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +nullable
+	HelmValues *runtime.RawExtension `json:"helmValues,omitempty"`
+
+	// Some (optional) patches applied to the resources rendered by the Helm chart,
+	// for the customizations that are not supported by the chart values (ie, extra
+	// sidecars, tolerations or annotations). They are applied in order.
+	// +optional
+	Patches []AmbassadorPatch `json:"patches,omitempty"`
+
+	// Some (optional) checks performed after installing or upgrading Ambassador (the
+	// Helm tests of the chart and some HTTP probes). The release fails when some check
+	// does not pass.
+	// +nullable
+	Verification *AmbassadorVerification `json:"verification,omitempty"`
+
+	// Some (optional) Jobs run before and after the upgrades performed by the operator
+	// (ie, for backing up resources or draining traffic).
+	// +nullable
+	Hooks *AmbassadorHooks `json:"hooks,omitempty"`
+
+	// Snapshots of the `getambassador.io` resources (Mappings, Hosts, Modules...) taken
+	// before the upgrades performed by the operator, so they can be restored if the
+	// upgrade is rolled back.
+	// +nullable
+	Snapshots *AmbassadorSnapshots `json:"snapshots,omitempty"`
+}
+
+// AmbassadorSnapshotStorage is the kind of resource where the snapshots are stored
+type AmbassadorSnapshotStorage string
+
+const (
+	// SnapshotStorageSecret stores the snapshots in Secrets
+	SnapshotStorageSecret AmbassadorSnapshotStorage = "Secret"
+	// SnapshotStorageConfigMap stores the snapshots in ConfigMaps
+	SnapshotStorageConfigMap AmbassadorSnapshotStorage = "ConfigMap"
+)
+
+// AmbassadorSnapshots defines the snapshots of the Ambassador resources taken before the upgrades
+type AmbassadorSnapshots struct {
+	// Where the snapshots are stored (in the namespace of the release): `Secret`
+	// (the default) or `ConfigMap`.
+	// +kubebuilder:validation:Enum=Secret;ConfigMap
+	Storage AmbassadorSnapshotStorage `json:"storage,omitempty"`
+
+	// The (optional) kinds of `getambassador.io/v2` resources included in the snapshots.
+	// Defaults to `Mapping`, `Host`, `Module`, `TLSContext` and `Filter`.
+	// +optional
+	Kinds []string `json:"kinds,omitempty"`
+
+	// The (optional) number of snapshots kept. Defaults to `3`.
+	// +kubebuilder:validation:Minimum=1
+	Keep int `json:"keep,omitempty"`
+
+	// Restores the snapshot automatically when an upgrade is rolled back (see
+	// `spec.verification.rollbackOnFailure`). Otherwise, the snapshot can be restored
+	// with `kubectl ambassador restore`.
+	// +optional
+	RestoreOnRollback bool `json:"restoreOnRollback,omitempty"`
+}
+
+// AmbassadorHooks defines the Jobs run around the upgrades
+type AmbassadorHooks struct {
+	// Jobs run (in order) before every upgrade. The upgrade is aborted when some of them fails.
+	// +optional
+	PreUpgrade []AmbassadorHook `json:"preUpgrade,omitempty"`
+
+	// Jobs run (in order) after every successful upgrade.
+	// +optional
+	PostUpgrade []AmbassadorHook `json:"postUpgrade,omitempty"`
+
+	// The (optional) maximum time each Job can take (ie, `10m`). Defaults to `5m`.
+	// +nullable
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// AmbassadorHook defines a Job run around the upgrades
+type AmbassadorHook struct {
+	// The name of the hook, used in the status and in the name of the Job.
+	Name string `json:"name"`
+
+	// The template of the Job (a `batch/v1` `JobTemplateSpec`, with a `metadata`
+	// and a `spec`). The Job is created in the namespace of the release.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Template *runtime.RawExtension `json:"template"`
+}
+
+// AmbassadorVerification defines the checks performed after installing or upgrading Ambassador
+type AmbassadorVerification struct {
+	// Runs the Helm tests of the chart (like `helm test`).
+	// +optional
+	HelmTests bool `json:"helmTests,omitempty"`
+
+	// Some (optional) HTTP probes sent to the Ambassador Service. They are retried
+	// until they succeed or the `timeout` expires.
+	// +optional
+	Probes []AmbassadorProbe `json:"probes,omitempty"`
+
+	// The (optional) maximum time for running all the checks (ie, `5m`). Defaults to `2m`.
+	// +nullable
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Rolls back an upgrade to the previous release when some check fails.
+	// +optional
+	RollbackOnFailure bool `json:"rollbackOnFailure,omitempty"`
+}
+
+// AmbassadorProbe defines an HTTP request sent to an Ambassador Service for verifying a release
+type AmbassadorProbe struct {
+	// An (optional) name for the probe, used in the status. Defaults to the path.
+	Name string `json:"name,omitempty"`
+
+	// The (optional) Service the request is sent to. Defaults to the Service
+	// created by the chart for the release (ie, `ambassador`).
+	Service string `json:"service,omitempty"`
+
+	// The (optional) port of the Service. Defaults to `80`.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port,omitempty"`
+
+	// The (optional) scheme: `http` (the default) or `https` (the certificate is not verified).
+	// +kubebuilder:validation:Enum=http;https
+	Scheme string `json:"scheme,omitempty"`
+
+	// An (optional) `Host` header for the request (ie, for matching the `host` of a Mapping).
+	Host string `json:"host,omitempty"`
+
+	// The (optional) path requested (ie, the prefix of some Mapping). Defaults
+	// to `/ambassador/v0/check_ready`.
+	Path string `json:"path,omitempty"`
+
+	// The (optional) status code expected in the response. Defaults to `200`.
+	// +kubebuilder:validation:Minimum=100
+	// +kubebuilder:validation:Maximum=599
+	ExpectedStatus int `json:"expectedStatus,omitempty"`
+}
+
+// AmbassadorPatchType is the type of a patch
+type AmbassadorPatchType string
+
+const (
+	// PatchStrategicMerge is a strategic merge patch (like `kubectl patch --type strategic`).
+	// A JSON merge patch is used for the resources that have no strategic merge information
+	// (ie, custom resources).
+	PatchStrategicMerge AmbassadorPatchType = "StrategicMerge"
+	// PatchJSON6902 is a JSON patch (RFC 6902), a list of operations
+	PatchJSON6902 AmbassadorPatchType = "JSON6902"
+)
+
+// AmbassadorPatch defines a patch for some resources rendered by the Helm chart
+type AmbassadorPatch struct {
+	// The (optional) resources patched. When not provided, the resource patched is the
+	// one with the `kind` and `metadata.name` in a `StrategicMerge` patch.
+	// +nullable
+	Target *AmbassadorPatchTarget `json:"target,omitempty"`
+
+	// The (optional) type of the patch: `StrategicMerge` (the default) or `JSON6902`.
+	// +kubebuilder:validation:Enum=StrategicMerge;JSON6902
+	Type AmbassadorPatchType `json:"type,omitempty"`
+
+	// The patch, in YAML or JSON.
+	Patch string `json:"patch"`
+}
+
+// AmbassadorPatchTarget selects the resources a patch is applied to. All the fields
+// provided must match.
+type AmbassadorPatchTarget struct {
+	Group   string `json:"group,omitempty"`
+	Version string `json:"version,omitempty"`
+	Kind    string `json:"kind,omitempty"`
+	Name    string `json:"name,omitempty"`
+
+	// A label selector (ie, `app.kubernetes.io/name=ambassador`)
+	LabelSelector string `json:"labelSelector,omitempty"`
+}
+
+// AmbassadorInstallationMode is the mode of an installation (see `mode`)
+type AmbassadorInstallationMode string
+
+const (
+	// ModeInstall installs Ambassador in the cluster
+	ModeInstall AmbassadorInstallationMode = "install"
+	// ModeRender only renders the manifests of Ambassador
+	ModeRender AmbassadorInstallationMode = "render"
+)
+
+// AmbassadorRenderOptions defines where the manifests are written in the `render` mode.
+// When no destination is provided, the manifests are written to a `ConfigMap` named
+// `<release name>-manifests`.
+type AmbassadorRenderOptions struct {
+	// The (optional) name of a `ConfigMap` (in the target namespace) where the
+	// manifests are written, in a `<release name>.yaml` key.
+	ConfigMapName string `json:"configMapName,omitempty"`
+
+	// An (optional) directory (ie, a volume mounted in the operator) where the
+	// manifests are written, in a `<target namespace>/<release name>.yaml` file.
+	Directory string `json:"directory,omitempty"`
+
+	// An (optional) Git repository where the manifests are committed and pushed.
+	// +nullable
+	Git *AmbassadorRenderGit `json:"git,omitempty"`
+}
+
+// AmbassadorRenderGit defines a Git repository where the manifests are written, in a
+// `<path>/<target namespace>/<release name>.yaml` file. The operator uses the `git` CLI,
+// so credentials can be provided in the URL or with the usual Git configuration (ie,
+// SSH keys mounted in the operator).
+type AmbassadorRenderGit struct {
+	// The URL of the repository: an `https`, `ssh` or scp-like (ie, `git@github.com:org/repo.git`) URL.
+	URL string `json:"url"`
+
+	// The (optional) branch where the manifests are committed (`master` by default).
+	Branch string `json:"branch,omitempty"`
+
+	// An (optional) directory in the repository.
+	Path string `json:"path,omitempty"`
+}
+
+// AmbassadorNamespaceOptions defines how the target namespace is managed
+type AmbassadorNamespaceOptions struct {
+	// Create the target namespace when it does not exist. A namespace created by the
+	// operator is deleted (with everything in it) when Ambassador is uninstalled.
+	Create bool `json:"create,omitempty"`
+
+	// Labels added to the target namespace (only when `create` is enabled).
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations added to the target namespace (only when `create` is enabled).
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 // AmbassadorInstallationStatus defines the observed state of AmbassadorInstallation
 type AmbassadorInstallationStatus struct {
 	// List of conditions the installation has experienced.
+	// The `Ready` condition summarizes all the other conditions.
 	Conditions []AmbInsCondition `json:"conditions"`
 
+	// The `metadata.generation` of the installation observed in the last reconciliation.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	// the currently deployed Helm chart
 	// +nullable
 	DeployedRelease *AmbassadorRelease `json:"deployedRelease,omitempty"`
 
+	// the Helm chart last rendered (in the `render` mode)
+	// +nullable
+	RenderedRelease *AmbassadorRenderedRelease `json:"renderedRelease,omitempty"`
+
+	// The result of the last verification of a release (see `spec.verification`)
+	// +nullable
+	Verification *AmbassadorVerificationStatus `json:"verification,omitempty"`
+
+	// The last run of every hook in `spec.hooks`.
+	// +optional
+	Hooks []AmbassadorHookStatus `json:"hooks,omitempty"`
+
+	// The snapshots of the `getambassador.io` resources kept (newest first, see `spec.snapshots`).
+	// +optional
+	Snapshots []AmbassadorSnapshotStatus `json:"snapshots,omitempty"`
+
 	// Last time a successful update check was performed.
 	// +nullable
 	LastCheckTime metav1.Time `json:"lastCheckTime,omitempty"`
+
+	// Versions available in the Helm repo that are allowed by the `version`
+	// and are more recent than the deployed release (newest first).
+	// +optional
+	AvailableVersions []AmbassadorChartVersion `json:"availableVersions,omitempty"`
+
+	// The next upgrade planned, and the earliest time it can be performed
+	// +nullable
+	NextUpgrade *AmbassadorUpgrade `json:"nextUpgrade,omitempty"`
+
+	// The last on-demand requests (the `reconcile.getambassador.io/*` annotations)
+	// handled by the operator.
+	// +nullable
+	LastHandledRequest *AmbassadorHandledRequest `json:"lastHandledRequest,omitempty"`
+
+	// The history of the releases (installations, upgrades and repairs) of this
+	// installation, newest first. Only the last `MaxHistoryLength` entries are kept.
+	// +optional
+	History []AmbassadorReleaseRecord `json:"history,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
-// AmbassadorInstallation is the Schema for the ambassadorinstallations API
+// AmbassadorInstallation is the Schema for the ambassadorinstallations API.
+// This is the storage version, and the hub for conversions between versions.
 //
+// +kubebuilder:storageversion
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:path=ambassadorinstallations,scope=Namespaced
 // +kubebuilder:printcolumn:name="VERSION",type=string,JSONPath=`.spec.version`
+// +kubebuilder:printcolumn:name="AMBASSADOR-ID",type="string",JSONPath=".spec.ambassadorID",priority=1,description="The ambassador_id of this installation"
 // +kubebuilder:printcolumn:name="UPDATE-WINDOW",type=integer,JSONPath=`.spec.updateWindow`
 // +kubebuilder:printcolumn:name="LAST-CHECK",type="string",JSONPath=".status.lastCheckTime",priority=0,description="Last time checked"
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status",priority=0,description="Indicates if the installation is ready"
 // +kubebuilder:printcolumn:name="DEPLOYED",type="string",JSONPath=".status.conditions[?(@.type=='Deployed')].status",priority=0,description="Indicates if deployment has completed"
 // +kubebuilder:printcolumn:name="REASON",type="string",JSONPath=".status.conditions[?(@.type=='Deployed')].reason",priority=1,description="Reason for deployment completed"
 // +kubebuilder:printcolumn:name="MESSAGE",type="string",JSONPath=".status.conditions[?(@.type=='Deployed')].message",priority=1,description="Message for deployment completed"
 // +kubebuilder:printcolumn:name="DEPLOYED-VERSION",type="string",JSONPath=".status.deployedRelease.appVersion",priority=0,description="Deployed version of Ambassador"
+// +kubebuilder:printcolumn:name="DEPLOYED-CHART",type="string",JSONPath=".status.deployedRelease.version",priority=1,description="Deployed version of the Helm chart"
 // +kubebuilder:printcolumn:name="DEPLOYED-FLAVOR",type="string",JSONPath=".status.deployedRelease.flavor",priority=0,description="Deployed flavor of Ambassador (OSS or AES)"
+// +kubebuilder:printcolumn:name="NEXT-VERSION",type="string",JSONPath=".status.nextUpgrade.appVersion",priority=1,description="Next version of Ambassador that will be deployed"
 type AmbassadorInstallation struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -111,6 +447,28 @@ type AmbassadorInstallation struct {
 	Status AmbassadorInstallationStatus `json:"status,omitempty"`
 }
 
+// GetSpec returns the spec of the AmbassadorInstallation
+func (in *AmbassadorInstallation) GetSpec() *AmbassadorInstallationSpec {
+	return &in.Spec
+}
+
+// GetStatus returns the status of the AmbassadorInstallation
+func (in *AmbassadorInstallation) GetStatus() *AmbassadorInstallationStatus {
+	return &in.Status
+}
+
+// Installation is the common interface for AmbassadorInstallations and ClusterAmbassadorInstallations
+type Installation interface {
+	metav1.Object
+	runtime.Object
+
+	GetSpec() *AmbassadorInstallationSpec
+	GetStatus() *AmbassadorInstallationStatus
+}
+
+var _ Installation = &AmbassadorInstallation{}
+var _ Installation = &ClusterAmbassadorInstallation{}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // AmbassadorInstallationList contains a list of AmbassadorInstallation
@@ -125,46 +483,285 @@ type AmbInsConditionStatus string
 type AmbInsConditionReason string
 
 // AmbInsCondition defines an Ambassador installation condition, as well as
-// the last time there was a transition to this condition..
+// the last time there was a transition to this condition.
+// It has the same fields as the standard `metav1.Condition`.
 type AmbInsCondition struct {
 	Type    AmbInsConditionType   `json:"type"`
 	Status  AmbInsConditionStatus `json:"status"`
 	Reason  AmbInsConditionReason `json:"reason,omitempty"`
 	Message string                `json:"message,omitempty"`
 
+	// The `metadata.generation` of the installation when this condition was set.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
 }
 
 // AmbassadorRelease defines a release of an Ambassador Helm chart
 type AmbassadorRelease struct {
 	Name       string `json:"name,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
 	Version    string `json:"version,omitempty"`
 	AppVersion string `json:"appVersion,omitempty"`
 	Manifest   string `json:"manifest,omitempty"`
 	Flavor     string `json:"flavor,omitempty"`
+
+	// The version constraint used when choosing the chart (from `spec.version`)
+	VersionRule string `json:"versionRule,omitempty"`
+
+	// The chart version constraint used when choosing the chart (from `spec.chartVersion`)
+	ChartVersionRule string `json:"chartVersionRule,omitempty"`
+
+	// The SHA-256 digest of the `spec.patches` applied to the release (a change in the
+	// patches requires an upgrade)
+	PatchesDigest string `json:"patchesDigest,omitempty"`
 }
 
+// AmbassadorRenderedRelease defines a release of an Ambassador Helm chart rendered
+// in the `render` mode
+type AmbassadorRenderedRelease struct {
+	Name       string `json:"name,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	Version    string `json:"version,omitempty"`
+	AppVersion string `json:"appVersion,omitempty"`
+	Flavor     string `json:"flavor,omitempty"`
+
+	// The SHA-256 digest of the manifests rendered
+	Digest string `json:"digest,omitempty"`
+
+	// The destinations where the manifests have been written
+	// (ie, `configmap:<namespace>/<name>`, `file:<path>` or `git:<url>#<branch>:<path>@<commit>`)
+	// +optional
+	Locations []string `json:"locations,omitempty"`
+
+	// The time the manifests were rendered.
+	// +nullable
+	Time *metav1.Time `json:"time,omitempty"`
+}
+
+// AmbassadorCheckType is the type of a check performed when verifying a release
+type AmbassadorCheckType string
+
+// AmbassadorVerificationStatus defines the result of the verification of a release
+type AmbassadorVerificationStatus struct {
+	// The version of the Helm chart
+	Version string `json:"version,omitempty"`
+	// The version of Ambassador
+	AppVersion string `json:"appVersion,omitempty"`
+	// The Helm revision of the release verified
+	Revision int `json:"revision,omitempty"`
+
+	Outcome AmbassadorReleaseOutcome `json:"outcome"`
+
+	// The time the verification finished
+	// +nullable
+	Time *metav1.Time `json:"time,omitempty"`
+
+	// The results of the individual checks
+	// +optional
+	Checks []AmbassadorCheckResult `json:"checks,omitempty"`
+}
+
+// AmbassadorCheckResult defines the result of a check performed when verifying a release
+type AmbassadorCheckResult struct {
+	Name    string                   `json:"name"`
+	Type    AmbassadorCheckType      `json:"type"`
+	Outcome AmbassadorReleaseOutcome `json:"outcome"`
+
+	// A short description of the result (ie, the status code received)
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	// CheckHelmTest is a Helm test of the chart
+	CheckHelmTest AmbassadorCheckType = "HelmTest"
+	// CheckProbe is an HTTP probe from `spec.verification.probes`
+	CheckProbe AmbassadorCheckType = "Probe"
+)
+
+// AmbassadorHookPhase is the moment when a hook is run
+type AmbassadorHookPhase string
+
+const (
+	// HookPreUpgrade is a hook run before an upgrade
+	HookPreUpgrade AmbassadorHookPhase = "PreUpgrade"
+	// HookPostUpgrade is a hook run after an upgrade
+	HookPostUpgrade AmbassadorHookPhase = "PostUpgrade"
+)
+
+// AmbassadorHookStatus defines the last run of a hook
+type AmbassadorHookStatus struct {
+	Name  string              `json:"name"`
+	Phase AmbassadorHookPhase `json:"phase"`
+
+	// The Job run (as `<namespace>/<name>`)
+	Job string `json:"job,omitempty"`
+
+	// The version of the Helm chart being upgraded to
+	Version string `json:"version,omitempty"`
+	// The version of Ambassador being upgraded to
+	AppVersion string `json:"appVersion,omitempty"`
+
+	StartTime metav1.Time `json:"startTime,omitempty"`
+	// +nullable
+	EndTime *metav1.Time `json:"endTime,omitempty"`
+
+	Outcome AmbassadorReleaseOutcome `json:"outcome"`
+
+	// A short description of the error (when the hook failed)
+	Error string `json:"error,omitempty"`
+}
+
+// AmbassadorSnapshotStatus defines a snapshot of the `getambassador.io` resources
+type AmbassadorSnapshotStatus struct {
+	// The name of the Secret (or ConfigMap) with the snapshot
+	Name      string                    `json:"name"`
+	Namespace string                    `json:"namespace"`
+	Storage   AmbassadorSnapshotStorage `json:"storage"`
+
+	// The Helm revision of the release deployed when the snapshot was taken
+	Revision int `json:"revision,omitempty"`
+	// The version of Ambassador deployed when the snapshot was taken
+	AppVersion string `json:"appVersion,omitempty"`
+
+	// The number of resources in the snapshot
+	Resources int `json:"resources"`
+
+	Time metav1.Time `json:"time,omitempty"`
+
+	// The last time the snapshot was restored by the operator
+	// +nullable
+	RestoreTime *metav1.Time `json:"restoreTime,omitempty"`
+}
+
+// AmbassadorChartVersion defines a version of the Ambassador Helm chart available in a repo
+type AmbassadorChartVersion struct {
+	Version    string `json:"version,omitempty"`
+	AppVersion string `json:"appVersion,omitempty"`
+}
+
+// AmbassadorUpgrade defines an upgrade planned for the Ambassador installation
+type AmbassadorUpgrade struct {
+	Version    string `json:"version,omitempty"`
+	AppVersion string `json:"appVersion,omitempty"`
+
+	// The earliest time the upgrade can be performed, considering the `updateWindow`
+	// and the update interval. It will be empty when the `updateWindow` does not
+	// allow any upgrade.
+	// +nullable
+	Time *metav1.Time `json:"time,omitempty"`
+}
+
+// AmbassadorHandledRequest defines the last on-demand requests handled by the operator. A request
+// has been handled when the value of its annotation is the same as the value here.
+type AmbassadorHandledRequest struct {
+	// The last value of the `reconcile.getambassador.io/requestedAt` annotation handled.
+	RequestedAt string `json:"requestedAt,omitempty"`
+
+	// The last value of the `reconcile.getambassador.io/forceUpgradeAt` annotation handled.
+	ForceUpgradeAt string `json:"forceUpgradeAt,omitempty"`
+
+	// The time the last request was handled.
+	// +nullable
+	Time *metav1.Time `json:"time,omitempty"`
+}
+
+// AmbassadorReleaseOutcome is the outcome of a release in the history
+type AmbassadorReleaseOutcome string
+
+// AmbassadorReleaseTrigger is the reason why a release in the history was performed
+type AmbassadorReleaseTrigger string
+
+// AmbassadorReleaseRecord defines an entry in the history of releases of an installation
+type AmbassadorReleaseRecord struct {
+	// The version of the Helm chart
+	Version string `json:"version,omitempty"`
+	// The version of Ambassador
+	AppVersion string `json:"appVersion,omitempty"`
+	Flavor     string `json:"flavor,omitempty"`
+
+	// The Helm revision of the release (empty when the release failed)
+	Revision int `json:"revision,omitempty"`
+
+	StartTime metav1.Time `json:"startTime,omitempty"`
+	// +nullable
+	EndTime *metav1.Time `json:"endTime,omitempty"`
+
+	Outcome AmbassadorReleaseOutcome `json:"outcome"`
+	Trigger AmbassadorReleaseTrigger `json:"trigger"`
+
+	// A short description of the error (when the release failed)
+	Error string `json:"error,omitempty"`
+}
+
+// MaxHistoryLength is the maximum number of entries kept in the status history
+const MaxHistoryLength = 10
+
+// maxHistoryErrorLength is the maximum length of the errors in the status history
+const maxHistoryErrorLength = 256
+
+const (
+	OutcomeSucceeded AmbassadorReleaseOutcome = "Succeeded"
+	OutcomeFailed    AmbassadorReleaseOutcome = "Failed"
+
+	// the installation was created or its spec was modified
+	TriggerSpecChange AmbassadorReleaseTrigger = "SpecChange"
+	// a new version was found in the Helm repo, and the update window was open
+	TriggerScheduledUpdate AmbassadorReleaseTrigger = "ScheduledUpdate"
+	// some resources of the release did not match the manifest and were repaired
+	TriggerDrift AmbassadorReleaseTrigger = "Drift"
+	// the installation was migrated from OSS to AES
+	TriggerMigration AmbassadorReleaseTrigger = "Migration"
+	// the release was rolled back after a failed verification
+	TriggerRollback AmbassadorReleaseTrigger = "Rollback"
+	// a release stuck in a pending status (ie, after the operator was killed during an upgrade) was rolled back
+	TriggerRecovery AmbassadorReleaseTrigger = "Recovery"
+)
+
 const (
 	ConditionInitialized    AmbInsConditionType = "Initialized"
 	ConditionDeployed       AmbInsConditionType = "Deployed"
 	ConditionReleaseFailed  AmbInsConditionType = "Failed"
 	ConditionIrreconcilable AmbInsConditionType = "Irreconcilable"
 
+	// ConditionReady summarizes the other conditions: it is `True` when Ambassador
+	// has been deployed and there are no failures
+	ConditionReady AmbInsConditionType = "Ready"
+
+	// ConditionPaused is `True` when the reconciliation of the installation has been paused
+	ConditionPaused AmbInsConditionType = "Paused"
+
+	// ConditionRendered is `True` when the manifests have been rendered (in the `render` mode)
+	ConditionRendered AmbInsConditionType = "Rendered"
+
 	StatusTrue    AmbInsConditionStatus = "True"
 	StatusFalse   AmbInsConditionStatus = "False"
 	StatusUnknown AmbInsConditionStatus = "Unknown"
 
-	ReasonInstallSuccessful   AmbInsConditionReason = "InstallSuccessful"
-	ReasonUpdateSuccessful    AmbInsConditionReason = "UpdateSuccessful"
-	ReasonUninstallSuccessful AmbInsConditionReason = "UninstallSuccessful"
-	ReasonInstallError        AmbInsConditionReason = "InstallError"
-	ReasonUpdateError         AmbInsConditionReason = "UpdateError"
-	ReasonDownloadError       AmbInsConditionReason = "DownloadError"
-	ReasonReconcileError      AmbInsConditionReason = "ReconcileError"
-	ReasonUninstallError      AmbInsConditionReason = "UninstallError"
-	ReasonParametersError     AmbInsConditionReason = "ParametersError"
-	ReasonDuplicateError      AmbInsConditionReason = "DuplicateError"
-	ReasonUpgradePrecondError AmbInsConditionReason = "UpgradePrecondError"
+	ReasonInstallSuccessful      AmbInsConditionReason = "InstallSuccessful"
+	ReasonUpdateSuccessful       AmbInsConditionReason = "UpdateSuccessful"
+	ReasonUninstallSuccessful    AmbInsConditionReason = "UninstallSuccessful"
+	ReasonInstallError           AmbInsConditionReason = "InstallError"
+	ReasonUpdateError            AmbInsConditionReason = "UpdateError"
+	ReasonDownloadError          AmbInsConditionReason = "DownloadError"
+	ReasonReconcileError         AmbInsConditionReason = "ReconcileError"
+	ReasonUninstallError         AmbInsConditionReason = "UninstallError"
+	ReasonParametersError        AmbInsConditionReason = "ParametersError"
+	ReasonDuplicateError         AmbInsConditionReason = "DuplicateError"
+	ReasonResourceConflictError  AmbInsConditionReason = "ResourceConflictError"
+	ReasonUpgradePrecondError    AmbInsConditionReason = "UpgradePrecondError"
+	ReasonReconciling            AmbInsConditionReason = "Reconciling"
+	ReasonPausedBySpec           AmbInsConditionReason = "PausedBySpec"
+	ReasonPausedByAnnotation     AmbInsConditionReason = "PausedByAnnotation"
+	ReasonPausedByOperator       AmbInsConditionReason = "PausedByOperator"
+	ReasonRenderSuccessful       AmbInsConditionReason = "RenderSuccessful"
+	ReasonRenderError            AmbInsConditionReason = "RenderError"
+	ReasonPatchError             AmbInsConditionReason = "PatchError"
+	ReasonVerificationError      AmbInsConditionReason = "VerificationError"
+	ReasonVerificationSuccessful AmbInsConditionReason = "VerificationSuccessful"
+	ReasonHookError              AmbInsConditionReason = "HookError"
+	ReasonSnapshotError          AmbInsConditionReason = "SnapshotError"
 )
 
 func (s *AmbassadorInstallationStatus) ToMap() (map[string]interface{}, error) {
@@ -179,10 +776,14 @@ func (s *AmbassadorInstallationStatus) ToMap() (map[string]interface{}, error) {
 	return out, nil
 }
 
-// LastCondition returns the last condition, optionally filtering by Status, Reason or Type
+// LastCondition returns the last condition, optionally filtering by Status, Reason or Type.
+// The `Ready` and `Paused` conditions are ignored unless they are explicitly requested in the filter.
 func (s *AmbassadorInstallationStatus) LastCondition(filter AmbInsCondition) AmbInsCondition {
 	var last AmbInsCondition
 	for _, c := range s.Conditions {
+		if (c.Type == ConditionReady || c.Type == ConditionPaused) && filter.Type != c.Type {
+			continue
+		}
 		if filter.Status != "" && c.Status != filter.Status {
 			continue
 		}
@@ -202,10 +803,24 @@ func (s *AmbassadorInstallationStatus) LastCondition(filter AmbInsCondition) Amb
 	return last
 }
 
+// FindCondition returns the condition with the given type (or nil if it is not present)
+func (s *AmbassadorInstallationStatus) FindCondition(conditionType AmbInsConditionType) *AmbInsCondition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
 // SetCondition sets a condition on the status object. If the condition already
 // exists, it will be replaced. SetCondition does not update the resource in
-// the cluster.
+// the cluster. Conditions without a reason get the type as reason (as
+// `metav1.Condition` requires a reason).
 func (s *AmbassadorInstallationStatus) SetCondition(condition AmbInsCondition) *AmbassadorInstallationStatus {
+	if len(condition.Reason) == 0 {
+		condition.Reason = AmbInsConditionReason(condition.Type)
+	}
 	now := metav1.Now()
 	for i := range s.Conditions {
 		if s.Conditions[i].Type == condition.Type {
@@ -226,6 +841,44 @@ func (s *AmbassadorInstallationStatus) SetCondition(condition AmbInsCondition) *
 	return s
 }
 
+// SetReady sets the `Ready` condition from the other conditions, as well as the
+// `observedGeneration` (of the status and of the `Ready` condition):
+//
+//   - a `Failed` or `Irreconcilable` condition makes the installation not ready,
+//     with the same reason and message.
+//   - a `Rendered` condition (in the `render` mode) or a `Deployed` condition makes the
+//     installation ready (or not ready when it is `False`).
+//   - otherwise, the readiness is `Unknown`: the installation is being reconciled.
+//
+// SetReady does not update the resource in the cluster.
+func (s *AmbassadorInstallationStatus) SetReady(generation int64) *AmbassadorInstallationStatus {
+	ready := AmbInsCondition{
+		Type:               ConditionReady,
+		Status:             StatusUnknown,
+		Reason:             ReasonReconciling,
+		ObservedGeneration: generation,
+	}
+
+	failed := s.FindCondition(ConditionReleaseFailed)
+	irreconcilable := s.FindCondition(ConditionIrreconcilable)
+	deployed := s.FindCondition(ConditionDeployed)
+	rendered := s.FindCondition(ConditionRendered)
+	switch {
+	case failed != nil && failed.Status == StatusTrue:
+		ready.Status, ready.Reason, ready.Message = StatusFalse, failed.Reason, failed.Message
+	case irreconcilable != nil:
+		// note: duplicate installations are marked as Irreconcilable=False
+		ready.Status, ready.Reason, ready.Message = StatusFalse, irreconcilable.Reason, irreconcilable.Message
+	case rendered != nil:
+		ready.Status, ready.Reason, ready.Message = rendered.Status, rendered.Reason, ""
+	case deployed != nil:
+		ready.Status, ready.Reason, ready.Message = deployed.Status, deployed.Reason, ""
+	}
+
+	s.ObservedGeneration = generation
+	return s.SetCondition(ready)
+}
+
 // TimestampCheck updates the timestamp of the last successful install/update
 func (s *AmbassadorInstallationStatus) TimestampCheck(now time.Time) *AmbassadorInstallationStatus {
 	s.LastCheckTime = metav1.NewTime(now)
@@ -246,6 +899,33 @@ func (s *AmbassadorInstallationStatus) RemoveCondition(conditionType AmbInsCondi
 	return s
 }
 
+// AddHistory adds a record to the head of the history, dropping the oldest entries
+// when there are more than MaxHistoryLength. When the newest entry in the history
+// is a repetition of the record (same trigger, outcome, versions and error), it
+// is just updated with the new end time. AddHistory does not update the resource
+// in the cluster.
+func (s *AmbassadorInstallationStatus) AddHistory(record AmbassadorReleaseRecord) *AmbassadorInstallationStatus {
+	if len(record.Error) > maxHistoryErrorLength {
+		record.Error = record.Error[:maxHistoryErrorLength-3] + "..."
+	}
+
+	if len(s.History) > 0 {
+		last := &s.History[0]
+		if last.Trigger == record.Trigger && last.Outcome == record.Outcome &&
+			last.Version == record.Version && last.AppVersion == record.AppVersion &&
+			last.Error == record.Error {
+			last.EndTime = record.EndTime
+			return s
+		}
+	}
+
+	s.History = append([]AmbassadorReleaseRecord{record}, s.History...)
+	if len(s.History) > MaxHistoryLength {
+		s.History = s.History[:MaxHistoryLength]
+	}
+	return s
+}
+
 // StatusFor safely returns a typed status block from a custom resource.
 func StatusFor(cr *unstructured.Unstructured) *AmbassadorInstallationStatus {
 	switch s := cr.Object["status"].(type) {
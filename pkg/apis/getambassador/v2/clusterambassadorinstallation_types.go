@@ -0,0 +1,58 @@
+package v2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterAmbassadorInstallation is the Schema for the clusterambassadorinstallations API.
+// It is the cluster-scoped variant of the AmbassadorInstallation: Ambassador is installed
+// in the `targetNamespace` (`ambassador` by default), and all the resources created,
+// including the cluster-scoped ones, are owned by the ClusterAmbassadorInstallation.
+//
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=clusterambassadorinstallations,scope=Cluster
+// +kubebuilder:printcolumn:name="VERSION",type=string,JSONPath=`.spec.version`
+// +kubebuilder:printcolumn:name="TARGET-NAMESPACE",type="string",JSONPath=".status.deployedRelease.namespace",priority=0,description="Namespace where Ambassador is installed"
+// +kubebuilder:printcolumn:name="AMBASSADOR-ID",type="string",JSONPath=".spec.ambassadorID",priority=1,description="The ambassador_id of this installation"
+// +kubebuilder:printcolumn:name="UPDATE-WINDOW",type=integer,JSONPath=`.spec.updateWindow`
+// +kubebuilder:printcolumn:name="LAST-CHECK",type="string",JSONPath=".status.lastCheckTime",priority=0,description="Last time checked"
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status",priority=0,description="Indicates if the installation is ready"
+// +kubebuilder:printcolumn:name="DEPLOYED",type="string",JSONPath=".status.conditions[?(@.type=='Deployed')].status",priority=0,description="Indicates if deployment has completed"
+// +kubebuilder:printcolumn:name="REASON",type="string",JSONPath=".status.conditions[?(@.type=='Deployed')].reason",priority=1,description="Reason for deployment completed"
+// +kubebuilder:printcolumn:name="MESSAGE",type="string",JSONPath=".status.conditions[?(@.type=='Deployed')].message",priority=1,description="Message for deployment completed"
+// +kubebuilder:printcolumn:name="DEPLOYED-VERSION",type="string",JSONPath=".status.deployedRelease.appVersion",priority=0,description="Deployed version of Ambassador"
+// +kubebuilder:printcolumn:name="DEPLOYED-CHART",type="string",JSONPath=".status.deployedRelease.version",priority=1,description="Deployed version of the Helm chart"
+// +kubebuilder:printcolumn:name="DEPLOYED-FLAVOR",type="string",JSONPath=".status.deployedRelease.flavor",priority=0,description="Deployed flavor of Ambassador (OSS or AES)"
+// +kubebuilder:printcolumn:name="NEXT-VERSION",type="string",JSONPath=".status.nextUpgrade.appVersion",priority=1,description="Next version of Ambassador that will be deployed"
+type ClusterAmbassadorInstallation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AmbassadorInstallationSpec   `json:"spec,omitempty"`
+	Status AmbassadorInstallationStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterAmbassadorInstallationList contains a list of ClusterAmbassadorInstallation
+type ClusterAmbassadorInstallationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterAmbassadorInstallation `json:"items"`
+}
+
+// GetSpec returns the spec of the ClusterAmbassadorInstallation
+func (in *ClusterAmbassadorInstallation) GetSpec() *AmbassadorInstallationSpec {
+	return &in.Spec
+}
+
+// GetStatus returns the status of the ClusterAmbassadorInstallation
+func (in *ClusterAmbassadorInstallation) GetStatus() *AmbassadorInstallationStatus {
+	return &in.Status
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterAmbassadorInstallation{}, &ClusterAmbassadorInstallationList{})
+}
@@ -5,6 +5,7 @@
 package v2
 
 import (
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -25,12 +26,141 @@ func (in *AmbInsCondition) DeepCopy() *AmbInsCondition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorChartVersion) DeepCopyInto(out *AmbassadorChartVersion) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorChartVersion.
+func (in *AmbassadorChartVersion) DeepCopy() *AmbassadorChartVersion {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorChartVersion)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorCheckResult) DeepCopyInto(out *AmbassadorCheckResult) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorCheckResult.
+func (in *AmbassadorCheckResult) DeepCopy() *AmbassadorCheckResult {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorCheckResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorHandledRequest) DeepCopyInto(out *AmbassadorHandledRequest) {
+	*out = *in
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorHandledRequest.
+func (in *AmbassadorHandledRequest) DeepCopy() *AmbassadorHandledRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorHandledRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorHook) DeepCopyInto(out *AmbassadorHook) {
+	*out = *in
+	if in.Template != nil {
+		in, out := &in.Template, &out.Template
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorHook.
+func (in *AmbassadorHook) DeepCopy() *AmbassadorHook {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorHookStatus) DeepCopyInto(out *AmbassadorHookStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.EndTime != nil {
+		in, out := &in.EndTime, &out.EndTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorHookStatus.
+func (in *AmbassadorHookStatus) DeepCopy() *AmbassadorHookStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorHookStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorHooks) DeepCopyInto(out *AmbassadorHooks) {
+	*out = *in
+	if in.PreUpgrade != nil {
+		in, out := &in.PreUpgrade, &out.PreUpgrade
+		*out = make([]AmbassadorHook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PostUpgrade != nil {
+		in, out := &in.PostUpgrade, &out.PostUpgrade
+		*out = make([]AmbassadorHook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorHooks.
+func (in *AmbassadorHooks) DeepCopy() *AmbassadorHooks {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorHooks)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AmbassadorInstallation) DeepCopyInto(out *AmbassadorInstallation) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 	return
 }
@@ -89,6 +219,43 @@ func (in *AmbassadorInstallationList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AmbassadorInstallationSpec) DeepCopyInto(out *AmbassadorInstallationSpec) {
 	*out = *in
+	if in.Render != nil {
+		in, out := &in.Render, &out.Render
+		*out = new(AmbassadorRenderOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TargetNamespaceOptions != nil {
+		in, out := &in.TargetNamespaceOptions, &out.TargetNamespaceOptions
+		*out = new(AmbassadorNamespaceOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HelmValues != nil {
+		in, out := &in.HelmValues, &out.HelmValues
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Patches != nil {
+		in, out := &in.Patches, &out.Patches
+		*out = make([]AmbassadorPatch, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Verification != nil {
+		in, out := &in.Verification, &out.Verification
+		*out = new(AmbassadorVerification)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = new(AmbassadorHooks)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = new(AmbassadorSnapshots)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -117,7 +284,53 @@ func (in *AmbassadorInstallationStatus) DeepCopyInto(out *AmbassadorInstallation
 		*out = new(AmbassadorRelease)
 		**out = **in
 	}
+	if in.RenderedRelease != nil {
+		in, out := &in.RenderedRelease, &out.RenderedRelease
+		*out = new(AmbassadorRenderedRelease)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Verification != nil {
+		in, out := &in.Verification, &out.Verification
+		*out = new(AmbassadorVerificationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = make([]AmbassadorHookStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = make([]AmbassadorSnapshotStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	in.LastCheckTime.DeepCopyInto(&out.LastCheckTime)
+	if in.AvailableVersions != nil {
+		in, out := &in.AvailableVersions, &out.AvailableVersions
+		*out = make([]AmbassadorChartVersion, len(*in))
+		copy(*out, *in)
+	}
+	if in.NextUpgrade != nil {
+		in, out := &in.NextUpgrade, &out.NextUpgrade
+		*out = new(AmbassadorUpgrade)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastHandledRequest != nil {
+		in, out := &in.LastHandledRequest, &out.LastHandledRequest
+		*out = new(AmbassadorHandledRequest)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]AmbassadorReleaseRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -131,6 +344,89 @@ func (in *AmbassadorInstallationStatus) DeepCopy() *AmbassadorInstallationStatus
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorNamespaceOptions) DeepCopyInto(out *AmbassadorNamespaceOptions) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorNamespaceOptions.
+func (in *AmbassadorNamespaceOptions) DeepCopy() *AmbassadorNamespaceOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorNamespaceOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorPatch) DeepCopyInto(out *AmbassadorPatch) {
+	*out = *in
+	if in.Target != nil {
+		in, out := &in.Target, &out.Target
+		*out = new(AmbassadorPatchTarget)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorPatch.
+func (in *AmbassadorPatch) DeepCopy() *AmbassadorPatch {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorPatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorPatchTarget) DeepCopyInto(out *AmbassadorPatchTarget) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorPatchTarget.
+func (in *AmbassadorPatchTarget) DeepCopy() *AmbassadorPatchTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorPatchTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorProbe) DeepCopyInto(out *AmbassadorProbe) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorProbe.
+func (in *AmbassadorProbe) DeepCopy() *AmbassadorProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AmbassadorRelease) DeepCopyInto(out *AmbassadorRelease) {
 	*out = *in
@@ -146,3 +442,260 @@ func (in *AmbassadorRelease) DeepCopy() *AmbassadorRelease {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorReleaseRecord) DeepCopyInto(out *AmbassadorReleaseRecord) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.EndTime != nil {
+		in, out := &in.EndTime, &out.EndTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorReleaseRecord.
+func (in *AmbassadorReleaseRecord) DeepCopy() *AmbassadorReleaseRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorReleaseRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorRenderGit) DeepCopyInto(out *AmbassadorRenderGit) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorRenderGit.
+func (in *AmbassadorRenderGit) DeepCopy() *AmbassadorRenderGit {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorRenderGit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorRenderOptions) DeepCopyInto(out *AmbassadorRenderOptions) {
+	*out = *in
+	if in.Git != nil {
+		in, out := &in.Git, &out.Git
+		*out = new(AmbassadorRenderGit)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorRenderOptions.
+func (in *AmbassadorRenderOptions) DeepCopy() *AmbassadorRenderOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorRenderOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorRenderedRelease) DeepCopyInto(out *AmbassadorRenderedRelease) {
+	*out = *in
+	if in.Locations != nil {
+		in, out := &in.Locations, &out.Locations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorRenderedRelease.
+func (in *AmbassadorRenderedRelease) DeepCopy() *AmbassadorRenderedRelease {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorRenderedRelease)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorSnapshotStatus) DeepCopyInto(out *AmbassadorSnapshotStatus) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.RestoreTime != nil {
+		in, out := &in.RestoreTime, &out.RestoreTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorSnapshotStatus.
+func (in *AmbassadorSnapshotStatus) DeepCopy() *AmbassadorSnapshotStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorSnapshotStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorSnapshots) DeepCopyInto(out *AmbassadorSnapshots) {
+	*out = *in
+	if in.Kinds != nil {
+		in, out := &in.Kinds, &out.Kinds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorSnapshots.
+func (in *AmbassadorSnapshots) DeepCopy() *AmbassadorSnapshots {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorSnapshots)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorUpgrade) DeepCopyInto(out *AmbassadorUpgrade) {
+	*out = *in
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorUpgrade.
+func (in *AmbassadorUpgrade) DeepCopy() *AmbassadorUpgrade {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorUpgrade)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorVerification) DeepCopyInto(out *AmbassadorVerification) {
+	*out = *in
+	if in.Probes != nil {
+		in, out := &in.Probes, &out.Probes
+		*out = make([]AmbassadorProbe, len(*in))
+		copy(*out, *in)
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorVerification.
+func (in *AmbassadorVerification) DeepCopy() *AmbassadorVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AmbassadorVerificationStatus) DeepCopyInto(out *AmbassadorVerificationStatus) {
+	*out = *in
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+	if in.Checks != nil {
+		in, out := &in.Checks, &out.Checks
+		*out = make([]AmbassadorCheckResult, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AmbassadorVerificationStatus.
+func (in *AmbassadorVerificationStatus) DeepCopy() *AmbassadorVerificationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AmbassadorVerificationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterAmbassadorInstallation) DeepCopyInto(out *ClusterAmbassadorInstallation) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterAmbassadorInstallation.
+func (in *ClusterAmbassadorInstallation) DeepCopy() *ClusterAmbassadorInstallation {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterAmbassadorInstallation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterAmbassadorInstallation) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterAmbassadorInstallationList) DeepCopyInto(out *ClusterAmbassadorInstallationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterAmbassadorInstallation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterAmbassadorInstallationList.
+func (in *ClusterAmbassadorInstallationList) DeepCopy() *ClusterAmbassadorInstallationList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterAmbassadorInstallationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterAmbassadorInstallationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package v3
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	v2 "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+)
+
+// paths in the `v2` `helmValues` for the typed fields in this version
+var (
+	replicasPath           = []string{"replicaCount"}
+	resourcesPath          = []string{"resources"}
+	serviceTypePath        = []string{"service", "type"}
+	serviceAnnotationsPath = []string{"service", "annotations"}
+	licenseKeyPath         = []string{"licenseKey", "value"}
+	licenseSecretNamePath  = []string{"licenseKey", "secretName"}
+)
+
+// ConvertTo converts this AmbassadorInstallation to the hub version (v2),
+// moving the typed fields to the `helmValues`
+func (src *AmbassadorInstallation) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v2.AmbassadorInstallation)
+	if !ok {
+		return fmt.Errorf("unexpected hub type %T", dstRaw)
+	}
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = v2.AmbassadorInstallationSpec{
+		Version:                src.Spec.Version,
+		ChartVersion:           src.Spec.ChartVersion,
+		BaseImage:              imageRef(src.Spec.Image),
+		ImageRegistryMirror:    src.Spec.ImageRegistryMirror,
+		HelmRepo:               src.Spec.HelmRepo,
+		LogLevel:               src.Spec.LogLevel,
+		InstallOSS:             src.Spec.Flavor == FlavorOSS,
+		Paused:                 src.Spec.Paused,
+		Mode:                   src.Spec.Mode,
+		Render:                 src.Spec.Render,
+		Patches:                src.Spec.Patches,
+		Verification:           src.Spec.Verification,
+		Hooks:                  src.Spec.Hooks,
+		Snapshots:              src.Spec.Snapshots,
+		ReleaseName:            src.Spec.ReleaseName,
+		AmbassadorID:           src.Spec.AmbassadorID,
+		TargetNamespace:        src.Spec.TargetNamespace,
+		TargetNamespaceOptions: src.Spec.TargetNamespaceOptions,
+	}
+	if src.Spec.UpdatePolicy != nil {
+		dst.Spec.UpdateWindow = src.Spec.UpdatePolicy.Window
+	}
+
+	values, err := rawToMap(src.Spec.HelmValues)
+	if err != nil {
+		return err
+	}
+	if src.Spec.Replicas != nil {
+		if err := unstructured.SetNestedField(values, int64(*src.Spec.Replicas), replicasPath...); err != nil {
+			return err
+		}
+	}
+	if src.Spec.Resources != nil {
+		resources, err := runtime.DefaultUnstructuredConverter.ToUnstructured(src.Spec.Resources)
+		if err != nil {
+			return err
+		}
+		if err := unstructured.SetNestedMap(values, resources, resourcesPath...); err != nil {
+			return err
+		}
+	}
+	if s := src.Spec.Service; s != nil {
+		if len(s.Type) > 0 {
+			if err := unstructured.SetNestedField(values, string(s.Type), serviceTypePath...); err != nil {
+				return err
+			}
+		}
+		if len(s.Annotations) > 0 {
+			if err := unstructured.SetNestedStringMap(values, s.Annotations, serviceAnnotationsPath...); err != nil {
+				return err
+			}
+		}
+	}
+	if l := src.Spec.License; l != nil {
+		if len(l.Key) > 0 {
+			if err := unstructured.SetNestedField(values, l.Key, licenseKeyPath...); err != nil {
+				return err
+			}
+		}
+		if len(l.SecretName) > 0 {
+			if err := unstructured.SetNestedField(values, l.SecretName, licenseSecretNamePath...); err != nil {
+				return err
+			}
+		}
+	}
+	if dst.Spec.HelmValues, err = mapToRaw(values); err != nil {
+		return err
+	}
+
+	dst.Status = src.Status
+	return nil
+}
+
+// ConvertFrom converts from the hub version (v2) to this version, moving
+// the `helmValues` that have a typed field to that field
+func (dst *AmbassadorInstallation) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v2.AmbassadorInstallation)
+	if !ok {
+		return fmt.Errorf("unexpected hub type %T", srcRaw)
+	}
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = AmbassadorInstallationSpec{
+		Version:                src.Spec.Version,
+		ChartVersion:           src.Spec.ChartVersion,
+		HelmRepo:               src.Spec.HelmRepo,
+		Flavor:                 FlavorAES,
+		Image:                  parseImageRef(src.Spec.BaseImage),
+		ImageRegistryMirror:    src.Spec.ImageRegistryMirror,
+		LogLevel:               src.Spec.LogLevel,
+		Paused:                 src.Spec.Paused,
+		Mode:                   src.Spec.Mode,
+		Render:                 src.Spec.Render,
+		Patches:                src.Spec.Patches,
+		Verification:           src.Spec.Verification,
+		Hooks:                  src.Spec.Hooks,
+		Snapshots:              src.Spec.Snapshots,
+		ReleaseName:            src.Spec.ReleaseName,
+		AmbassadorID:           src.Spec.AmbassadorID,
+		TargetNamespace:        src.Spec.TargetNamespace,
+		TargetNamespaceOptions: src.Spec.TargetNamespaceOptions,
+	}
+	if src.Spec.InstallOSS {
+		dst.Spec.Flavor = FlavorOSS
+	}
+	if len(src.Spec.UpdateWindow) > 0 {
+		dst.Spec.UpdatePolicy = &AmbassadorUpdatePolicy{Window: src.Spec.UpdateWindow}
+	}
+
+	values, err := rawToMap(src.Spec.HelmValues)
+	if err != nil {
+		return err
+	}
+
+	// values that cannot be converted to the typed field are kept in the `helmValues`
+	if replicas, ok := nestedInt32(values, replicasPath...); ok {
+		dst.Spec.Replicas = &replicas
+		removeNestedField(values, replicasPath...)
+	}
+	if m, found, err := unstructured.NestedMap(values, resourcesPath...); err == nil && found {
+		resources := &corev1.ResourceRequirements{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, resources); err == nil {
+			dst.Spec.Resources = resources
+			removeNestedField(values, resourcesPath...)
+		}
+	}
+	if t, found, err := unstructured.NestedString(values, serviceTypePath...); err == nil && found {
+		dst.Spec.Service = &AmbassadorService{Type: corev1.ServiceType(t)}
+		removeNestedField(values, serviceTypePath...)
+	}
+	if a, found, err := unstructured.NestedStringMap(values, serviceAnnotationsPath...); err == nil && found {
+		if dst.Spec.Service == nil {
+			dst.Spec.Service = &AmbassadorService{}
+		}
+		dst.Spec.Service.Annotations = a
+		removeNestedField(values, serviceAnnotationsPath...)
+	}
+	if k, found, err := unstructured.NestedString(values, licenseKeyPath...); err == nil && found {
+		dst.Spec.License = &AmbassadorLicense{Key: k}
+		removeNestedField(values, licenseKeyPath...)
+	}
+	if n, found, err := unstructured.NestedString(values, licenseSecretNamePath...); err == nil && found {
+		if dst.Spec.License == nil {
+			dst.Spec.License = &AmbassadorLicense{}
+		}
+		dst.Spec.License.SecretName = n
+		removeNestedField(values, licenseSecretNamePath...)
+	}
+	if dst.Spec.HelmValues, err = mapToRaw(values); err != nil {
+		return err
+	}
+
+	dst.Status = src.Status
+	return nil
+}
+
+// imageRef returns the image reference (ie, `repo:tag` or `repo@digest`) for an image
+func imageRef(image *AmbassadorImage) string {
+	if image == nil || len(image.Repository) == 0 {
+		return ""
+	}
+	res := image.Repository
+	if len(image.Tag) > 0 {
+		res += ":" + image.Tag
+	}
+	if len(image.Digest) > 0 {
+		res += "@" + image.Digest
+	}
+	return res
+}
+
+// parseImageRef parses an image reference, like `quay.io/datawire/aes:1.0` or
+// `localhost:5000/aes@sha256:...`, returning nil for an empty reference
+func parseImageRef(s string) *AmbassadorImage {
+	if len(s) == 0 {
+		return nil
+	}
+	image := &AmbassadorImage{}
+	if i := strings.Index(s, "@"); i >= 0 {
+		s, image.Digest = s[:i], s[i+1:]
+	}
+	// the tag is after the last `:`, as long as it is not a registry port
+	if i := strings.LastIndex(s, ":"); i >= 0 && !strings.Contains(s[i+1:], "/") {
+		s, image.Tag = s[:i], s[i+1:]
+	}
+	image.Repository = s
+	return image
+}
+
+// rawToMap decodes some (optional) Helm values
+func rawToMap(raw *runtime.RawExtension) (map[string]interface{}, error) {
+	res := map[string]interface{}{}
+	if raw == nil {
+		return res, nil
+	}
+	data := raw.Raw
+	if len(data) == 0 && raw.Object != nil {
+		var err error
+		if data, err = json.Marshal(raw.Object); err != nil {
+			return nil, err
+		}
+	}
+	if len(data) == 0 {
+		return res, nil
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("could not decode helmValues: %w", err)
+	}
+	if res == nil {
+		res = map[string]interface{}{}
+	}
+	return res, nil
+}
+
+// mapToRaw encodes some Helm values, returning nil when there are no values
+func mapToRaw(values map[string]interface{}) (*runtime.RawExtension, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	return &runtime.RawExtension{Raw: data}, nil
+}
+
+// nestedInt32 returns an integer value in some (decoded) Helm values
+func nestedInt32(values map[string]interface{}, fields ...string) (int32, bool) {
+	v, found, err := unstructured.NestedFieldNoCopy(values, fields...)
+	if err != nil || !found {
+		return 0, false
+	}
+	switch n := v.(type) {
+	case int64:
+		return int32(n), true
+	case float64:
+		if n == float64(int32(n)) {
+			return int32(n), true
+		}
+	}
+	return 0, false
+}
+
+// removeNestedField removes a field from some Helm values, as well as the parents
+// that become empty after the removal
+func removeNestedField(values map[string]interface{}, fields ...string) {
+	unstructured.RemoveNestedField(values, fields...)
+	for i := len(fields) - 1; i > 0; i-- {
+		parent, found, err := unstructured.NestedMap(values, fields[:i]...)
+		if err != nil || !found || len(parent) > 0 {
+			return
+		}
+		unstructured.RemoveNestedField(values, fields[:i]...)
+	}
+}
@@ -0,0 +1,113 @@
+package v3
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	v2 "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+)
+
+func TestConvertFromV2(t *testing.T) {
+	src := &v2.AmbassadorInstallation{}
+	src.SetName("ambassador")
+	src.Spec = v2.AmbassadorInstallationSpec{
+		Version:      "1.*",
+		InstallOSS:   true,
+		BaseImage:    "localhost:5000/ambassador:1.5.0",
+		UpdateWindow: "* 0-6 * * SUN",
+		HelmValues: &runtime.RawExtension{Raw: []byte(`{
+			"replicaCount": 2,
+			"resources": {"limits": {"cpu": "1"}},
+			"service": {"type": "NodePort", "ports": [{"port": 80}]},
+			"licenseKey": {"secretName": "license"},
+			"env.AMBASSADOR_ID": "internal"
+		}`)},
+	}
+
+	dst := &AmbassadorInstallation{}
+	if err := dst.ConvertFrom(src); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	replicas := int32(2)
+	expected := AmbassadorInstallationSpec{
+		Version:      "1.*",
+		Flavor:       FlavorOSS,
+		Image:        &AmbassadorImage{Repository: "localhost:5000/ambassador", Tag: "1.5.0"},
+		Replicas:     &replicas,
+		Resources:    &corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}},
+		Service:      &AmbassadorService{Type: corev1.ServiceTypeNodePort},
+		License:      &AmbassadorLicense{SecretName: "license"},
+		UpdatePolicy: &AmbassadorUpdatePolicy{Window: "* 0-6 * * SUN"},
+	}
+	values, err := rawToMap(dst.Spec.HelmValues)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dst.Spec.HelmValues = nil
+	if !reflect.DeepEqual(dst.Spec, expected) {
+		t.Errorf("converted spec\n%+v\nexpected\n%+v", dst.Spec, expected)
+	}
+
+	// values without a typed field are kept in the helmValues
+	expectedValues := map[string]interface{}{
+		"service":           map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": float64(80)}}},
+		"env.AMBASSADOR_ID": "internal",
+	}
+	if !reflect.DeepEqual(values, expectedValues) {
+		t.Errorf("helmValues %v, expected %v", values, expectedValues)
+	}
+}
+
+func TestConversionRoundTrip(t *testing.T) {
+	replicas := int32(3)
+	src := &AmbassadorInstallation{}
+	src.SetName("ambassador")
+	src.Spec = AmbassadorInstallationSpec{
+		Version:      "1.5.*",
+		Flavor:       FlavorAES,
+		Image:        &AmbassadorImage{Repository: "quay.io/datawire/aes", Digest: "sha256:1234"},
+		Replicas:     &replicas,
+		Service:      &AmbassadorService{Type: corev1.ServiceTypeLoadBalancer, Annotations: map[string]string{"a": "b"}},
+		License:      &AmbassadorLicense{Key: "key"},
+		AmbassadorID: "internal",
+		Paused:       true,
+		Mode:         v2.ModeRender,
+		Render:       &v2.AmbassadorRenderOptions{Git: &v2.AmbassadorRenderGit{URL: "https://example.com/repo.git"}},
+		Patches:      []v2.AmbassadorPatch{{Type: v2.PatchJSON6902, Patch: "[]"}},
+		Verification: &v2.AmbassadorVerification{HelmTests: true, Probes: []v2.AmbassadorProbe{{Path: "/backend/"}}},
+		Hooks:        &v2.AmbassadorHooks{PreUpgrade: []v2.AmbassadorHook{{Name: "backup", Template: &runtime.RawExtension{Raw: []byte(`{"spec":{}}`)}}}},
+		Snapshots:    &v2.AmbassadorSnapshots{Storage: v2.SnapshotStorageConfigMap, Keep: 5},
+		HelmValues:   &runtime.RawExtension{Raw: []byte(`{"daemonSet":true}`)},
+	}
+	src.Status.DeployedRelease = &v2.AmbassadorRelease{Name: "ambassador", Flavor: "AES"}
+
+	hub := &v2.AmbassadorInstallation{}
+	if err := src.ConvertTo(hub); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hub.Spec.BaseImage != "quay.io/datawire/aes@sha256:1234" || hub.Spec.InstallOSS {
+		t.Errorf("unexpected v2 spec: %+v", hub.Spec)
+	}
+
+	dst := &AmbassadorInstallation{}
+	if err := dst.ConvertFrom(hub); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(dst, src) {
+		t.Errorf("round trip returned\n%+v\nexpected\n%+v", dst, src)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	o := &AmbassadorInstallation{}
+	o.Spec.Service = &AmbassadorService{}
+	o.Default()
+	if o.Spec.Version != DefaultVersion || o.Spec.Flavor != DefaultFlavor || o.Spec.Service.Type != DefaultServiceType {
+		t.Errorf("unexpected defaults: %+v", o.Spec)
+	}
+}
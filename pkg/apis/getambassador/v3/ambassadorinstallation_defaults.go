@@ -0,0 +1,29 @@
+package v3
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// DefaultVersion is the version used when no `version` is provided: the latest version available
+	DefaultVersion = "*"
+
+	// DefaultFlavor is the flavor used when no `flavor` is provided
+	DefaultFlavor = FlavorAES
+
+	// DefaultServiceType is the type of the Ambassador `Service` when no `service.type` is provided
+	DefaultServiceType = corev1.ServiceTypeLoadBalancer
+)
+
+// Default fills in the defaults for the fields that have not been provided
+func (in *AmbassadorInstallation) Default() {
+	if len(in.Spec.Version) == 0 {
+		in.Spec.Version = DefaultVersion
+	}
+	if len(in.Spec.Flavor) == 0 {
+		in.Spec.Flavor = DefaultFlavor
+	}
+	if in.Spec.Service != nil && len(in.Spec.Service.Type) == 0 {
+		in.Spec.Service.Type = DefaultServiceType
+	}
+}
@@ -0,0 +1,209 @@
+package v3
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	v2 "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+)
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+// AmbassadorFlavor is the flavor of Ambassador installed
+type AmbassadorFlavor string
+
+const (
+	// FlavorAES is the Ambassador Edge Stack
+	FlavorAES AmbassadorFlavor = "AES"
+
+	// FlavorOSS is the Ambassador API Gateway
+	FlavorOSS AmbassadorFlavor = "OSS"
+)
+
+// AmbassadorInstallationSpec defines the desired state of AmbassadorInstallation
+type AmbassadorInstallationSpec struct {
+	// The version of Ambassador, using SemVer. See the `version` in the
+	// `v2` API for the syntax accepted.
+	Version string `json:"version,omitempty"`
+
+	// An (optional) constraint for the version of the Helm chart, using the same
+	// SemVer syntax as `version`.
+	ChartVersion string `json:"chartVersion,omitempty"`
+
+	// An (optional) Helm repository.
+	HelmRepo string `json:"helmRepo,omitempty"`
+
+	// The flavor of Ambassador installed: the Ambassador Edge Stack (`AES`)
+	// or the Ambassador API Gateway (`OSS`).
+	// +kubebuilder:validation:Enum=AES;OSS
+	Flavor AmbassadorFlavor `json:"flavor,omitempty"`
+
+	// An (optional) image to use instead of the image specified in the Helm chart.
+	// +nullable
+	Image *AmbassadorImage `json:"image,omitempty"`
+
+	// An (optional) registry (with an optional path) where all the images rendered
+	// by the Helm chart will be pulled from (ie, `registry.internal:5000/mirror`).
+	ImageRegistryMirror string `json:"imageRegistryMirror,omitempty"`
+
+	// An (optional) number of replicas for the Ambassador `Deployment`.
+	// +kubebuilder:validation:Minimum=0
+	// +nullable
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Some (optional) compute resources required by the Ambassador containers.
+	// +nullable
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Some (optional) options for the Ambassador `Service`.
+	// +nullable
+	Service *AmbassadorService `json:"service,omitempty"`
+
+	// An (optional) log level: debug, info...
+	// +kubebuilder:validation:Enum=info;debug;warn;warning;error;critical;fatal
+	LogLevel string `json:"logLevel,omitempty"`
+
+	// The (optional) license for the Ambassador Edge Stack.
+	// +nullable
+	License *AmbassadorLicense `json:"license,omitempty"`
+
+	// The (optional) policy for updating Ambassador.
+	// +nullable
+	UpdatePolicy *AmbassadorUpdatePolicy `json:"updatePolicy,omitempty"`
+
+	// Pauses the reconciliation of this installation (see `paused` in the `v2` API).
+	Paused bool `json:"paused,omitempty"`
+
+	// The (optional) mode of the installation (see `mode` in the `v2` API).
+	// +kubebuilder:validation:Enum=install;render
+	Mode v2.AmbassadorInstallationMode `json:"mode,omitempty"`
+
+	// Some (optional) options for the `render` mode.
+	// +nullable
+	Render *v2.AmbassadorRenderOptions `json:"render,omitempty"`
+
+	// An (optional) name for the Helm release. It defaults to the name of the
+	// `AmbassadorInstallation`.
+	ReleaseName string `json:"releaseName,omitempty"`
+
+	// An (optional) `ambassador_id` for this installation.
+	AmbassadorID string `json:"ambassadorID,omitempty"`
+
+	// An (optional) namespace where Ambassador will be installed. It defaults to
+	// the namespace of the `AmbassadorInstallation`.
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+
+	// Some (optional) options for the `targetNamespace`.
+	// +nullable
+	TargetNamespaceOptions *v2.AmbassadorNamespaceOptions `json:"targetNamespaceOptions,omitempty"`
+
+	// Some (optional) extra values for the Helm chart, for everything that
+	// is not covered by the other fields.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +nullable
+	HelmValues *runtime.RawExtension `json:"helmValues,omitempty"`
+
+	// Some (optional) patches applied to the resources rendered by the Helm chart
+	// (see `patches` in the `v2` API).
+	// +optional
+	Patches []v2.AmbassadorPatch `json:"patches,omitempty"`
+
+	// Some (optional) checks performed after installing or upgrading Ambassador
+	// (see `verification` in the `v2` API).
+	// +nullable
+	Verification *v2.AmbassadorVerification `json:"verification,omitempty"`
+
+	// Some (optional) Jobs run before and after the upgrades (see `hooks` in the `v2` API).
+	// +nullable
+	Hooks *v2.AmbassadorHooks `json:"hooks,omitempty"`
+
+	// Snapshots of the `getambassador.io` resources taken before the upgrades
+	// (see `snapshots` in the `v2` API).
+	// +nullable
+	Snapshots *v2.AmbassadorSnapshots `json:"snapshots,omitempty"`
+}
+
+// AmbassadorImage defines the image used for Ambassador
+type AmbassadorImage struct {
+	// The image repository (ie, `docker.io/datawire/aes`).
+	Repository string `json:"repository,omitempty"`
+
+	// The image tag (ie, `1.5.0`).
+	Tag string `json:"tag,omitempty"`
+
+	// An (optional) image digest (ie, `sha256:...`). It takes precedence over the `tag`.
+	Digest string `json:"digest,omitempty"`
+}
+
+// AmbassadorService defines some options for the Ambassador Service
+type AmbassadorService struct {
+	// The type of the `Service`.
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	Type corev1.ServiceType `json:"type,omitempty"`
+
+	// Annotations added to the `Service`.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// AmbassadorLicense defines the license used by the Ambassador Edge Stack
+type AmbassadorLicense struct {
+	// The license key.
+	Key string `json:"key,omitempty"`
+
+	// The name of the `Secret` with the license key.
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// AmbassadorUpdatePolicy defines when Ambassador can be updated
+type AmbassadorUpdatePolicy struct {
+	// The update window, with the same syntax as the `updateWindow` in the `v2` API.
+	Window string `json:"window,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AmbassadorInstallation is the Schema for the ambassadorinstallations API.
+// This version uses typed fields for the most common settings, and it is converted
+// to/from the `v2` version (the storage version) by the operator's conversion webhook.
+// It is not served until the conversion webhook is configured in the CRD.
+//
+// +kubebuilder:unservedversion
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=ambassadorinstallations,scope=Namespaced
+// +kubebuilder:printcolumn:name="VERSION",type=string,JSONPath=`.spec.version`
+// +kubebuilder:printcolumn:name="FLAVOR",type="string",JSONPath=".spec.flavor",priority=1,description="The flavor of Ambassador requested (OSS or AES)"
+// +kubebuilder:printcolumn:name="AMBASSADOR-ID",type="string",JSONPath=".spec.ambassadorID",priority=1,description="The ambassador_id of this installation"
+// +kubebuilder:printcolumn:name="UPDATE-WINDOW",type=string,JSONPath=`.spec.updatePolicy.window`
+// +kubebuilder:printcolumn:name="LAST-CHECK",type="string",JSONPath=".status.lastCheckTime",priority=0,description="Last time checked"
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status",priority=0,description="Indicates if the installation is ready"
+// +kubebuilder:printcolumn:name="DEPLOYED",type="string",JSONPath=".status.conditions[?(@.type=='Deployed')].status",priority=0,description="Indicates if deployment has completed"
+// +kubebuilder:printcolumn:name="REASON",type="string",JSONPath=".status.conditions[?(@.type=='Deployed')].reason",priority=1,description="Reason for deployment completed"
+// +kubebuilder:printcolumn:name="MESSAGE",type="string",JSONPath=".status.conditions[?(@.type=='Deployed')].message",priority=1,description="Message for deployment completed"
+// +kubebuilder:printcolumn:name="DEPLOYED-VERSION",type="string",JSONPath=".status.deployedRelease.appVersion",priority=0,description="Deployed version of Ambassador"
+// +kubebuilder:printcolumn:name="DEPLOYED-CHART",type="string",JSONPath=".status.deployedRelease.version",priority=1,description="Deployed version of the Helm chart"
+// +kubebuilder:printcolumn:name="DEPLOYED-FLAVOR",type="string",JSONPath=".status.deployedRelease.flavor",priority=0,description="Deployed flavor of Ambassador (OSS or AES)"
+// +kubebuilder:printcolumn:name="NEXT-VERSION",type="string",JSONPath=".status.nextUpgrade.appVersion",priority=1,description="Next version of Ambassador that will be deployed"
+type AmbassadorInstallation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AmbassadorInstallationSpec `json:"spec,omitempty"`
+
+	// The status is shared with the `v2` API.
+	Status v2.AmbassadorInstallationStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AmbassadorInstallationList contains a list of AmbassadorInstallation
+type AmbassadorInstallationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AmbassadorInstallation `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AmbassadorInstallation{}, &AmbassadorInstallationList{})
+}
@@ -0,0 +1,4 @@
+// Package v3 contains API Schema definitions for the getambassador v3 API group
+// +k8s:deepcopy-gen=package,register
+// +groupName=getambassador.io
+package v3
@@ -3,12 +3,13 @@ package ambassadorinstallation
 import (
 	"fmt"
 	"strings"
+	"time"
 
-	"github.com/operator-framework/operator-sdk/pkg/helm/release"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	"github.com/datawire/ambassador-operator/pkg/helm"
+	"github.com/datawire/ambassador-operator/pkg/helm/release"
 )
 
 const (
@@ -89,12 +90,15 @@ type HelmValuesStrings map[string]string
 
 // HelmManager is a remote Helm repo or a file, provided with an URL
 type HelmManager struct {
-	mgr manager.Manager
+	mgr                   manager.Manager
+	stalePendingThreshold time.Duration
 	helm.Downloader
 }
 
 type HelmManagerOptions struct {
 	Manager manager.Manager
+	// StalePendingThreshold is the time after which a release stuck in a pending status is recovered
+	StalePendingThreshold time.Duration
 	helm.DownloaderOptions
 }
 
@@ -107,8 +111,9 @@ func NewHelmManager(options HelmManagerOptions) (HelmManager, error) {
 		return HelmManager{}, err
 	}
 	return HelmManager{
-		mgr:        options.Manager,
-		Downloader: downloader,
+		mgr:                   options.Manager,
+		stalePendingThreshold: options.StalePendingThreshold,
+		Downloader:            downloader,
 	}, nil
 }
 
@@ -142,10 +147,43 @@ func (lc *HelmManager) GetManagerFor(o *unstructured.Unstructured, values HelmVa
 		unstructured.RemoveNestedField(oc.Object, "spec", defHelmValuesFieldName)
 	}
 
-	chartMgr, err := factory.NewManager(&oc, valuesStrings)
+	postRenderers, err := postRenderersFor(o)
+	if err != nil {
+		return nil, err
+	}
+	options := release.ManagerOptions{
+		ReleaseName:           releaseNameFor(o),
+		Namespace:             targetNamespaceFor(o),
+		PostRenderers:         postRenderers,
+		StalePendingThreshold: lc.stalePendingThreshold,
+	}
+
+	chartMgr, err := factory.NewManager(&oc, valuesStrings, options)
 	if err != nil {
 		return nil, err
 	}
 
 	return chartMgr, nil
 }
+
+// postRenderersFor returns the post-renderers for the resources rendered by the chart
+// for an AmbassadorInstallation: the `spec.patches` are applied before the images are
+// rewritten for the registry mirror, so the containers added by the patches are mirrored too
+func postRenderersFor(o *unstructured.Unstructured) ([]release.PostRenderer, error) {
+	var postRenderers []release.PostRenderer
+	patches, err := patchesFor(o)
+	if err != nil {
+		return nil, err
+	}
+	if len(patches) > 0 {
+		p, err := newPatchesPostRenderer(patches)
+		if err != nil {
+			return nil, err
+		}
+		postRenderers = append(postRenderers, p)
+	}
+	if mirror, _, _ := unstructured.NestedString(o.Object, "spec", "imageRegistryMirror"); len(mirror) > 0 {
+		postRenderers = append(postRenderers, newRegistryMirrorPostRenderer(mirror))
+	}
+	return postRenderers, nil
+}
@@ -52,6 +52,18 @@ func getCurrSpecHash(o *unstructured.Unstructured) (string, error) {
 		return "", err
 	}
 
+	// pausing/resuming the reconciliation is not a change in the .spec
+	if m, ok := currSpec.(map[string]interface{}); ok {
+		if _, found := m["paused"]; found {
+			withoutPaused := make(map[string]interface{}, len(m))
+			for k, v := range m {
+				withoutPaused[k] = v
+			}
+			delete(withoutPaused, "paused")
+			currSpec = withoutPaused
+		}
+	}
+
 	// encode the current .spec as a JSON
 	encodedSpec, err := json.Marshal(currSpec)
 	if err != nil {
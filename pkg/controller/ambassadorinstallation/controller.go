@@ -2,8 +2,10 @@ package ambassadorinstallation
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"reflect"
+	"strings"
 	"sync"
 
 	"gopkg.in/yaml.v2"
@@ -18,8 +20,6 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	crtpredicate "sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/source"
-
-	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
 )
 
 var log = logf.Log.WithName("controller-amb-inst")
@@ -46,22 +46,108 @@ var (
 // Add creates a new AmbassadorInstallation Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func Add(mgr manager.Manager) error {
-	return add(mgr, NewReconcileAmbassadorInstallation(mgr))
+	r, err := newReconciler(mgr, DefaultGVK)
+	if err != nil {
+		return err
+	}
+	return add(mgr, r)
+}
+
+// AddCluster creates a new ClusterAmbassadorInstallation Controller and adds it to the Manager.
+// ClusterAmbassadorInstallations are ignored when the operator is restricted to a namespace
+// or when the CRD is not installed in the cluster.
+func AddCluster(mgr manager.Manager) error {
+	namespaces, err := GetWatchNamespaces()
+	if err != nil {
+		return err
+	}
+	if len(namespaces) > 0 {
+		log.Info("Operator restricted to some namespaces: ClusterAmbassadorInstallations will be ignored", "namespaces", namespaces)
+		return nil
+	}
+
+	if _, err := mgr.GetRESTMapper().RESTMapping(ClusterGVK.GroupKind(), ClusterGVK.Version); err != nil {
+		log.Info("ClusterAmbassadorInstallations will be ignored: could not find the CRD", "error", err.Error())
+		return nil
+	}
+
+	r, err := newReconciler(mgr, ClusterGVK)
+	if err != nil {
+		return err
+	}
+	return add(mgr, r)
+}
+
+// newReconciler creates a new reconciler for some GVK, processing only the installations
+// that match the label selector provided in the environment, and sending the telemetry
+// reports to the sinks configured in the environment
+func newReconciler(mgr manager.Manager, gvk schema.GroupVersionKind) (*ReconcileAmbassadorInstallation, error) {
+	selector, err := GetWatchLabelSelector()
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", WatchLabelSelectorEnvVar, err)
+	}
+	if !selector.Empty() {
+		log.Info("Only installations matching the label selector will be processed", "kind", gvk.Kind, "selector", selector.String())
+	}
+
+	reporter, err := NewReporterFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("invalid telemetry configuration: %w", err)
+	}
+	if reporter == nil {
+		log.Info("Telemetry disabled", "kind", gvk.Kind)
+	}
+
+	// the namespaces watched by the operator (empty when it watches all the namespaces)
+	namespaces, err := GetWatchNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewReconcileAmbassadorInstallation(mgr, gvk)
+	r.selector = selector
+	r.namespaces = namespaces
+	r.reporter = reporter
+	return r, nil
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
 func add(mgr manager.Manager, r *ReconcileAmbassadorInstallation) error {
 	// Create a new controller
-	c, err := controller.New("ambassadorinstallation-controller", mgr, controller.Options{Reconciler: r})
+	c, err := controller.New(strings.ToLower(r.GVK.Kind)+"-controller", mgr, controller.Options{Reconciler: r})
 	if err != nil {
 		return err
 	}
 
-	// Watch for changes to primary resource AmbassadorInstallation
-	err = c.Watch(&source.Kind{Type: &ambassador.AmbassadorInstallation{}}, &handler.EnqueueRequestForObject{})
+	// Watch for changes to primary resource AmbassadorInstallation/ClusterAmbassadorInstallation
+	primary, err := mgr.GetScheme().New(r.GVK)
 	if err != nil {
 		return err
 	}
+	err = c.Watch(&source.Kind{Type: primary}, &handler.EnqueueRequestForObject{}, SelectorPredicateFuncs(r.selector))
+	if err != nil {
+		return err
+	}
+
+	// Re-evaluate the installations that have been disabled because of some other
+	// installation when that one is removed or its spec changes (for both kinds, when
+	// the other kind is available)
+	blockingMapper := &handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.mapToBlockedAmbInsts)}
+	for _, gvk := range []schema.GroupVersionKind{DefaultGVK, ClusterGVK} {
+		if gvk != r.GVK {
+			if _, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+				continue
+			}
+		}
+		blocking, err := mgr.GetScheme().New(gvk)
+		if err != nil {
+			return err
+		}
+		err = c.Watch(&source.Kind{Type: blocking}, blockingMapper, BlockingPredicateFuncs())
+		if err != nil {
+			return err
+		}
+	}
 
 	// based on the code at https://github.com/operator-framework/operator-sdk/blob/master/pkg/helm/controller/controller.go#L93
 
@@ -136,6 +222,13 @@ func add(mgr manager.Manager, r *ReconcileAmbassadorInstallation) error {
 				return err
 			}
 
+			// resources installed in a namespace different to the AmbassadorInstallation's
+			// cannot have owner references: they are labeled with the owner instead
+			err = c.Watch(&source.Kind{Type: &u}, &handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.mapOwnerLabels)}, dependentPredicate)
+			if err != nil {
+				return err
+			}
+
 			m.Lock()
 			watches[gvk] = struct{}{}
 			m.Unlock()
@@ -148,6 +241,25 @@ func add(mgr manager.Manager, r *ReconcileAmbassadorInstallation) error {
 
 /////////////////////////////////////////////////////////////////////////////////////////
 
+// BlockingPredicateFuncs returns functions for filtering the events in AmbassadorInstallations
+// that could unblock some other AmbassadorInstallation
+func BlockingPredicateFuncs() crtpredicate.Funcs {
+	return crtpredicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return false
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return true
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration()
+		},
+	}
+}
+
 // DependentPredicateFuncs returns functions defined for filtering events
 func DependentPredicateFuncs() crtpredicate.Funcs {
 
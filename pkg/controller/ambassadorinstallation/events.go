@@ -0,0 +1,55 @@
+package ambassadorinstallation
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+)
+
+// reasons for the Events that do not correspond to a condition reason
+const (
+	eventReasonInstalling     = "Installing"
+	eventReasonUpgrading      = "Upgrading"
+	eventReasonUninstalling   = "Uninstalling"
+	eventReasonUpdateDeferred = "UpdateDeferred"
+	eventReasonRolledBack     = "RolledBack"
+	eventReasonRollbackFailed = "RollbackFailed"
+	eventReasonRunningHook    = "RunningHook"
+	eventReasonRecovered      = "Recovered"
+
+	eventReasonSnapshotAvailable     = "SnapshotAvailable"
+	eventReasonSnapshotRestored      = "SnapshotRestored"
+	eventReasonSnapshotRestoreFailed = "SnapshotRestoreFailed"
+)
+
+// recordEvent records a Kubernetes Event for an installation
+func (r *ReconcileAmbassadorInstallation) recordEvent(o *unstructured.Unstructured, eventType string, reason string, messageFmt string, args ...interface{}) {
+	if r.EventRecorder == nil {
+		return
+	}
+	r.EventRecorder.Eventf(o, eventType, reason, messageFmt, args...)
+}
+
+// setCondition sets a condition in the status (for the current generation of the installation),
+// counting the outcome in the metrics. Failures (and Irreconcilable installations) are also
+// recorded as Warning Events.
+func (r *ReconcileAmbassadorInstallation) setCondition(o *unstructured.Unstructured,
+	status *ambassador.AmbassadorInstallationStatus, condition ambassador.AmbInsCondition) {
+	condition.ObservedGeneration = o.GetGeneration()
+	status.SetCondition(condition)
+	recordOutcome(condition)
+
+	switch condition.Type {
+	case ambassador.ConditionReleaseFailed, ambassador.ConditionIrreconcilable:
+		r.recordEvent(o, corev1.EventTypeWarning, string(condition.Reason), "%s", condition.Message)
+	}
+}
+
+// releaseDescription returns a description of a release for the Events (ie, `1.5.0 (chart 6.4.0)`)
+func releaseDescription(release *ambassador.AmbassadorRelease) string {
+	if release == nil {
+		return "<none>"
+	}
+	return release.AppVersion + " (chart " + release.Version + ")"
+}
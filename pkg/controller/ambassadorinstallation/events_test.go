@@ -0,0 +1,51 @@
+package ambassadorinstallation
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/record"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+)
+
+func TestSetConditionEvents(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	r := &ReconcileAmbassadorInstallation{EventRecorder: recorder}
+	ambIns := newTestAmbInst("ambassador", time.Now(), map[string]interface{}{})
+	status := &ambassador.AmbassadorInstallationStatus{}
+
+	r.setCondition(&ambIns, status, ambassador.AmbInsCondition{
+		Type:    ambassador.ConditionReleaseFailed,
+		Status:  ambassador.StatusTrue,
+		Reason:  ambassador.ReasonDownloadError,
+		Message: "could not download chart",
+	})
+	r.setCondition(&ambIns, status, ambassador.AmbInsCondition{
+		Type:   ambassador.ConditionDeployed,
+		Status: ambassador.StatusTrue,
+		Reason: ambassador.ReasonInstallSuccessful,
+	})
+
+	if len(status.Conditions) != 2 {
+		t.Errorf("expected 2 conditions, got %v", status.Conditions)
+	}
+
+	// only the failure is recorded as an Event
+	if len(recorder.Events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(recorder.Events))
+	}
+	expected := "Warning DownloadError could not download chart"
+	if e := <-recorder.Events; e != expected {
+		t.Errorf("got event %q, expected %q", e, expected)
+	}
+
+	// a reconciler without recorder does not record anything
+	r = &ReconcileAmbassadorInstallation{}
+	r.recordEvent(&ambIns, "Normal", eventReasonInstalling, "Installing")
+
+	if d := releaseDescription(&ambassador.AmbassadorRelease{AppVersion: "1.5.0", Version: "6.4.0"}); !strings.Contains(d, "chart 6.4.0") {
+		t.Errorf("unexpected release description %q", d)
+	}
+}
@@ -0,0 +1,51 @@
+package ambassadorinstallation
+
+import (
+	"time"
+
+	rpb "helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+)
+
+// historyTrigger returns the trigger recorded in the history for an installation or an upgrade
+func historyTrigger(isMigrating bool, specChanged bool) ambassador.AmbassadorReleaseTrigger {
+	switch {
+	case isMigrating:
+		return ambassador.TriggerMigration
+	case specChanged:
+		return ambassador.TriggerSpecChange
+	default:
+		return ambassador.TriggerScheduledUpdate
+	}
+}
+
+// newReleaseRecord creates a new entry for the history for a release that started at `start`.
+// The release can be nil when the installation/upgrade failed: the chart downloaded is used then.
+func newReleaseRecord(chartsMgr HelmManager, flavor string, trigger ambassador.AmbassadorReleaseTrigger,
+	start time.Time, release *rpb.Release, err error) ambassador.AmbassadorReleaseRecord {
+	end := metav1.Now()
+	record := ambassador.AmbassadorReleaseRecord{
+		Flavor:    flavor,
+		StartTime: metav1.NewTime(start),
+		EndTime:   &end,
+		Outcome:   ambassador.OutcomeSucceeded,
+		Trigger:   trigger,
+	}
+
+	if release != nil && release.Chart != nil && release.Chart.Metadata != nil {
+		record.Version = release.Chart.Metadata.Version
+		record.AppVersion = release.Chart.Metadata.AppVersion
+		record.Revision = release.Version
+	} else if c := chartsMgr.GetChart(); c != nil {
+		record.Version = c.Version
+		record.AppVersion = c.AppVersion
+	}
+
+	if err != nil {
+		record.Outcome = ambassador.OutcomeFailed
+		record.Error = err.Error()
+	}
+	return record
+}
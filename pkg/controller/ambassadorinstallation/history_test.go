@@ -0,0 +1,90 @@
+package ambassadorinstallation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"helm.sh/helm/v3/pkg/chart"
+	rpb "helm.sh/helm/v3/pkg/release"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+)
+
+func TestHistoryTrigger(t *testing.T) {
+	tests := []struct {
+		isMigrating bool
+		specChanged bool
+		expected    ambassador.AmbassadorReleaseTrigger
+	}{
+		{isMigrating: true, specChanged: true, expected: ambassador.TriggerMigration},
+		{isMigrating: false, specChanged: true, expected: ambassador.TriggerSpecChange},
+		{isMigrating: false, specChanged: false, expected: ambassador.TriggerScheduledUpdate},
+	}
+	for _, test := range tests {
+		if got := historyTrigger(test.isMigrating, test.specChanged); got != test.expected {
+			t.Errorf("migrating=%v, specChanged=%v: got %s, expected %s",
+				test.isMigrating, test.specChanged, got, test.expected)
+		}
+	}
+}
+
+func TestNewReleaseRecord(t *testing.T) {
+	release := &rpb.Release{
+		Version: 3,
+		Chart:   &chart.Chart{Metadata: &chart.Metadata{Version: "6.4.0", AppVersion: "1.5.0"}},
+	}
+	start := time.Now().Add(-time.Minute)
+
+	record := newReleaseRecord(HelmManager{}, flavorAES, ambassador.TriggerScheduledUpdate, start, release, nil)
+	if record.Version != "6.4.0" || record.AppVersion != "1.5.0" || record.Revision != 3 || record.Flavor != flavorAES {
+		t.Errorf("unexpected record: %+v", record)
+	}
+	if record.Outcome != ambassador.OutcomeSucceeded || len(record.Error) > 0 {
+		t.Errorf("unexpected outcome: %+v", record)
+	}
+	if !record.StartTime.Time.Equal(start) || record.EndTime == nil || record.EndTime.Before(&record.StartTime) {
+		t.Errorf("unexpected times: %+v", record)
+	}
+
+	record = newReleaseRecord(HelmManager{}, flavorAES, ambassador.TriggerDrift, start, release, errors.New("patch error"))
+	if record.Outcome != ambassador.OutcomeFailed || record.Error != "patch error" {
+		t.Errorf("unexpected outcome: %+v", record)
+	}
+}
+
+func TestStatusAddHistory(t *testing.T) {
+	status := &ambassador.AmbassadorInstallationStatus{}
+
+	for i := 0; i < ambassador.MaxHistoryLength+5; i++ {
+		status.AddHistory(ambassador.AmbassadorReleaseRecord{
+			Version: fmt.Sprintf("6.%d.0", i),
+			Outcome: ambassador.OutcomeSucceeded,
+			Trigger: ambassador.TriggerScheduledUpdate,
+		})
+	}
+	if len(status.History) != ambassador.MaxHistoryLength {
+		t.Fatalf("expected %d entries, got %d", ambassador.MaxHistoryLength, len(status.History))
+	}
+	if newest := status.History[0].Version; newest != fmt.Sprintf("6.%d.0", ambassador.MaxHistoryLength+4) {
+		t.Errorf("unexpected newest entry: %s", newest)
+	}
+
+	// repeated failures are collapsed in the same entry
+	failure := ambassador.AmbassadorReleaseRecord{
+		Version: "6.99.0",
+		Outcome: ambassador.OutcomeFailed,
+		Trigger: ambassador.TriggerDrift,
+		Error:   strings.Repeat("x", 1000),
+	}
+	status.AddHistory(failure)
+	status.AddHistory(failure)
+	if status.History[0].Version != "6.99.0" || status.History[1].Version == "6.99.0" {
+		t.Errorf("repeated entries were not collapsed: %+v", status.History[:2])
+	}
+	if len(status.History[0].Error) > 256 || !strings.HasSuffix(status.History[0].Error, "...") {
+		t.Errorf("error was not truncated: %d chars", len(status.History[0].Error))
+	}
+}
@@ -0,0 +1,328 @@
+package ambassadorinstallation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/helm/release"
+)
+
+const (
+	// default maximum time for every hook Job
+	defaultHookTimeout = 5 * time.Minute
+
+	// time between the checks of the status of a hook Job
+	hookPollInterval = 2 * time.Second
+
+	// maximum time for deleting a hook Job that did not finish in time
+	hookDeleteTimeout = 10 * time.Second
+
+	// label added to the hook Jobs, with the phase of the hook
+	hookPhaseLabel = "getambassador.io/hook-phase"
+
+	// maximum length of the errors recorded in the status of the hooks
+	maxHookErrorLength = 256
+)
+
+// environment variables added to the containers of the hook Jobs
+const (
+	hookEnvPhase            = "AMB_HOOK_PHASE"
+	hookEnvReleaseName      = "AMB_RELEASE_NAME"
+	hookEnvReleaseNamespace = "AMB_RELEASE_NAMESPACE"
+	hookEnvFromVersion      = "AMB_FROM_VERSION"
+	hookEnvToVersion        = "AMB_TO_VERSION"
+)
+
+// HookError is the error returned when a hook in `spec.hooks` failed
+type HookError struct {
+	Name  string
+	Phase ambassador.AmbassadorHookPhase
+	Err   error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s hook %q failed: %v", e.Phase, e.Name, e.Err)
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// hooksFor returns the `spec.hooks` of an AmbassadorInstallation (or nil when there are none)
+func hooksFor(o *unstructured.Unstructured) (*ambassador.AmbassadorHooks, error) {
+	ambIns, err := unsToInstallation(o)
+	if err != nil {
+		return nil, err
+	}
+	return ambIns.GetSpec().Hooks, nil
+}
+
+// hookTimeout returns the maximum time for every hook Job
+func hookTimeout(hooks *ambassador.AmbassadorHooks) time.Duration {
+	if hooks != nil && hooks.Timeout != nil && hooks.Timeout.Duration > 0 {
+		return hooks.Timeout.Duration
+	}
+	return defaultHookTimeout
+}
+
+// hookVersions describes the upgrade a hook is run for
+type hookVersions struct {
+	fromAppVersion          string
+	toVersion, toAppVersion string
+}
+
+// runUpgradeHooks runs (in order) the hooks for a phase, recording their outcome in the status.
+// It stops at the first hook that fails, returning a *HookError.
+func (r *ReconcileAmbassadorInstallation) runUpgradeHooks(ctx context.Context, o *unstructured.Unstructured,
+	status *ambassador.AmbassadorInstallationStatus, phase ambassador.AmbassadorHookPhase,
+	releaseName string, versions hookVersions) error {
+	hooks, err := hooksFor(o)
+	if err != nil || hooks == nil {
+		return err
+	}
+	toRun := hooks.PreUpgrade
+	if phase == ambassador.HookPostUpgrade {
+		toRun = hooks.PostUpgrade
+	}
+	namespace := targetNamespaceFor(o)
+
+	for _, hook := range toRun {
+		job, err := newHookJob(o, hook, phase, releaseName, namespace, versions)
+		if err == nil {
+			log.Info("Running hook", "phase", phase, "hook", hook.Name, "job", job.Name)
+			r.recordEvent(o, corev1.EventTypeNormal, eventReasonRunningHook, "Running %s hook %q (Job %s/%s)",
+				phase, hook.Name, job.Namespace, job.Name)
+		}
+
+		start := metav1.Now()
+		if err == nil {
+			hookCtx, cancel := context.WithTimeout(ctx, hookTimeout(hooks))
+			err = runHookJob(hookCtx, r.Manager.GetAPIReader(), r.Client, job)
+			cancel()
+		}
+
+		end := metav1.Now()
+		hookStatus := ambassador.AmbassadorHookStatus{
+			Name:       hook.Name,
+			Phase:      phase,
+			Version:    versions.toVersion,
+			AppVersion: versions.toAppVersion,
+			StartTime:  start,
+			EndTime:    &end,
+			Outcome:    ambassador.OutcomeSucceeded,
+		}
+		if job != nil {
+			hookStatus.Job = job.Namespace + "/" + job.Name
+		}
+		if err != nil {
+			hookStatus.Outcome = ambassador.OutcomeFailed
+			hookStatus.Error = err.Error()
+		}
+		setHookStatus(status, hookStatus)
+
+		if err != nil {
+			return &HookError{Name: hook.Name, Phase: phase, Err: err}
+		}
+	}
+	return nil
+}
+
+// setHookStatus replaces the last run of a hook in the status
+func setHookStatus(status *ambassador.AmbassadorInstallationStatus, hookStatus ambassador.AmbassadorHookStatus) {
+	if len(hookStatus.Error) > maxHookErrorLength {
+		hookStatus.Error = hookStatus.Error[:maxHookErrorLength-3] + "..."
+	}
+	for i := range status.Hooks {
+		if status.Hooks[i].Name == hookStatus.Name && status.Hooks[i].Phase == hookStatus.Phase {
+			status.Hooks[i] = hookStatus
+			return
+		}
+	}
+	status.Hooks = append(status.Hooks, hookStatus)
+}
+
+// parseHookTemplate parses the Job template of a hook
+func parseHookTemplate(hook ambassador.AmbassadorHook) (*batchv1beta1.JobTemplateSpec, error) {
+	if hook.Template == nil || len(hook.Template.Raw) == 0 {
+		return nil, errors.New("a Job template must be provided")
+	}
+	template := &batchv1beta1.JobTemplateSpec{}
+	if err := json.Unmarshal(hook.Template.Raw, template); err != nil {
+		return nil, fmt.Errorf("could not parse the Job template: %w", err)
+	}
+	if len(template.Spec.Template.Spec.Containers) == 0 {
+		return nil, errors.New("the Job template must have some containers")
+	}
+	if err := checkHookPodSpec(&template.Spec.Template.Spec); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// checkHookPodSpec checks that the Pods of a hook run with the default ServiceAccount of the
+// target namespace and without any privileges, as the hooks are run by the operator
+func checkHookPodSpec(spec *corev1.PodSpec) error {
+	switch {
+	case len(spec.ServiceAccountName) > 0 || len(spec.DeprecatedServiceAccount) > 0:
+		return errors.New("the Job template cannot set a serviceAccountName: hooks run with the default ServiceAccount")
+	case spec.HostNetwork || spec.HostPID || spec.HostIPC:
+		return errors.New("the Job template cannot use the host network, PID or IPC namespaces")
+	}
+	for _, v := range spec.Volumes {
+		if v.HostPath != nil {
+			return fmt.Errorf("the Job template cannot mount hostPath volumes (volume %q)", v.Name)
+		}
+	}
+	containers := append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, c := range containers {
+		sc := c.SecurityContext
+		if sc == nil {
+			continue
+		}
+		if (sc.Privileged != nil && *sc.Privileged) || (sc.AllowPrivilegeEscalation != nil && *sc.AllowPrivilegeEscalation) ||
+			(sc.Capabilities != nil && len(sc.Capabilities.Add) > 0) {
+			return fmt.Errorf("the container %q cannot be privileged or add capabilities", c.Name)
+		}
+	}
+	return nil
+}
+
+// newHookJob returns the Job for a hook, named after the release, the phase and the hook
+func newHookJob(o *unstructured.Unstructured, hook ambassador.AmbassadorHook, phase ambassador.AmbassadorHookPhase,
+	releaseName, namespace string, versions hookVersions) (*batchv1.Job, error) {
+	template, err := parseHookTemplate(hook)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: *template.ObjectMeta.DeepCopy(),
+		Spec:       *template.Spec.DeepCopy(),
+	}
+	job.Name = truncateName(strings.ToLower(fmt.Sprintf("%s-%s-%s", releaseName, hookPhaseName(phase), hook.Name)))
+	job.Namespace = namespace
+	job.GenerateName = ""
+
+	labels := job.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for k, v := range release.OwnerLabels(o) {
+		labels[k] = v
+	}
+	labels[hookPhaseLabel] = hookPhaseName(phase)
+	job.SetLabels(labels)
+	if len(o.GetNamespace()) == 0 || o.GetNamespace() == namespace {
+		job.SetOwnerReferences([]metav1.OwnerReference{*metav1.NewControllerRef(o, o.GroupVersionKind())})
+	}
+
+	podSpec := &job.Spec.Template.Spec
+	if len(podSpec.RestartPolicy) == 0 {
+		podSpec.RestartPolicy = corev1.RestartPolicyNever
+	}
+	env := []corev1.EnvVar{
+		{Name: hookEnvPhase, Value: hookPhaseName(phase)},
+		{Name: hookEnvReleaseName, Value: releaseName},
+		{Name: hookEnvReleaseNamespace, Value: namespace},
+		{Name: hookEnvFromVersion, Value: versions.fromAppVersion},
+		{Name: hookEnvToVersion, Value: versions.toAppVersion},
+	}
+	for i := range podSpec.Containers {
+		podSpec.Containers[i].Env = append(podSpec.Containers[i].Env, env...)
+	}
+	return job, nil
+}
+
+// hookPhaseName returns the name of a phase used in the Jobs (ie, `pre-upgrade`)
+func hookPhaseName(phase ambassador.AmbassadorHookPhase) string {
+	if phase == ambassador.HookPostUpgrade {
+		return "post-upgrade"
+	}
+	return "pre-upgrade"
+}
+
+// runHookJob (re)creates a Job and waits until it finishes or the context is done. The
+// Job is read with `reader` (ie, the API reader, as we do not want to cache all the Jobs).
+func runHookJob(ctx context.Context, reader client.Reader, c client.Client, job *batchv1.Job) error {
+	key := types.NamespacedName{Namespace: job.Namespace, Name: job.Name}
+
+	// remove the Job of the previous run (and its Pods), like Helm does with its hooks
+	existing := &batchv1.Job{}
+	if err := reader.Get(ctx, key, existing); err == nil {
+		if err := c.Delete(ctx, existing, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("could not delete the previous Job %s: %w", key, err)
+		}
+	} else if !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	if err := c.Create(ctx, job); err != nil {
+		return fmt.Errorf("could not create Job %s: %w", key, err)
+	}
+
+	for {
+		current := &batchv1.Job{}
+		if err := reader.Get(ctx, key, current); err != nil && ctx.Err() == nil {
+			return fmt.Errorf("could not get Job %s: %w", key, err)
+		}
+		if done, err := jobOutcome(current); done {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			// do not leave the Job (and its Pods) running: it could run in the middle of the upgrade
+			err := fmt.Errorf("the Job %s did not finish in time", key)
+			deleteCtx, cancel := context.WithTimeout(context.Background(), hookDeleteTimeout)
+			defer cancel()
+			if delErr := c.Delete(deleteCtx, job, client.PropagationPolicy(metav1.DeletePropagationForeground)); delErr != nil && !apierrors.IsNotFound(delErr) {
+				err = fmt.Errorf("%s (and it could not be deleted: %v)", err, delErr)
+			}
+			return err
+		case <-time.After(hookPollInterval):
+		}
+	}
+}
+
+// jobOutcome returns true when a Job has finished, with an error if it failed
+func jobOutcome(job *batchv1.Job) (bool, error) {
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			return true, nil
+		case batchv1.JobFailed:
+			message := cond.Message
+			if len(message) == 0 {
+				message = cond.Reason
+			}
+			return true, fmt.Errorf("the Job %s/%s failed: %s", job.Namespace, job.Name, message)
+		}
+	}
+	return false, nil
+}
+
+// hooksTimeout returns the maximum time all the hooks of an installation can take
+func hooksTimeout(o *unstructured.Unstructured) time.Duration {
+	hooks, err := hooksFor(o)
+	if err != nil || hooks == nil {
+		return 0
+	}
+	return time.Duration(len(hooks.PreUpgrade)+len(hooks.PostUpgrade)) * hookTimeout(hooks)
+}
@@ -0,0 +1,166 @@
+package ambassadorinstallation
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/helm/release"
+)
+
+const testHookTemplate = `{
+  "metadata": {"labels": {"team": "edge"}},
+  "spec": {
+    "backoffLimit": 1,
+    "template": {
+      "spec": {
+        "containers": [{"name": "backup", "image": "bitnami/kubectl:1.18", "env": [{"name": "A", "value": "b"}]}]
+      }
+    }
+  }
+}`
+
+func newTestHook(name string) ambassador.AmbassadorHook {
+	return ambassador.AmbassadorHook{Name: name, Template: &runtime.RawExtension{Raw: []byte(testHookTemplate)}}
+}
+
+func TestNewHookJob(t *testing.T) {
+	owner := newTestAmbInst("ambassador", time.Now(), map[string]interface{}{})
+	versions := hookVersions{fromAppVersion: "1.4.3", toVersion: "6.4.0", toAppVersion: "1.5.0"}
+
+	job, err := newHookJob(&owner, newTestHook("backup"), ambassador.HookPreUpgrade, "ambassador", owner.GetNamespace(), versions)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job.Name != "ambassador-pre-upgrade-backup" || job.Namespace != owner.GetNamespace() {
+		t.Errorf("unexpected Job %s/%s", job.Namespace, job.Name)
+	}
+	labels := job.GetLabels()
+	if labels["team"] != "edge" || labels[hookPhaseLabel] != "pre-upgrade" || labels[release.OwnerNameLabel] != owner.GetName() {
+		t.Errorf("unexpected labels %v", labels)
+	}
+	if refs := job.GetOwnerReferences(); len(refs) != 1 || refs[0].Name != owner.GetName() {
+		t.Errorf("unexpected owner references %v", refs)
+	}
+	podSpec := job.Spec.Template.Spec
+	if podSpec.RestartPolicy != corev1.RestartPolicyNever {
+		t.Errorf("unexpected restart policy %q", podSpec.RestartPolicy)
+	}
+	env := map[string]string{}
+	for _, e := range podSpec.Containers[0].Env {
+		env[e.Name] = e.Value
+	}
+	expected := map[string]string{
+		"A":                     "b",
+		hookEnvPhase:            "pre-upgrade",
+		hookEnvReleaseName:      "ambassador",
+		hookEnvReleaseNamespace: owner.GetNamespace(),
+		hookEnvFromVersion:      "1.4.3",
+		hookEnvToVersion:        "1.5.0",
+	}
+	for k, v := range expected {
+		if env[k] != v {
+			t.Errorf("env %s=%q, expected %q", k, env[k], v)
+		}
+	}
+
+	// Jobs in other namespaces are only labeled
+	job, err = newHookJob(&owner, newTestHook("notify"), ambassador.HookPostUpgrade, "ambassador", "edge", versions)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job.Name != "ambassador-post-upgrade-notify" || len(job.GetOwnerReferences()) != 0 {
+		t.Errorf("unexpected Job %s with owner references %v", job.Name, job.GetOwnerReferences())
+	}
+
+	if _, err := newHookJob(&owner, ambassador.AmbassadorHook{Name: "empty"}, ambassador.HookPreUpgrade, "ambassador", "edge", versions); err == nil {
+		t.Errorf("expected an error for a hook without a template")
+	}
+}
+
+func TestJobOutcome(t *testing.T) {
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "ambassador", Name: "backup"}}
+	if done, _ := jobOutcome(job); done {
+		t.Errorf("Job without conditions is done")
+	}
+
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	if done, err := jobOutcome(job); !done || err != nil {
+		t.Errorf("unexpected outcome for a complete Job: %t, %v", done, err)
+	}
+
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded"}}
+	if done, err := jobOutcome(job); !done || err == nil || !strings.Contains(err.Error(), "BackoffLimitExceeded") {
+		t.Errorf("unexpected outcome for a failed Job: %t, %v", done, err)
+	}
+}
+
+func TestRunHookJob(t *testing.T) {
+	owner := newTestAmbInst("ambassador", time.Now(), map[string]interface{}{})
+	job, err := newHookJob(&owner, newTestHook("backup"), ambassador.HookPreUpgrade, "ambassador", owner.GetNamespace(), hookVersions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := types.NamespacedName{Namespace: job.Namespace, Name: job.Name}
+
+	// the Job of a previous run is replaced
+	previous := job.DeepCopy()
+	previous.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	c := fake.NewFakeClient(previous)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = runHookJob(ctx, c, c, job)
+	if err == nil || !strings.Contains(err.Error(), "did not finish in time") {
+		t.Errorf("expected a timeout (after replacing the previous Job), got %v", err)
+	}
+
+	// the Job that did not finish in time is deleted
+	if err := c.Get(context.Background(), key, &batchv1.Job{}); !apierrors.IsNotFound(err) {
+		t.Errorf("the Job was not deleted after the timeout: %v", err)
+	}
+}
+
+func TestHookTemplatePrivileges(t *testing.T) {
+	for _, template := range []string{
+		`{"spec": {"template": {"spec": {"serviceAccountName": "cluster-admin", "containers": [{"name": "a", "image": "a"}]}}}}`,
+		`{"spec": {"template": {"spec": {"serviceAccount": "cluster-admin", "containers": [{"name": "a", "image": "a"}]}}}}`,
+		`{"spec": {"template": {"spec": {"hostNetwork": true, "containers": [{"name": "a", "image": "a"}]}}}}`,
+		`{"spec": {"template": {"spec": {"volumes": [{"name": "root", "hostPath": {"path": "/"}}], "containers": [{"name": "a", "image": "a"}]}}}}`,
+		`{"spec": {"template": {"spec": {"containers": [{"name": "a", "image": "a", "securityContext": {"privileged": true}}]}}}}`,
+		`{"spec": {"template": {"spec": {"initContainers": [{"name": "i", "image": "i", "securityContext": {"capabilities": {"add": ["SYS_ADMIN"]}}}], "containers": [{"name": "a", "image": "a"}]}}}}`,
+	} {
+		hook := ambassador.AmbassadorHook{Name: "backup", Template: &runtime.RawExtension{Raw: []byte(template)}}
+		if _, err := parseHookTemplate(hook); err == nil {
+			t.Errorf("expected an error for the template %s", template)
+		}
+	}
+	if _, err := parseHookTemplate(newTestHook("backup")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSetHookStatus(t *testing.T) {
+	status := &ambassador.AmbassadorInstallationStatus{}
+	setHookStatus(status, ambassador.AmbassadorHookStatus{Name: "backup", Phase: ambassador.HookPreUpgrade, Outcome: ambassador.OutcomeFailed})
+	setHookStatus(status, ambassador.AmbassadorHookStatus{Name: "backup", Phase: ambassador.HookPostUpgrade, Outcome: ambassador.OutcomeSucceeded})
+	setHookStatus(status, ambassador.AmbassadorHookStatus{Name: "backup", Phase: ambassador.HookPreUpgrade, Outcome: ambassador.OutcomeSucceeded,
+		Error: strings.Repeat("x", 1000)})
+
+	if len(status.Hooks) != 2 {
+		t.Fatalf("expected 2 hooks in the status, got %+v", status.Hooks)
+	}
+	if h := status.Hooks[0]; h.Outcome != ambassador.OutcomeSucceeded || len(h.Error) != maxHookErrorLength {
+		t.Errorf("the last run of the hook was not recorded: %+v", h)
+	}
+}
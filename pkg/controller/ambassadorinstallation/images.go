@@ -0,0 +1,95 @@
+package ambassadorinstallation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/datawire/ambassador-operator/pkg/helm/release"
+)
+
+// fields (in any object rendered by the chart) with lists of containers
+var containersFields = []string{"containers", "initContainers", "ephemeralContainers"}
+
+// validateRegistryMirror checks a registry mirror is a valid registry host,
+// with an optional path (ie, `registry.internal:5000/mirror`)
+func validateRegistryMirror(mirror string) error {
+	mirror = strings.TrimSuffix(mirror, "/")
+	if strings.Contains(mirror, "@") {
+		return fmt.Errorf("must be a registry host with an optional path (ie, registry.internal:5000/mirror)")
+	}
+	// the mirror must be valid as the beginning of an image name
+	if _, err := reference.ParseNamed(mirror + "/image"); err != nil {
+		return fmt.Errorf("must be a registry host with an optional path (ie, registry.internal:5000/mirror): %w", err)
+	}
+	return nil
+}
+
+// mirrorImage rewrites an image for pulling it from a registry mirror, keeping the
+// repository path, tag and digest. Images from the Docker Hub keep their full path,
+// for example: "redis:5.0.1" -> "registry.internal/library/redis:5.0.1"
+// Images already in the mirror are not modified.
+func mirrorImage(image, mirror string) (string, error) {
+	mirror = strings.TrimSuffix(mirror, "/")
+	if strings.HasPrefix(image, mirror+"/") {
+		return image, nil
+	}
+
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("could not parse image name %s: %w", image, err)
+	}
+
+	res := mirror + "/" + reference.Path(named)
+	if tagged, ok := named.(reference.Tagged); ok {
+		res += ":" + tagged.Tag()
+	}
+	if digested, ok := named.(reference.Digested); ok {
+		res += "@" + digested.Digest().String()
+	}
+	return res, nil
+}
+
+// newRegistryMirrorPostRenderer returns a post-renderer that rewrites all the
+// images rendered by the chart for pulling them from a registry mirror
+func newRegistryMirrorPostRenderer(mirror string) release.PostRenderer {
+	return release.PostRendererFunc(func(u *unstructured.Unstructured) error {
+		return mirrorImagesIn(u.Object, mirror)
+	})
+}
+
+// mirrorImagesIn looks for lists of containers anywhere in an object (so it works for
+// Pods, Deployments, CronJobs...) and rewrites their images for the registry mirror
+func mirrorImagesIn(obj map[string]interface{}, mirror string) error {
+	for k, v := range obj {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			if err := mirrorImagesIn(value, mirror); err != nil {
+				return err
+			}
+		case []interface{}:
+			isContainers := contains(containersFields, k)
+			for _, elem := range value {
+				m, ok := elem.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if isContainers {
+					if image, ok := m["image"].(string); ok && len(image) > 0 {
+						mirrored, err := mirrorImage(image, mirror)
+						if err != nil {
+							return err
+						}
+						m["image"] = mirrored
+					}
+				}
+				if err := mirrorImagesIn(m, mirror); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
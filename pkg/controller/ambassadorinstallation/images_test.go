@@ -0,0 +1,126 @@
+package ambassadorinstallation
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const testDigest = "sha256:2bd6f1e2c3a4b5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e"
+
+func TestParseRepoTag(t *testing.T) {
+	tests := []struct {
+		image   string
+		repo    string
+		tag     string
+		wantErr bool
+	}{
+		{image: "quay.io/datawire/aes:1.0", repo: "quay.io/datawire/aes", tag: "1.0"},
+		{image: "localhost:5000/ambassador:1.5", repo: "localhost:5000/ambassador", tag: "1.5"},
+		{image: "ambassador@" + testDigest, repo: "ambassador@sha256", tag: testDigest[len("sha256:"):]},
+		{image: "quay.io/datawire/aes:1.5@" + testDigest, repo: "quay.io/datawire/aes:1.5@sha256", tag: testDigest[len("sha256:"):]},
+		{image: "quay.io/datawire/aes", wantErr: true},
+		{image: "localhost:5000/ambassador", wantErr: true},
+		{image: "ambassador@sha256:wrong", wantErr: true},
+		{image: "", wantErr: true},
+	}
+
+	for _, test := range tests {
+		repo, tag, err := parseRepoTag(test.image)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected error, got (%q, %q)", test.image, repo, tag)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.image, err)
+			continue
+		}
+		if repo != test.repo || tag != test.tag {
+			t.Errorf("%q: got (%q, %q), expected (%q, %q)", test.image, repo, tag, test.repo, test.tag)
+		}
+		// the chart renders the image as `repository:tag`
+		if repo+":"+tag != test.image {
+			t.Errorf("%q: rendered as %q", test.image, repo+":"+tag)
+		}
+	}
+}
+
+func TestMirrorImage(t *testing.T) {
+	mirror := "registry.internal:5000/mirror/"
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{"quay.io/datawire/aes:1.5.0", "registry.internal:5000/mirror/datawire/aes:1.5.0"},
+		{"redis:5.0.1", "registry.internal:5000/mirror/library/redis:5.0.1"},
+		{"localhost:5000/ambassador", "registry.internal:5000/mirror/ambassador"},
+		{"datawire/aes@" + testDigest, "registry.internal:5000/mirror/datawire/aes@" + testDigest},
+		{"registry.internal:5000/mirror/datawire/aes:1.5.0", "registry.internal:5000/mirror/datawire/aes:1.5.0"},
+	}
+
+	for _, test := range tests {
+		res, err := mirrorImage(test.image, mirror)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.image, err)
+			continue
+		}
+		if res != test.expected {
+			t.Errorf("%q: got %q, expected %q", test.image, res, test.expected)
+		}
+	}
+
+	for _, m := range []string{"registry.internal", "registry.internal:5000/mirror", "localhost/mirror/"} {
+		if err := validateRegistryMirror(m); err != nil {
+			t.Errorf("%q: unexpected error: %v", m, err)
+		}
+	}
+	for _, m := range []string{"", "Registry.Internal/UPPER", "registry.internal@sha256"} {
+		if err := validateRegistryMirror(m); err == nil {
+			t.Errorf("%q: expected error", m)
+		}
+	}
+}
+
+func TestRegistryMirrorPostRenderer(t *testing.T) {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"initContainers": []interface{}{
+						map[string]interface{}{"name": "init", "image": "busybox"},
+					},
+					"containers": []interface{}{
+						map[string]interface{}{"name": "ambassador", "image": "quay.io/datawire/aes:1.5.0"},
+						map[string]interface{}{"name": "redis", "image": "redis:5.0.1"},
+					},
+					"volumes": []interface{}{
+						map[string]interface{}{"name": "image", "image": "not-an-image"},
+					},
+				},
+			},
+		},
+	}}
+
+	if err := newRegistryMirrorPostRenderer("registry.internal").PostRender(u); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	podSpec, _, _ := unstructured.NestedMap(u.Object, "spec", "template", "spec")
+	expected := map[string][]string{
+		"initContainers": {"registry.internal/library/busybox"},
+		"containers":     {"registry.internal/datawire/aes:1.5.0", "registry.internal/library/redis:5.0.1"},
+		"volumes":        {"not-an-image"},
+	}
+	for field, images := range expected {
+		list := podSpec[field].([]interface{})
+		for i, image := range images {
+			if got := list[i].(map[string]interface{})["image"]; got != image {
+				t.Errorf("%s[%d]: got %q, expected %q", field, i, got, image)
+			}
+		}
+	}
+}
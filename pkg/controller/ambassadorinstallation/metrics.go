@@ -0,0 +1,172 @@
+package ambassadorinstallation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/tracing"
+)
+
+// phases of a reconciliation measured in the metrics
+const (
+	phaseDownload  = "download"
+	phaseSync      = "sync"
+	phaseInstall   = "install"
+	phaseUpdate    = "update"
+	phaseVerify    = "verify"
+	phaseRender    = "render"
+	phaseUninstall = "uninstall"
+)
+
+const metricsNamespace = "ambassador_operator"
+
+// labels identifying an installation in the metrics
+var installationLabels = []string{"kind", "namespace", "name"}
+
+var (
+	reconcilePhaseDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "reconcile_phase_duration_seconds",
+			Help:      "Duration of the phases of a reconciliation (download, sync, install, update, verify, render, uninstall)",
+			Buckets:   []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120, 300},
+		},
+		[]string{"phase"},
+	)
+
+	reconcileOutcomes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "reconcile_outcomes_total",
+			Help:      "Number of reconciliations by the type and reason of the condition set in the status",
+		},
+		[]string{"type", "reason"},
+	)
+
+	installationInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ambassador_installation_info",
+			Help: "Information about the Ambassador deployed by an installation",
+		},
+		append(installationLabels, "app_version", "chart_version", "flavor"),
+	)
+
+	installationUpgradeAvailable = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ambassador_installation_upgrade_available",
+			Help: "Whether there is a newer version available for an installation (1) or not (0)",
+		},
+		installationLabels,
+	)
+
+	installationUpdateWindowOpen = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ambassador_installation_update_window_open",
+			Help: "Whether the update window of an installation is currently open (1) or not (0)",
+		},
+		installationLabels,
+	)
+)
+
+// the labels used in the info metric of each installation, so old values can be removed
+var (
+	installationInfoLabels      = map[string]prometheus.Labels{}
+	installationInfoLabelsMutex sync.Mutex
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		reconcilePhaseDuration,
+		reconcileOutcomes,
+		installationInfo,
+		installationUpgradeAvailable,
+		installationUpdateWindowOpen,
+	)
+}
+
+// startPhase starts timing (and tracing) a phase of the reconciliation, returning the
+// context for the phase and a function that must be called (with the error, if any)
+// when the phase is done
+func startPhase(ctx context.Context, phase string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, phase)
+	return ctx, func(err error) {
+		reconcilePhaseDuration.WithLabelValues(phase).Observe(time.Since(start).Seconds())
+		span.RecordError(err)
+		span.End()
+	}
+}
+
+// recordOutcome counts the outcome of a reconciliation from the condition set in the status
+func recordOutcome(condition ambassador.AmbInsCondition) {
+	if len(condition.Reason) > 0 {
+		reconcileOutcomes.WithLabelValues(string(condition.Type), string(condition.Reason)).Inc()
+	}
+}
+
+func labelsFor(o *unstructured.Unstructured) prometheus.Labels {
+	return prometheus.Labels{"kind": o.GetKind(), "namespace": o.GetNamespace(), "name": o.GetName()}
+}
+
+func keyFor(o *unstructured.Unstructured) string {
+	return o.GetKind() + "/" + o.GetNamespace() + "/" + o.GetName()
+}
+
+// updateInstallationMetrics updates the metrics of an installation from its status
+func updateInstallationMetrics(o *unstructured.Unstructured, status *ambassador.AmbassadorInstallationStatus) {
+	labels := labelsFor(o)
+
+	upgradeAvailable := 0.0
+	if status.NextUpgrade != nil {
+		upgradeAvailable = 1.0
+	}
+	installationUpgradeAvailable.With(labels).Set(upgradeAvailable)
+
+	installationInfoLabelsMutex.Lock()
+	defer installationInfoLabelsMutex.Unlock()
+
+	key := keyFor(o)
+	if prev, ok := installationInfoLabels[key]; ok {
+		installationInfo.Delete(prev)
+		delete(installationInfoLabels, key)
+	}
+	if r := status.DeployedRelease; r != nil {
+		infoLabels := labelsFor(o)
+		infoLabels["app_version"] = r.AppVersion
+		infoLabels["chart_version"] = r.Version
+		infoLabels["flavor"] = r.Flavor
+		installationInfo.With(infoLabels).Set(1)
+		installationInfoLabels[key] = infoLabels
+	}
+}
+
+// setUpdateWindowMetric records if the update window of an installation is open
+func setUpdateWindowMetric(o *unstructured.Unstructured, open bool) {
+	value := 0.0
+	if open {
+		value = 1.0
+	}
+	installationUpdateWindowOpen.With(labelsFor(o)).Set(value)
+}
+
+// deleteInstallationMetrics removes all the metrics of an installation (ie, when it is deleted)
+func deleteInstallationMetrics(o *unstructured.Unstructured) {
+	labels := labelsFor(o)
+	installationUpgradeAvailable.Delete(labels)
+	installationUpdateWindowOpen.Delete(labels)
+
+	installationInfoLabelsMutex.Lock()
+	defer installationInfoLabelsMutex.Unlock()
+
+	key := keyFor(o)
+	if prev, ok := installationInfoLabels[key]; ok {
+		installationInfo.Delete(prev)
+		delete(installationInfoLabels, key)
+	}
+}
@@ -0,0 +1,81 @@
+package ambassadorinstallation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+)
+
+func TestRecordOutcome(t *testing.T) {
+	counter := reconcileOutcomes.WithLabelValues(string(ambassador.ConditionReleaseFailed), string(ambassador.ReasonDownloadError))
+	before := testutil.ToFloat64(counter)
+
+	recordOutcome(ambassador.AmbInsCondition{
+		Type:   ambassador.ConditionReleaseFailed,
+		Status: ambassador.StatusTrue,
+		Reason: ambassador.ReasonDownloadError,
+	})
+	recordOutcome(ambassador.AmbInsCondition{
+		Type:   ambassador.ConditionInitialized,
+		Status: ambassador.StatusTrue,
+	})
+
+	if got := testutil.ToFloat64(counter); got != before+1 {
+		t.Errorf("outcomes counter is %v, expected %v", got, before+1)
+	}
+}
+
+func TestUpdateInstallationMetrics(t *testing.T) {
+	ambIns := newTestAmbInst("metrics", time.Now(), map[string]interface{}{})
+	labels := labelsFor(&ambIns)
+
+	status := &ambassador.AmbassadorInstallationStatus{
+		DeployedRelease: &ambassador.AmbassadorRelease{AppVersion: "1.4.0", Version: "6.3.0", Flavor: flavorAES},
+		NextUpgrade:     &ambassador.AmbassadorUpgrade{AppVersion: "1.5.0", Version: "6.4.0"},
+	}
+	updateInstallationMetrics(&ambIns, status)
+	if got := testutil.ToFloat64(installationUpgradeAvailable.With(labels)); got != 1 {
+		t.Errorf("upgrade available is %v, expected 1", got)
+	}
+
+	// after an upgrade, the info metric only has the new versions
+	status.DeployedRelease = &ambassador.AmbassadorRelease{AppVersion: "1.5.0", Version: "6.4.0", Flavor: flavorAES}
+	status.NextUpgrade = nil
+	updateInstallationMetrics(&ambIns, status)
+	if got := testutil.ToFloat64(installationUpgradeAvailable.With(labels)); got != 0 {
+		t.Errorf("upgrade available is %v, expected 0", got)
+	}
+	if n := countMetrics(installationInfo); n != 1 {
+		t.Errorf("expected 1 info metric, got %d", n)
+	}
+	if got := installationInfoLabels[keyFor(&ambIns)]["app_version"]; got != "1.5.0" {
+		t.Errorf("info metric with app_version %q, expected 1.5.0", got)
+	}
+
+	setUpdateWindowMetric(&ambIns, true)
+	deleteInstallationMetrics(&ambIns)
+	if n := countMetrics(installationInfo); n != 0 {
+		t.Errorf("expected no info metrics after deletion, got %d", n)
+	}
+	if n := countMetrics(installationUpdateWindowOpen); n != 0 {
+		t.Errorf("expected no update window metrics after deletion, got %d", n)
+	}
+}
+
+// countMetrics returns the number of metrics in a collector
+func countMetrics(c prometheus.Collector) int {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	n := 0
+	for range ch {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,284 @@
+package ambassadorinstallation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/helm/release"
+)
+
+// PatchError is the error returned when a patch in `spec.patches` cannot be applied
+type PatchError struct {
+	// Index is the index of the patch in `spec.patches`
+	Index int
+	// Resource is the resource patched (as `Kind/name`)
+	Resource string
+	Err      error
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("could not apply spec.patches[%d] to %s: %v", e.Index, e.Resource, e.Err)
+}
+
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// isPatchError returns true when an error has been caused by a patch that could not be applied.
+// Helm wraps errors with github.com/pkg/errors, so both `Unwrap()` and `Cause()` are followed.
+func isPatchError(err error) bool {
+	for err != nil {
+		if _, ok := err.(*PatchError); ok {
+			return true
+		}
+		if c, ok := err.(interface{ Cause() error }); ok && c.Cause() != err {
+			err = c.Cause()
+			continue
+		}
+		err = errors.Unwrap(err)
+	}
+	return false
+}
+
+// failureReason returns the reason for a failed release: ReasonPatchError when some
+// patch could not be applied, ReasonVerificationError when the release did not pass
+// the verification, or `reason` otherwise
+func failureReason(err error, reason ambassador.AmbInsConditionReason) ambassador.AmbInsConditionReason {
+	switch {
+	case isPatchError(err):
+		return ambassador.ReasonPatchError
+	case isVerificationError(err):
+		return ambassador.ReasonVerificationError
+	}
+	return reason
+}
+
+// the group of the Ambassador resources (ie, `Module`, `Host` or `Mapping`): all of them can be patched
+const ambassadorGroup = "getambassador.io"
+
+// patchableKinds are the kinds (besides the Ambassador resources) that can be patched. Cluster-wide
+// resources, like the RBAC rules, the CRDs or the webhooks installed by the chart, cannot be patched.
+var patchableKinds = map[schema.GroupKind]bool{
+	{Group: "", Kind: "ConfigMap"}:                           true,
+	{Group: "", Kind: "Secret"}:                              true,
+	{Group: "", Kind: "Service"}:                             true,
+	{Group: "", Kind: "ServiceAccount"}:                      true,
+	{Group: "apps", Kind: "DaemonSet"}:                       true,
+	{Group: "apps", Kind: "Deployment"}:                      true,
+	{Group: "apps", Kind: "StatefulSet"}:                     true,
+	{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"}:  true,
+	{Group: "batch", Kind: "Job"}:                            true,
+	{Group: "extensions", Kind: "Ingress"}:                   true,
+	{Group: "monitoring.coreos.com", Kind: "ServiceMonitor"}: true,
+	{Group: "networking.k8s.io", Kind: "Ingress"}:            true,
+	{Group: "policy", Kind: "PodDisruptionBudget"}:           true,
+}
+
+// isPatchable returns true if the resources of some kind can be patched
+func isPatchable(gk schema.GroupKind) bool {
+	return gk.Group == ambassadorGroup || patchableKinds[gk]
+}
+
+// isPatchableTarget returns true if a target can match some resource that can be patched
+func isPatchableTarget(t ambassador.AmbassadorPatchTarget) bool {
+	if len(t.Kind) == 0 || t.Group == ambassadorGroup {
+		return true
+	}
+	for gk := range patchableKinds {
+		if gk.Kind == t.Kind && (len(t.Group) == 0 || gk.Group == t.Group) {
+			return true
+		}
+	}
+	return false
+}
+
+// compiledPatch is a patch with its target parsed
+type compiledPatch struct {
+	index     int
+	target    ambassador.AmbassadorPatchTarget
+	selector  labels.Selector
+	patchType ambassador.AmbassadorPatchType
+	patch     []byte // in JSON
+}
+
+// compilePatch parses a patch, filling the target from the patch itself when it is
+// a strategic merge patch without a target
+func compilePatch(index int, p ambassador.AmbassadorPatch) (compiledPatch, error) {
+	c := compiledPatch{index: index, patchType: p.Type, selector: labels.Everything()}
+	if len(c.patchType) == 0 {
+		c.patchType = ambassador.PatchStrategicMerge
+	}
+
+	patch, err := yaml.YAMLToJSON([]byte(p.Patch))
+	if err != nil {
+		return c, fmt.Errorf("could not parse patch: %w", err)
+	}
+	c.patch = patch
+
+	switch c.patchType {
+	case ambassador.PatchStrategicMerge:
+		u := &unstructured.Unstructured{}
+		if err := json.Unmarshal(patch, &u.Object); err != nil {
+			return c, fmt.Errorf("the strategic merge patch must be an object: %w", err)
+		}
+		if p.Target == nil {
+			if len(u.GetKind()) == 0 || len(u.GetName()) == 0 {
+				return c, errors.New("a target (or a kind and a metadata.name in the patch) must be provided")
+			}
+			gv, _ := schema.ParseGroupVersion(u.GetAPIVersion())
+			c.target = ambassador.AmbassadorPatchTarget{Group: gv.Group, Version: gv.Version, Kind: u.GetKind(), Name: u.GetName()}
+		}
+	case ambassador.PatchJSON6902:
+		if _, err := jsonpatch.DecodePatch(patch); err != nil {
+			return c, fmt.Errorf("the JSON6902 patch must be a list of operations: %w", err)
+		}
+		if p.Target == nil {
+			return c, errors.New("a target must be provided for JSON6902 patches")
+		}
+	default:
+		return c, fmt.Errorf("unknown patch type %q", p.Type)
+	}
+
+	if p.Target != nil {
+		c.target = *p.Target
+		if len(c.target.LabelSelector) > 0 {
+			selector, err := labels.Parse(c.target.LabelSelector)
+			if err != nil {
+				return c, fmt.Errorf("could not parse label selector: %w", err)
+			}
+			c.selector = selector
+		}
+	}
+	if !isPatchableTarget(c.target) {
+		return c, fmt.Errorf("resources of kind %q cannot be patched", c.target.Kind)
+	}
+	return c, nil
+}
+
+// matches returns true if the patch targets the resource (and the resource can be patched)
+func (c compiledPatch) matches(u *unstructured.Unstructured) bool {
+	gvk := u.GroupVersionKind()
+	t := c.target
+	switch {
+	case !isPatchable(gvk.GroupKind()),
+		len(t.Group) > 0 && t.Group != gvk.Group,
+		len(t.Version) > 0 && t.Version != gvk.Version,
+		len(t.Kind) > 0 && t.Kind != gvk.Kind,
+		len(t.Name) > 0 && t.Name != u.GetName():
+		return false
+	}
+	return c.selector.Matches(labels.Set(u.GetLabels()))
+}
+
+// apply applies the patch to a resource
+func (c compiledPatch) apply(u *unstructured.Unstructured) error {
+	original, err := json.Marshal(u.Object)
+	if err != nil {
+		return err
+	}
+
+	var patched []byte
+	switch c.patchType {
+	case ambassador.PatchJSON6902:
+		p, err := jsonpatch.DecodePatch(c.patch)
+		if err != nil {
+			return err
+		}
+		if patched, err = p.Apply(original); err != nil {
+			return err
+		}
+	default:
+		// use the strategic merge information of the built-in types, or a JSON merge
+		// patch for the types we do not know about
+		if obj, err := scheme.Scheme.New(u.GroupVersionKind()); err == nil {
+			patched, err = strategicpatch.StrategicMergePatch(original, c.patch, obj)
+			if err != nil {
+				return err
+			}
+		} else if patched, err = jsonpatch.MergePatch(original, c.patch); err != nil {
+			return err
+		}
+	}
+
+	res := map[string]interface{}{}
+	if err := json.Unmarshal(patched, &res); err != nil {
+		return err
+	}
+	u.Object = res
+	return nil
+}
+
+// newPatchesPostRenderer returns a post-renderer that applies the `spec.patches` to
+// the resources rendered by the chart
+func newPatchesPostRenderer(patches []ambassador.AmbassadorPatch) (release.PostRenderer, error) {
+	compiled := make([]compiledPatch, 0, len(patches))
+	for i, p := range patches {
+		c, err := compilePatch(i, p)
+		if err != nil {
+			return nil, &PatchError{Index: i, Resource: "<none>", Err: err}
+		}
+		compiled = append(compiled, c)
+	}
+
+	return release.PostRendererFunc(func(u *unstructured.Unstructured) error {
+		for _, c := range compiled {
+			if !c.matches(u) {
+				continue
+			}
+			if err := c.apply(u); err != nil {
+				return &PatchError{Index: c.index, Resource: u.GetKind() + "/" + u.GetName(), Err: err}
+			}
+		}
+		return nil
+	}), nil
+}
+
+// patchesFor returns the `spec.patches` of an AmbassadorInstallation
+func patchesFor(o *unstructured.Unstructured) ([]ambassador.AmbassadorPatch, error) {
+	ambIns, err := unsToInstallation(o)
+	if err != nil {
+		return nil, err
+	}
+	return ambIns.GetSpec().Patches, nil
+}
+
+// patchesDigest returns the SHA-256 digest of the `spec.patches` of an AmbassadorInstallation
+// (empty when there are no patches). It is recorded in the deployed release, so a change in
+// the patches requires an upgrade (even when the manifests of the chart have not changed).
+func patchesDigest(o *unstructured.Unstructured) string {
+	patches, _, _ := unstructured.NestedSlice(o.Object, "spec", "patches")
+	if len(patches) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(patches)
+	if err != nil {
+		return ""
+	}
+	digest := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(digest[:])
+}
+
+// isUpdateRequired returns true when the release must be upgraded: when the manifests of the
+// chart have changed, or when the patches are not the ones in the deployed release
+func isUpdateRequired(o *unstructured.Unstructured, status *ambassador.AmbassadorInstallationStatus, chart release.Manager) bool {
+	if chart.IsUpdateRequired() {
+		return true
+	}
+	deployed := ""
+	if status.DeployedRelease != nil {
+		deployed = status.DeployedRelease.PatchesDigest
+	}
+	return chart.IsInstalled() && deployed != patchesDigest(o)
+}
@@ -0,0 +1,275 @@
+package ambassadorinstallation
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/helm/release"
+)
+
+const testDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ambassador
+  labels:
+    app.kubernetes.io/name: ambassador
+spec:
+  template:
+    spec:
+      containers:
+      - name: ambassador
+        image: quay.io/datawire/aes:1.5.0
+`
+
+func newTestResource(t *testing.T, manifest string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal([]byte(manifest), &u.Object); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestPatchesPostRenderer(t *testing.T) {
+	tests := []struct {
+		description string
+		patch       ambassador.AmbassadorPatch
+		path        []string
+		expected    interface{}
+	}{
+		{
+			description: "strategic merge patch with the target in the patch",
+			patch: ambassador.AmbassadorPatch{Patch: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ambassador
+spec:
+  template:
+    spec:
+      containers:
+      - name: sidecar
+        image: envoyproxy/envoy:v1.14.1
+`},
+			path: []string{"spec", "template", "spec", "containers"},
+			expected: []interface{}{
+				map[string]interface{}{"name": "sidecar", "image": "envoyproxy/envoy:v1.14.1"},
+				map[string]interface{}{"name": "ambassador", "image": "quay.io/datawire/aes:1.5.0"},
+			},
+		},
+		{
+			description: "strategic merge patch with a label selector",
+			patch: ambassador.AmbassadorPatch{
+				Target: &ambassador.AmbassadorPatchTarget{Kind: "Deployment", LabelSelector: "app.kubernetes.io/name=ambassador"},
+				Patch:  `{"spec": {"template": {"spec": {"tolerations": [{"key": "dedicated", "operator": "Exists"}]}}}}`,
+			},
+			path:     []string{"spec", "template", "spec", "tolerations"},
+			expected: []interface{}{map[string]interface{}{"key": "dedicated", "operator": "Exists"}},
+		},
+		{
+			description: "JSON6902 patch",
+			patch: ambassador.AmbassadorPatch{
+				Type:   ambassador.PatchJSON6902,
+				Target: &ambassador.AmbassadorPatchTarget{Group: "apps", Kind: "Deployment", Name: "ambassador"},
+				Patch: `
+- op: add
+  path: /metadata/annotations
+  value:
+    example.com/team: edge
+`,
+			},
+			path:     []string{"metadata", "annotations"},
+			expected: map[string]interface{}{"example.com/team": "edge"},
+		},
+		{
+			description: "target not matching",
+			patch: ambassador.AmbassadorPatch{
+				Type:   ambassador.PatchJSON6902,
+				Target: &ambassador.AmbassadorPatchTarget{Kind: "Deployment", LabelSelector: "app.kubernetes.io/name=redis"},
+				Patch:  `[{"op": "add", "path": "/metadata/annotations", "value": {"a": "b"}}]`,
+			},
+			path: []string{"metadata", "annotations"},
+		},
+	}
+
+	for _, test := range tests {
+		p, err := newPatchesPostRenderer([]ambassador.AmbassadorPatch{test.patch})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.description, err)
+			continue
+		}
+		u := newTestResource(t, testDeployment)
+		if err := p.PostRender(u); err != nil {
+			t.Errorf("%s: unexpected error: %v", test.description, err)
+			continue
+		}
+		value, _, _ := unstructured.NestedFieldNoCopy(u.Object, test.path...)
+		if !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("%s: got %v, expected %v", test.description, value, test.expected)
+		}
+	}
+}
+
+func TestPatchesPostRendererCustomResource(t *testing.T) {
+	p, err := newPatchesPostRenderer([]ambassador.AmbassadorPatch{{Patch: `
+apiVersion: getambassador.io/v2
+kind: Module
+metadata:
+  name: ambassador
+spec:
+  config:
+    diagnostics:
+      enabled: false
+`}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	u := newTestResource(t, `
+apiVersion: getambassador.io/v2
+kind: Module
+metadata:
+  name: ambassador
+spec:
+  config:
+    enable_grpc_web: true
+`)
+	if err := p.PostRender(u); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]interface{}{
+		"enable_grpc_web": true,
+		"diagnostics":     map[string]interface{}{"enabled": false},
+	}
+	if config, _, _ := unstructured.NestedMap(u.Object, "spec", "config"); !reflect.DeepEqual(config, expected) {
+		t.Errorf("got %v, expected %v", config, expected)
+	}
+}
+
+func TestPatchErrors(t *testing.T) {
+	invalid := []ambassador.AmbassadorPatch{
+		{Patch: `{"spec": {}}`},
+		{Type: ambassador.PatchJSON6902, Patch: `[{"op": "add", "path": "/a", "value": 1}]`},
+		{Type: ambassador.PatchJSON6902, Target: &ambassador.AmbassadorPatchTarget{Kind: "Deployment"}, Patch: `{"a": 1}`},
+		{Target: &ambassador.AmbassadorPatchTarget{LabelSelector: "a in (b"}, Patch: `{"a": 1}`},
+		{Type: "Unknown", Patch: `{}`},
+		{Target: &ambassador.AmbassadorPatchTarget{Kind: "ClusterRole"}, Patch: `{"rules": []}`},
+		{Patch: `{"kind": "ClusterRoleBinding", "metadata": {"name": "ambassador"}, "subjects": []}`},
+	}
+	for i, p := range invalid {
+		if _, err := newPatchesPostRenderer([]ambassador.AmbassadorPatch{p}); !isPatchError(err) {
+			t.Errorf("patch %d: expected a PatchError, got %v", i, err)
+		}
+	}
+
+	// a patch that cannot be applied to a resource
+	p, err := newPatchesPostRenderer([]ambassador.AmbassadorPatch{{
+		Type:   ambassador.PatchJSON6902,
+		Target: &ambassador.AmbassadorPatchTarget{Kind: "Deployment"},
+		Patch:  `[{"op": "replace", "path": "/spec/missing/field", "value": 1}]`,
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = p.PostRender(newTestResource(t, testDeployment))
+	if !isPatchError(err) {
+		t.Fatalf("expected a PatchError, got %v", err)
+	}
+
+	// errors wrapped by Helm (with github.com/pkg/errors) and by us are detected
+	wrapped := fmt.Errorf("failed to install release: %w", errors.Wrap(err, "unable to build kubernetes objects"))
+	if reason := failureReason(wrapped, ambassador.ReasonInstallError); reason != ambassador.ReasonPatchError {
+		t.Errorf("failureReason() = %s, expected %s", reason, ambassador.ReasonPatchError)
+	}
+	if reason := failureReason(fmt.Errorf("some error"), ambassador.ReasonInstallError); reason != ambassador.ReasonInstallError {
+		t.Errorf("failureReason() = %s, expected %s", reason, ambassador.ReasonInstallError)
+	}
+}
+
+func TestPatchesNotPatchableKinds(t *testing.T) {
+	p, err := newPatchesPostRenderer([]ambassador.AmbassadorPatch{{
+		Target: &ambassador.AmbassadorPatchTarget{LabelSelector: "app.kubernetes.io/name=ambassador"},
+		Patch:  `{"metadata": {"annotations": {"patched": "true"}}}`,
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	clusterRole := newTestResource(t, `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: ambassador
+  labels:
+    app.kubernetes.io/name: ambassador
+`)
+	deployment := newTestResource(t, testDeployment)
+	for _, u := range []*unstructured.Unstructured{clusterRole, deployment} {
+		if err := p.PostRender(u); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if _, ok := clusterRole.GetAnnotations()["patched"]; ok {
+		t.Errorf("the ClusterRole has been patched")
+	}
+	if _, ok := deployment.GetAnnotations()["patched"]; !ok {
+		t.Errorf("the Deployment has not been patched")
+	}
+}
+
+// testReleaseManager is a release.Manager that only knows if the release is installed and up to date
+type testReleaseManager struct {
+	release.Manager
+	installed      bool
+	updateRequired bool
+}
+
+func (m testReleaseManager) IsInstalled() bool      { return m.installed }
+func (m testReleaseManager) IsUpdateRequired() bool { return m.updateRequired }
+
+func TestIsUpdateRequiredForPatches(t *testing.T) {
+	now := time.Now()
+	withoutPatches := newTestAmbInst("ambassador", now, map[string]interface{}{})
+	withPatches := newTestAmbInst("ambassador", now, map[string]interface{}{
+		"patches": []interface{}{map[string]interface{}{"patch": `{"kind": "Service", "metadata": {"name": "ambassador"}}`}},
+	})
+	if digest := patchesDigest(&withoutPatches); digest != "" {
+		t.Errorf("digest %q for an installation without patches", digest)
+	}
+	digest := patchesDigest(&withPatches)
+	if !strings.HasPrefix(digest, "sha256:") {
+		t.Fatalf("unexpected digest %q", digest)
+	}
+
+	installed := testReleaseManager{installed: true}
+	tests := []struct {
+		description string
+		o           *unstructured.Unstructured
+		deployed    string
+		chart       release.Manager
+		expected    bool
+	}{
+		{"same patches", &withPatches, digest, installed, false},
+		{"no patches", &withoutPatches, "", installed, false},
+		{"patches added", &withPatches, "", installed, true},
+		{"patches changed", &withPatches, "sha256:other", installed, true},
+		{"patches removed", &withoutPatches, digest, installed, true},
+		{"chart changed", &withPatches, digest, testReleaseManager{installed: true, updateRequired: true}, true},
+		{"not installed", &withPatches, "", testReleaseManager{}, false},
+	}
+	for _, test := range tests {
+		status := &ambassador.AmbassadorInstallationStatus{
+			DeployedRelease: &ambassador.AmbassadorRelease{PatchesDigest: test.deployed},
+		}
+		if required := isUpdateRequired(test.o, status, test.chart); required != test.expected {
+			t.Errorf("%s: update required %t, expected %t", test.description, required, test.expected)
+		}
+	}
+}
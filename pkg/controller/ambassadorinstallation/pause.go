@@ -0,0 +1,95 @@
+package ambassadorinstallation
+
+import (
+	"os"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+)
+
+const (
+	// PausedAnnotation pauses the reconciliation of an installation when set to "true"
+	// (the same as `spec.paused: true`)
+	PausedAnnotation = "getambassador.io/paused"
+
+	// PauseReconciliationEnvVar is the environment variable for pausing the reconciliation
+	// of all the installations managed by the operator
+	PauseReconciliationEnvVar = "AMB_PAUSE_RECONCILIATION"
+)
+
+// reasons for the Events that do not correspond to a condition reason
+const (
+	eventReasonPaused  = "Paused"
+	eventReasonResumed = "Resumed"
+)
+
+// getEnvPaused returns true when the reconciliation of all the installations has been
+// paused in the environment
+func getEnvPaused() bool {
+	e := os.Getenv(PauseReconciliationEnvVar)
+	if len(e) == 0 {
+		return false
+	}
+	paused, err := strconv.ParseBool(e)
+	if err != nil {
+		log.Error(err, "Could not parse boolean from environ variable: IGNORED", "name", PauseReconciliationEnvVar, "value", e)
+		return false
+	}
+	return paused
+}
+
+// pausedCondition returns the Paused condition for an installation, and true when the
+// reconciliation of the installation has been paused (in the operator, in the spec or
+// with an annotation)
+func (r *ReconcileAmbassadorInstallation) pausedCondition(o *unstructured.Unstructured,
+	spec *ambassador.AmbassadorInstallationSpec) (ambassador.AmbInsCondition, bool) {
+	condition := ambassador.AmbInsCondition{
+		Type:   ambassador.ConditionPaused,
+		Status: ambassador.StatusTrue,
+	}
+	switch {
+	case r.paused:
+		condition.Reason = ambassador.ReasonPausedByOperator
+		condition.Message = "reconciliation paused in the operator (" + PauseReconciliationEnvVar + ")"
+	case spec.Paused:
+		condition.Reason = ambassador.ReasonPausedBySpec
+		condition.Message = "reconciliation paused with spec.paused"
+	case isPausedByAnnotation(o):
+		condition.Reason = ambassador.ReasonPausedByAnnotation
+		condition.Message = "reconciliation paused with the " + PausedAnnotation + " annotation"
+	default:
+		return ambassador.AmbInsCondition{}, false
+	}
+	return condition, true
+}
+
+// isPausedByAnnotation returns true when the PausedAnnotation is set to "true"
+func isPausedByAnnotation(o *unstructured.Unstructured) bool {
+	paused, err := strconv.ParseBool(o.GetAnnotations()[PausedAnnotation])
+	return err == nil && paused
+}
+
+// setPaused sets the Paused condition in the status, recording an Event when the
+// installation was not paused before
+func (r *ReconcileAmbassadorInstallation) setPaused(o *unstructured.Unstructured,
+	status *ambassador.AmbassadorInstallationStatus, condition ambassador.AmbInsCondition) {
+	if prev := status.FindCondition(ambassador.ConditionPaused); prev == nil || prev.Status != ambassador.StatusTrue {
+		r.recordEvent(o, corev1.EventTypeNormal, eventReasonPaused, "Reconciliation paused: %s", condition.Message)
+	}
+	r.setCondition(o, status, condition)
+}
+
+// clearPaused removes the Paused condition from the status, recording an Event when
+// the installation was paused before. It returns true when the status has been modified.
+func (r *ReconcileAmbassadorInstallation) clearPaused(o *unstructured.Unstructured,
+	status *ambassador.AmbassadorInstallationStatus) bool {
+	if prev := status.FindCondition(ambassador.ConditionPaused); prev == nil {
+		return false
+	}
+	r.recordEvent(o, corev1.EventTypeNormal, eventReasonResumed, "Reconciliation resumed")
+	status.RemoveCondition(ambassador.ConditionPaused)
+	return true
+}
@@ -0,0 +1,209 @@
+package ambassadorinstallation
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/datawire/ambassador-operator/pkg/apis"
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+)
+
+func TestPausedCondition(t *testing.T) {
+	tests := []struct {
+		description    string
+		operatorPaused bool
+		spec           ambassador.AmbassadorInstallationSpec
+		annotations    map[string]string
+		expectedReason ambassador.AmbInsConditionReason
+		expectedPaused bool
+	}{
+		{description: "not paused", expectedPaused: false},
+		{description: "paused in the operator", operatorPaused: true, expectedPaused: true, expectedReason: ambassador.ReasonPausedByOperator},
+		{description: "paused in the spec", spec: ambassador.AmbassadorInstallationSpec{Paused: true}, expectedPaused: true, expectedReason: ambassador.ReasonPausedBySpec},
+		{description: "paused with an annotation", annotations: map[string]string{PausedAnnotation: "true"}, expectedPaused: true, expectedReason: ambassador.ReasonPausedByAnnotation},
+		{description: "annotation set to false", annotations: map[string]string{PausedAnnotation: "false"}, expectedPaused: false},
+	}
+
+	for _, test := range tests {
+		r := &ReconcileAmbassadorInstallation{paused: test.operatorPaused}
+		ambIns := newTestAmbInst("ambassador", time.Now(), map[string]interface{}{})
+		ambIns.SetAnnotations(test.annotations)
+
+		condition, paused := r.pausedCondition(&ambIns, &test.spec)
+		if paused != test.expectedPaused {
+			t.Errorf("%s: got paused=%v, expected %v", test.description, paused, test.expectedPaused)
+			continue
+		}
+		if paused && (condition.Type != ambassador.ConditionPaused || condition.Reason != test.expectedReason) {
+			t.Errorf("%s: unexpected condition %+v", test.description, condition)
+		}
+	}
+}
+
+func TestPauseResumeStatus(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	r := &ReconcileAmbassadorInstallation{EventRecorder: recorder, paused: true}
+	ambIns := newTestAmbInst("ambassador", time.Now(), map[string]interface{}{})
+	status := &ambassador.AmbassadorInstallationStatus{}
+	status.SetCondition(ambassador.AmbInsCondition{Type: ambassador.ConditionDeployed, Status: ambassador.StatusTrue,
+		Reason: ambassador.ReasonInstallSuccessful})
+
+	condition, _ := r.pausedCondition(&ambIns, &ambassador.AmbassadorInstallationSpec{})
+	r.setPaused(&ambIns, status, condition)
+	r.setPaused(&ambIns, status, condition)
+
+	// the Paused condition does not change the readiness or the last condition
+	status.SetReady(1)
+	if ready := status.FindCondition(ambassador.ConditionReady); ready.Status != ambassador.StatusTrue {
+		t.Errorf("unexpected Ready condition while paused: %+v", ready)
+	}
+	if last := status.LastCondition(ambassador.AmbInsCondition{}); last.Type != ambassador.ConditionDeployed {
+		t.Errorf("unexpected last condition: %s", last.Type)
+	}
+
+	if !r.clearPaused(&ambIns, status) || r.clearPaused(&ambIns, status) {
+		t.Errorf("the status should be modified only the first time")
+	}
+	if status.FindCondition(ambassador.ConditionPaused) != nil {
+		t.Errorf("the Paused condition was not removed")
+	}
+
+	// only the transitions are recorded as Events
+	expected := []string{
+		"Normal Paused Reconciliation paused: " + condition.Message,
+		"Normal Resumed Reconciliation resumed",
+	}
+	if len(recorder.Events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(recorder.Events))
+	}
+	for _, e := range expected {
+		if got := <-recorder.Events; got != e {
+			t.Errorf("got event %q, expected %q", got, e)
+		}
+	}
+}
+
+func TestGetEnvPaused(t *testing.T) {
+	defer os.Unsetenv(PauseReconciliationEnvVar)
+
+	for value, expected := range map[string]bool{"": false, "true": true, "1": true, "false": false, "whatever": false} {
+		os.Setenv(PauseReconciliationEnvVar, value)
+		if got := getEnvPaused(); got != expected {
+			t.Errorf("%s=%q: got %v, expected %v", PauseReconciliationEnvVar, value, got, expected)
+		}
+	}
+}
+
+func TestSpecHashIgnoresPaused(t *testing.T) {
+	ambIns := newTestAmbInst("ambassador", time.Now(), map[string]interface{}{"version": "1.*"})
+	hash, err := getCurrSpecHash(&ambIns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paused := newTestAmbInst("ambassador", time.Now(), map[string]interface{}{"version": "1.*", "paused": true})
+	if pausedHash, err := getCurrSpecHash(&paused); err != nil || pausedHash != hash {
+		t.Errorf("pausing changed the spec hash: %q -> %q (%v)", hash, pausedHash, err)
+	}
+
+	changed := newTestAmbInst("ambassador", time.Now(), map[string]interface{}{"version": "2.*", "paused": true})
+	if changedHash, err := getCurrSpecHash(&changed); err != nil || changedHash == hash {
+		t.Errorf("the spec hash did not change: %q (%v)", changedHash, err)
+	}
+}
+
+// jsonStatusClient is a fake client that encodes the status as JSON before updating it,
+// as the real client does (the fake client cannot copy the typed status in an Unstructured)
+type jsonStatusClient struct {
+	client.Client
+}
+
+func (c jsonStatusClient) Status() client.StatusWriter {
+	return jsonStatusWriter{c.Client.Status()}
+}
+
+type jsonStatusWriter struct {
+	client.StatusWriter
+}
+
+func (w jsonStatusWriter) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return w.StatusWriter.Update(ctx, obj, opts...)
+	}
+	data, err := json.Marshal(u.Object)
+	if err != nil {
+		return err
+	}
+	encoded := &unstructured.Unstructured{}
+	if err := json.Unmarshal(data, &encoded.Object); err != nil {
+		return err
+	}
+	return w.StatusWriter.Update(ctx, encoded, opts...)
+}
+
+func TestReconcilePausedDoesNotUpgrade(t *testing.T) {
+	// an installation already applied, with an update window that is not open now
+	ambIns := newTestAmbInst("ambassador", time.Now(), map[string]interface{}{
+		"version":      "1.*",
+		"updateWindow": "Never",
+	})
+	ambIns.SetFinalizers([]string{defFinalizerID})
+	hash, err := getCurrSpecHash(&ambIns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ambIns.SetAnnotations(map[string]string{previousAppliedAnnot: hash})
+
+	// paused as `kubectl ambassador pause` does
+	if err := unstructured.SetNestedField(ambIns.Object, true, "spec", "paused"); err != nil {
+		t.Fatal(err)
+	}
+
+	s := runtime.NewScheme()
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	c := jsonStatusClient{fake.NewFakeClientWithScheme(s, &ambIns)}
+	r := &ReconcileAmbassadorInstallation{
+		Client:        c,
+		GVK:           DefaultGVK,
+		selector:      labels.Everything(),
+		EventRecorder: record.NewFakeRecorder(10),
+	}
+
+	key := types.NamespacedName{Namespace: ambIns.GetNamespace(), Name: ambIns.GetName()}
+	if _, err := r.reconcile(context.Background(), reconcile.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	current, err := r.lookupAmbInst(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := ambassador.StatusFor(current)
+	if paused := status.FindCondition(ambassador.ConditionPaused); paused == nil || paused.Reason != ambassador.ReasonPausedBySpec {
+		t.Errorf("unexpected Paused condition %+v", paused)
+	}
+	if status.DeployedRelease != nil || len(status.History) != 0 {
+		t.Errorf("a release was performed while paused: %+v", status)
+	}
+
+	// resuming is not a change in the spec, so the update window is not ignored
+	unstructured.RemoveNestedField(current.Object, "spec", "paused")
+	if hasChangedSpec(current) {
+		t.Errorf("resuming the reconciliation was considered a change in the spec")
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	rpb "helm.sh/helm/v3/pkg/release"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
@@ -17,6 +18,8 @@ import (
 
 	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
 	"github.com/datawire/ambassador-operator/pkg/helm"
+	"github.com/datawire/ambassador-operator/pkg/helm/release"
+	"github.com/datawire/ambassador-operator/pkg/tracing"
 )
 
 // note: base on the code of the Helm operator:
@@ -77,12 +80,10 @@ var (
 
 var (
 	// DefaultGVK is the GVK used by the AmbassadorInstallation
-	// TODO: we should get these details from the CRD or somewhere...
-	DefaultGVK = schema.GroupVersionKind{
-		Group:   "getambassador.io",
-		Version: "v2",
-		Kind:    "AmbassadorInstallation",
-	}
+	DefaultGVK = ambassador.SchemeGroupVersion.WithKind("AmbassadorInstallation")
+
+	// ClusterGVK is the GVK used by the ClusterAmbassadorInstallation
+	ClusterGVK = ambassador.SchemeGroupVersion.WithKind("ClusterAmbassadorInstallation")
 )
 
 // ReconcileAmbassadorInstallation reconciles a AmbassadorInstallation object
@@ -94,31 +95,51 @@ type ReconcileAmbassadorInstallation struct {
 	Manager            manager.Manager
 	EventRecorder      record.EventRecorder
 	GVK                schema.GroupVersionKind
+	selector           labels.Selector
+	namespaces         []string
 	Scout              *Scout
+	reporter           Reporter
 	releaseHook        ReleaseHookFunc
 	checkInterval      time.Duration
 	updateInterval     time.Duration
 	lastSucUpdateCheck time.Time
+	paused             bool
+
+	// releases stuck in a pending status for longer than this are recovered
+	stalePendingThreshold time.Duration
 }
 
-func NewReconcileAmbassadorInstallation(mgr manager.Manager) *ReconcileAmbassadorInstallation {
+// NewReconcileAmbassadorInstallation creates a new reconciler for the installations of the given GVK
+// (DefaultGVK or ClusterGVK)
+func NewReconcileAmbassadorInstallation(mgr manager.Manager, gvk schema.GroupVersionKind) *ReconcileAmbassadorInstallation {
 	checkInterval, checkIntervalSrc := getEnvDuration(defaultCheckIntervalEnvVar, defaultCheckInterval)
 	updateInterval, updateIntervalSrc := getEnvDuration(defaultUpdateIntervalEnvVar, defaultUpdateInterval)
+	stalePendingThreshold, stalePendingThresholdSrc := getEnvDuration(stalePendingThresholdEnvVar, release.DefaultStalePendingThreshold)
 
 	log.Info("Intervals",
 		"check", checkInterval, "checkIntervalSrc", checkIntervalSrc,
-		"update", updateInterval, "updateIntervalSrc", updateIntervalSrc)
+		"update", updateInterval, "updateIntervalSrc", updateIntervalSrc,
+		"stalePendingThreshold", stalePendingThreshold, "stalePendingThresholdSrc", stalePendingThresholdSrc)
+
+	paused := getEnvPaused()
+	if paused {
+		log.Info("Reconciliation paused: no installations will be installed or upgraded", "envVar", PauseReconciliationEnvVar)
+	}
 
 	return &ReconcileAmbassadorInstallation{
 		Manager:            mgr,
 		Client:             mgr.GetClient(),
 		Scheme:             mgr.GetScheme(),
 		EventRecorder:      mgr.GetEventRecorderFor(defControllerName),
-		GVK:                DefaultGVK,
+		GVK:                gvk,
+		selector:           labels.Everything(),
 		checkInterval:      checkInterval,
 		updateInterval:     updateInterval,
 		lastSucUpdateCheck: time.Time{},
 		Scout:              nil,
+		paused:             paused,
+
+		stalePendingThreshold: stalePendingThreshold,
 	}
 }
 
@@ -128,13 +149,28 @@ func NewReconcileAmbassadorInstallation(mgr manager.Manager) *ReconcileAmbassado
 // The Controller will requeue the Request to be processed again if the returned error is non-nil or
 // Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
 func (r *ReconcileAmbassadorInstallation) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	ctx, span := tracing.Start(context.Background(), "Reconcile",
+		tracing.String("installation.namespace", request.Namespace),
+		tracing.String("installation.name", request.Name))
+	defer span.End()
+
+	res, err := r.reconcile(ctx, request)
+	span.RecordError(err)
+	return res, err
+}
+
+// reconcile does the real work for Reconcile, under the span in the context
+func (r *ReconcileAmbassadorInstallation) reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
 	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
 
 	// ...and log it.
 	reqLogger.Info("Reconciling AmbassadorInstallation")
 
 	ambInstName := types.NamespacedName{Name: request.Name, Namespace: request.Namespace}
+	_, lookupSpan := tracing.Start(ctx, "lookup")
 	ambIns, err := r.lookupAmbInst(ambInstName)
+	lookupSpan.RecordError(err)
+	lookupSpan.End()
 
 	if err != nil {
 		log.Error(err, "Failed to lookup resource")
@@ -146,18 +182,28 @@ func (r *ReconcileAmbassadorInstallation) Reconcile(request reconcile.Request) (
 		return reconcile.Result{}, nil
 	}
 
+	// ignore installations that do not match our label selector: they are managed by some other operator
+	if !r.selector.Matches(labels.Set(ambIns.GetLabels())) {
+		reqLogger.V(1).Info("AmbassadorInstallation does not match the label selector: ignored", "selector", r.selector.String())
+		return reconcile.Result{}, nil
+	}
+
 	deleted := ambIns.GetDeletionTimestamp() != nil
 	pendingFinalizers := ambIns.GetFinalizers()
 
 	// get some values we want to override from the CR
 	// check the values we can set in https://github.com/datawire/ambassador-chart/#configuration
-	ambObj, err := unsToAmbIns(ambIns)
+	ambObj, err := unsToInstallation(ambIns)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
 
-	spec := ambObj.Spec
+	spec := ambObj.GetSpec()
 	status := ambassador.StatusFor(ambIns)
+	tracing.SpanFromContext(ctx).SetAttributes(
+		tracing.String("installation.kind", ambIns.GetKind()),
+		tracing.String("installation.version", spec.Version),
+		tracing.Bool("installation.deleted", deleted))
 	specHelmValues := GetHelmValuesAmbIns(ambIns) // values passes in the spec: just for reading
 
 	// check if there are finalizers installed for this instance: if not, install our finalizer.
@@ -166,7 +212,10 @@ func (r *ReconcileAmbassadorInstallation) Reconcile(request reconcile.Request) (
 		finalizers := append(pendingFinalizers, defFinalizerID)
 		ambIns.SetFinalizers(finalizers)
 
-		err = r.Client.Update(context.TODO(), ambIns)
+		finCtx, finSpan := tracing.Start(ctx, "addFinalizer")
+		err = r.Client.Update(finCtx, ambIns)
+		finSpan.RecordError(err)
+		finSpan.End()
 
 		// Need to requeue because finalizer update does not change metadata.generation
 		return reconcile.Result{Requeue: true}, err
@@ -176,53 +225,68 @@ func (r *ReconcileAmbassadorInstallation) Reconcile(request reconcile.Request) (
 	// IMPORTANT: no calls to r.ReportEvent, r.ReportError are allowed before this point.
 	// IMPORTANT: `ambIns` can have nil-UUID when it has been removed, so make sure we
 	//            have `return`ed by now.
-	r.BeginReporting("reconcile", ambIns.GetUID())
+	r.BeginReporting(ctx, "reconcile", ambIns.GetUID())
 
 	// Report beginning the reconciliation process to Metriton
 	r.ReportEvent("start_reconciliation")
 
 	// create a new parsed checker for versions
 	chartVersion, err := helm.NewChartVersionRule(spec.Version)
+	if err != nil && deleted {
+		// a wrong version must not prevent the removal of the AmbassadorInstallation:
+		// the version is not really relevant when uninstalling, so just use any version.
+		reqLogger.Info("Could not parse version: ignored while deleting", "version", spec.Version)
+		chartVersion, err = helm.NewChartVersionRule("")
+	}
 	if err != nil {
 		message := fmt.Sprintf("could not parse version from %q", spec.Version)
 
 		// Report to Metriton
 		r.ReportError("fail_parse_chart_version", message, err)
 
-		status.SetCondition(ambassador.AmbInsCondition{
+		r.setCondition(ambIns, status, ambassador.AmbInsCondition{
 			Type:    ambassador.ConditionReleaseFailed,
 			Status:  ambassador.StatusTrue,
 			Reason:  ambassador.ReasonParametersError,
 			Message: message,
 		})
 
-		// FIXME: this will return an error before reaching the `if delete...`, so it will make
-		//        impossible to delete an AmbassadorInstallation with a wrong Version
-		_ = r.updateResourceStatus(ambIns, status)
+		_ = r.updateResourceStatus(ctx, ambIns, status)
 		return reconcile.Result{}, err
 	}
 
-	var chartName string
-	isV2 := false
-	// if versions greater than 2.0.0-ea are allowed, change the chart name
-	ok, err := chartVersion.Allowed("2.0.0-ea")
-	if err == nil && ok {
-		isV2 = true
-		if spec.InstallOSS {
-			chartName = helm.DefaultEmissaryChartName
-		} else {
-			chartName = helm.DefaultEdgeStackChartName
+	// create a parsed checker for the chart version (only when provided)
+	var chartVersionRule helm.ChartVersionRule
+	if len(spec.ChartVersion) > 0 && !deleted {
+		chartVersionRule, err = helm.NewChartVersionRule(spec.ChartVersion)
+		if err != nil {
+			message := fmt.Sprintf("could not parse chart version from %q", spec.ChartVersion)
+
+			// Report to Metriton
+			r.ReportError("fail_parse_chart_version_rule", message, err)
+
+			r.setCondition(ambIns, status, ambassador.AmbInsCondition{
+				Type:    ambassador.ConditionReleaseFailed,
+				Status:  ambassador.StatusTrue,
+				Reason:  ambassador.ReasonParametersError,
+				Message: message,
+			})
+
+			_ = r.updateResourceStatus(ctx, ambIns, status)
+			return reconcile.Result{}, err
 		}
-	} else {
-		chartName = helm.DefaultChartName
 	}
 
+	chartName, isV2 := ChartNameFor(chartVersion, spec.InstallOSS)
+
 	options := HelmManagerOptions{
-		Manager: r.Manager,
+		Manager:               r.Manager,
+		StalePendingThreshold: r.stalePendingThreshold,
 		DownloaderOptions: helm.DownloaderOptions{
-			URL:       spec.HelmRepo,
-			Version:   chartVersion,
-			ChartName: chartName,
+			URL:          spec.HelmRepo,
+			Version:      chartVersion,
+			ChartVersion: chartVersionRule,
+			ChartName:    chartName,
 		},
 	}
 	// create a new manager for the remote Helm repo URL
@@ -237,46 +301,71 @@ func (r *ReconcileAmbassadorInstallation) Reconcile(request reconcile.Request) (
 	//            the removal of the `AmbassasdorInstallation`
 	if deleted {
 		reqLogger.Info("AmbassadorInstallation deleted: uninstalling Ambassador")
-		return r.deleteRelease(ambIns, pendingFinalizers, chartsMgr)
+		return r.deleteRelease(ctx, ambIns, pendingFinalizers, chartsMgr)
 	}
 
-	// check if this AmbassadorInstallation was marked as a Duplicate in the past
-	lastDuplicateCondition := status.LastCondition(ambassador.AmbInsCondition{Reason: ambassador.ReasonDuplicateError})
-	if lastDuplicateCondition.Reason == ambassador.ReasonDuplicateError {
-		reqLogger.Info("AmbassadorInstallation marked as duplicate: ignored")
-		return reconcile.Result{}, nil
+	// do not perform any Helm action when the reconciliation has been paused, but keep the
+	// status up to date (the installation will be reconciled again when it is resumed)
+	if paused, ok := r.pausedCondition(ambIns, spec); ok {
+		reqLogger.Info("Reconciliation paused: ignoring AmbassadorInstallation", "reason", paused.Reason)
+		r.setPaused(ambIns, status, paused)
+		return reconcile.Result{}, r.updateResourceStatus(ctx, ambIns, status)
+	}
+	if r.clearPaused(ambIns, status) {
+		if err := r.updateResourceStatus(ctx, ambIns, status); err != nil {
+			return reconcile.Result{}, err
+		}
 	}
 
 	lastCondition := status.LastCondition(ambassador.AmbInsCondition{})
 	log.V(2).Info("Last condition",
 		"type", lastCondition.Type, "reason", lastCondition.Reason, "status", lastCondition.Status)
 
-	// check if this is the first and only AmbassadorInstallation in this namespace
-	// if it is not, mark the status as Duplicate
-	isFirstAmbIns, err := r.isFirstAmbInst(ambIns)
+	// check if some other AmbassadorInstallation in this namespace is using the same
+	// release name or ambassador_id: if that is the case, mark the status as Duplicate.
+	// Note that this is checked in every reconciliation, so a Duplicate will be
+	// installed as soon as the blocking AmbassadorInstallation is removed.
+	installations, err := r.listInstallations(ambIns)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
-	if !isFirstAmbIns {
-		message := fmt.Sprintf("There is a previous AmbassadorInstallation in this namespace. Disabling this one.")
+	if blocker, conflict := findBlockingAmbInst(installations, ambIns); blocker != nil {
+		message := fmt.Sprintf("%s %q is using the same %s in namespace %q. Disabling this one.",
+			blocker.GetKind(), blocker.GetName(), conflict, targetNamespaceFor(ambIns))
 
 		// Report to Metriton
 		r.ReportEvent("disabling_previous_installation", ScoutMeta{"message", message})
 
-		status.SetCondition(ambassador.AmbInsCondition{
+		r.setCondition(ambIns, status, ambassador.AmbInsCondition{
 			Type:    ambassador.ConditionIrreconcilable,
 			Status:  ambassador.StatusFalse,
 			Reason:  ambassador.ReasonDuplicateError,
 			Message: message,
 		})
 
-		return reconcile.Result{}, r.updateResourceStatus(ambIns, status)
+		return reconcile.Result{}, r.updateResourceStatus(ctx, ambIns, status)
+	}
+
+	// the release cannot be renamed: the installation will be reconciled again when
+	// the `spec.releaseName` is reverted
+	if message := releaseNameChange(ambIns); len(message) > 0 {
+		// Report to Metriton
+		r.ReportEvent("fail_release_name_change", ScoutMeta{"message", message})
+
+		r.setCondition(ambIns, status, ambassador.AmbInsCondition{
+			Type:    ambassador.ConditionReleaseFailed,
+			Status:  ambassador.StatusTrue,
+			Reason:  ambassador.ReasonParametersError,
+			Message: message,
+		})
+
+		return reconcile.Result{}, r.updateResourceStatus(ctx, ambIns, status)
 	}
 
 	// Condition initialized
 	r.ReportEvent("condition_initialized")
 
-	status.SetCondition(ambassador.AmbInsCondition{
+	r.setCondition(ambIns, status, ambassador.AmbInsCondition{
 		Type:   ambassador.ConditionInitialized,
 		Status: ambassador.StatusTrue,
 	})
@@ -332,14 +421,14 @@ func (r *ReconcileAmbassadorInstallation) Reconcile(request reconcile.Request) (
 					ScoutMeta{"enableAES", enableAES},
 					ScoutMeta{"installOSS", enableOSS})
 
-				status.SetCondition(ambassador.AmbInsCondition{
+				r.setCondition(ambIns, status, ambassador.AmbInsCondition{
 					Type:    ambassador.ConditionReleaseFailed,
 					Status:  ambassador.StatusTrue,
 					Reason:  ambassador.ReasonParametersError,
 					Message: message,
 				})
 
-				_ = r.updateResourceStatus(ambIns, status)
+				_ = r.updateResourceStatus(ctx, ambIns, status)
 				return reconcile.Result{}, fmt.Errorf(message)
 			}
 		}
@@ -355,14 +444,14 @@ func (r *ReconcileAmbassadorInstallation) Reconcile(request reconcile.Request) (
 			// Report to Metriton
 			r.ReportError("fail_parse_image", message, err)
 
-			status.SetCondition(ambassador.AmbInsCondition{
+			r.setCondition(ambIns, status, ambassador.AmbInsCondition{
 				Type:    ambassador.ConditionReleaseFailed,
 				Status:  ambassador.StatusTrue,
 				Reason:  ambassador.ReasonParametersError,
 				Message: message,
 			})
 
-			_ = r.updateResourceStatus(ambIns, status)
+			_ = r.updateResourceStatus(ctx, ambIns, status)
 			return reconcile.Result{}, err
 		}
 		reqLogger.Info("Using custom base image", "repo", repo, "tag", tag)
@@ -424,6 +513,11 @@ func (r *ReconcileAmbassadorInstallation) Reconcile(request reconcile.Request) (
 		helmValuesStrings["pro.logLevel"] = spec.LogLevel
 	}
 
+	if len(spec.AmbassadorID) > 0 {
+		reqLogger.Info("Using custom ambassador_id", "ambassadorID", spec.AmbassadorID)
+		helmValuesStrings["env."+ambassadorIDEnvVar] = spec.AmbassadorID
+	}
+
 	// get an update window from the arguments in the CRD
 	window, err := NewUpdateWindow(spec.UpdateWindow)
 	if err != nil {
@@ -435,26 +529,45 @@ func (r *ReconcileAmbassadorInstallation) Reconcile(request reconcile.Request) (
 		// ...and log the error as well.
 		reqLogger.Info(message)
 
-		status.SetCondition(ambassador.AmbInsCondition{
+		r.setCondition(ambIns, status, ambassador.AmbInsCondition{
 			Type:    ambassador.ConditionReleaseFailed,
 			Status:  ambassador.StatusTrue,
 			Reason:  ambassador.ReasonParametersError,
 			Message: message,
 		})
 
-		_ = r.updateResourceStatus(ambIns, status)
+		_ = r.updateResourceStatus(ctx, ambIns, status)
 		return reconcile.Result{}, err
 	}
 
 	r.ReportEvent("completed_reconciliation")
-	return r.tryInstallOrUpdate(ambIns, chartsMgr, window, helmValuesStrings, isMigrating, specChanged, flavor)
+	return r.tryInstallOrUpdate(ctx, ambIns, chartsMgr, window, helmValuesStrings, isMigrating, specChanged, flavor)
+}
+
+// ChartNameFor returns the name of the chart for a version rule (and if it is an Ambassador 2.x chart):
+// when versions greater than 2.0.0-ea are allowed, the emissary-ingress or edge-stack charts are used.
+func ChartNameFor(versionRule helm.ChartVersionRule, installOSS bool) (string, bool) {
+	if ok, err := versionRule.Allowed("2.0.0-ea"); err != nil || !ok {
+		return helm.DefaultChartName, false
+	}
+	if installOSS {
+		return helm.DefaultEmissaryChartName, true
+	}
+	return helm.DefaultEdgeStackChartName, true
 }
 
 func (r *ReconcileAmbassadorInstallation) updateResource(o runtime.Object) error {
 	return r.Client.Update(context.TODO(), o)
 }
 
-func (r *ReconcileAmbassadorInstallation) updateResourceStatus(o *unstructured.Unstructured, status *ambassador.AmbassadorInstallationStatus) error {
+func (r *ReconcileAmbassadorInstallation) updateResourceStatus(ctx context.Context, o *unstructured.Unstructured, status *ambassador.AmbassadorInstallationStatus) error {
+	ctx, span := tracing.Start(ctx, "updateStatus")
+	defer span.End()
+
+	status.SetReady(o.GetGeneration())
 	o.Object["status"] = status
-	return r.Client.Status().Update(context.TODO(), o)
+	updateInstallationMetrics(o, status)
+	err := r.Client.Status().Update(ctx, o)
+	span.RecordError(err)
+	return err
 }
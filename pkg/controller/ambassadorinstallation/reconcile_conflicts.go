@@ -0,0 +1,366 @@
+package ambassadorinstallation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/helm/release"
+)
+
+const (
+	// the ambassador_id used by Ambassador when no AMBASSADOR_ID is provided
+	defaultAmbassadorID = "default"
+
+	// the environment variable used for setting the ambassador_id
+	ambassadorIDEnvVar = "AMBASSADOR_ID"
+
+	// max number of conflicting resources reported in a condition message
+	maxReportedConflicts = 5
+)
+
+// releaseNameFor returns the Helm release name requested for an AmbassadorInstallation:
+// the name of the release currently deployed, or the `spec.releaseName` when nothing
+// has been deployed yet. An empty string means that the default name must be used.
+func releaseNameFor(o *unstructured.Unstructured) string {
+	status := ambassador.StatusFor(o)
+	if status.DeployedRelease != nil && len(status.DeployedRelease.Name) > 0 {
+		return status.DeployedRelease.Name
+	}
+	releaseName, _, _ := unstructured.NestedString(o.Object, "spec", "releaseName")
+	return releaseName
+}
+
+// releaseNameChange returns a description of the change in the `spec.releaseName` of an
+// AmbassadorInstallation that has already been deployed with some other release name, or an
+// empty string when the release name has not changed. A release cannot be renamed.
+func releaseNameChange(o *unstructured.Unstructured) string {
+	status := ambassador.StatusFor(o)
+	if status.DeployedRelease == nil || len(status.DeployedRelease.Name) == 0 {
+		return ""
+	}
+	releaseName, _, _ := unstructured.NestedString(o.Object, "spec", "releaseName")
+	if len(releaseName) == 0 || releaseName == status.DeployedRelease.Name {
+		return ""
+	}
+	return fmt.Sprintf("spec.releaseName cannot be changed once Ambassador has been deployed (from %q to %q)",
+		status.DeployedRelease.Name, releaseName)
+}
+
+// effectiveReleaseName returns the Helm release name used by an AmbassadorInstallation
+func effectiveReleaseName(o *unstructured.Unstructured) string {
+	if releaseName := releaseNameFor(o); len(releaseName) > 0 {
+		return releaseName
+	}
+	return o.GetName()
+}
+
+// effectiveAmbassadorID returns the ambassador_id used by an AmbassadorInstallation, looking at
+// the `spec.ambassadorID` and, if not present, at the `AMBASSADOR_ID` in the `helmValues`
+func effectiveAmbassadorID(o *unstructured.Unstructured) string {
+	if id, _, _ := unstructured.NestedString(o.Object, "spec", "ambassadorID"); len(id) > 0 {
+		return id
+	}
+	if helmValues := GetHelmValuesAmbIns(o); helmValues != nil {
+		if id, _, _ := unstructured.NestedString(helmValues, "env", ambassadorIDEnvVar); len(id) > 0 {
+			return id
+		}
+		if id, ok := helmValues["env."+ambassadorIDEnvVar].(string); ok && len(id) > 0 {
+			return id
+		}
+	}
+	return defaultAmbassadorID
+}
+
+// conflictBetween returns a description of what is shared between two AmbassadorInstallations
+// that prevents them from being installed in the same namespace, or an empty string
+// when they can live together.
+func conflictBetween(a, b *unstructured.Unstructured) string {
+	if targetNamespaceFor(a) != targetNamespaceFor(b) {
+		return ""
+	}
+	if releaseName := effectiveReleaseName(a); releaseName == effectiveReleaseName(b) {
+		return fmt.Sprintf("release name %q", releaseName)
+	}
+	if id := effectiveAmbassadorID(a); id == effectiveAmbassadorID(b) {
+		return fmt.Sprintf("ambassador_id %q", id)
+	}
+	return ""
+}
+
+// findBlockingAmbInst looks for the AmbassadorInstallation that prevents `o` from being installed
+// (ie, it uses the same release name or ambassador_id), returning the blocker as well as a
+// description of the conflict.
+// AmbassadorInstallations are considered from the oldest to the most recent one, so the
+// oldest installation always wins. Installations that are blocked by some other installation
+// do not block other installations.
+func findBlockingAmbInst(installations []unstructured.Unstructured, o *unstructured.Unstructured) (*unstructured.Unstructured, string) {
+	sorted := make([]unstructured.Unstructured, len(installations))
+	copy(sorted, installations)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ti, tj := sorted[i].GetCreationTimestamp(), sorted[j].GetCreationTimestamp()
+		if !ti.Equal(&tj) {
+			return ti.Before(&tj)
+		}
+		return sorted[i].GetName() < sorted[j].GetName()
+	})
+
+	active := []*unstructured.Unstructured{}
+	for i := range sorted {
+		current := &sorted[i]
+		isTarget := current.GetUID() == o.GetUID()
+		if isTarget {
+			current = o
+		}
+
+		var blocker *unstructured.Unstructured
+		conflict := ""
+		for _, a := range active {
+			if conflict = conflictBetween(a, current); len(conflict) > 0 {
+				blocker = a
+				break
+			}
+		}
+
+		if isTarget {
+			return blocker, conflict
+		}
+		if blocker == nil {
+			active = append(active, current)
+		}
+	}
+	return nil, ""
+}
+
+// isBlockedAmbInst returns true if the AmbassadorInstallation has been disabled because of
+// some other AmbassadorInstallation in the same namespace
+func isBlockedAmbInst(status *ambassador.AmbassadorInstallationStatus) bool {
+	for _, reason := range []ambassador.AmbInsConditionReason{ambassador.ReasonDuplicateError, ambassador.ReasonResourceConflictError} {
+		if status.LastCondition(ambassador.AmbInsCondition{Reason: reason}).Reason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// wellKnownClusterScopedKinds are the kinds of cluster-scoped resources that can be found in
+// the charts, used when the scope of a kind cannot be obtained from the API server
+var wellKnownClusterScopedKinds = map[string]bool{
+	"APIService":                     true,
+	"ClusterRole":                    true,
+	"ClusterRoleBinding":             true,
+	"CustomResourceDefinition":       true,
+	"MutatingWebhookConfiguration":   true,
+	"Namespace":                      true,
+	"PodSecurityPolicy":              true,
+	"PriorityClass":                  true,
+	"StorageClass":                   true,
+	"ValidatingWebhookConfiguration": true,
+}
+
+// isClusterScoped returns true if the resources of some kind are cluster-scoped
+func (r *ReconcileAmbassadorInstallation) isClusterScoped(gvk schema.GroupVersionKind) bool {
+	if r.Manager != nil {
+		mapping, err := r.Manager.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err == nil {
+			return mapping.Scope.Name() == meta.RESTScopeNameRoot
+		}
+	}
+	return wellKnownClusterScopedKinds[gvk.Kind]
+}
+
+// manifestResources returns the list of resources in a manifest, as `Kind/namespace/name` (or as
+// `Kind/name` for cluster-scoped resources). Namespaced resources without a namespace are
+// considered to be in the `namespace` provided.
+func manifestResources(manifest string, namespace string, isClusterScoped func(schema.GroupVersionKind) bool) (map[string]struct{}, error) {
+	res := map[string]struct{}{}
+	for _, doc := range releaseutil.SplitManifests(manifest) {
+		u := unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(doc), &u.Object); err != nil {
+			return nil, err
+		}
+		if len(u.GetKind()) == 0 || len(u.GetName()) == 0 {
+			continue
+		}
+		if isClusterScoped(u.GroupVersionKind()) {
+			res[fmt.Sprintf("%s/%s", u.GetKind(), u.GetName())] = struct{}{}
+			continue
+		}
+		ns := u.GetNamespace()
+		if len(ns) == 0 {
+			ns = namespace
+		}
+		res[fmt.Sprintf("%s/%s/%s", u.GetKind(), ns, u.GetName())] = struct{}{}
+	}
+	return res, nil
+}
+
+// overlappingResources returns the (sorted) list of resources that are present in both manifests,
+// installed in the namespaces `namespaceA` and `namespaceB` respectively
+func overlappingResources(a, namespaceA, b, namespaceB string, isClusterScoped func(schema.GroupVersionKind) bool) ([]string, error) {
+	ra, err := manifestResources(a, namespaceA, isClusterScoped)
+	if err != nil {
+		return nil, err
+	}
+	rb, err := manifestResources(b, namespaceB, isClusterScoped)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []string{}
+	for k := range ra {
+		if _, ok := rb[k]; ok {
+			res = append(res, k)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// findResourceConflicts checks that the release that would be installed does not contain
+// resources already managed by some other AmbassadorInstallation: any of its namespaced
+// resources in the same target namespace, or any of its cluster-scoped resources in any
+// target namespace.
+// It returns a description of the conflicts found, or an empty string if there are none.
+func (r *ReconcileAmbassadorInstallation) findResourceConflicts(ctx context.Context, o *unstructured.Unstructured, chart release.Manager) (string, error) {
+	installations, err := r.listAllInstallations()
+	if err != nil {
+		return "", err
+	}
+
+	rendered, err := chart.RenderRelease(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	namespace := targetNamespaceFor(o)
+	for _, other := range installations {
+		if other.GetUID() == o.GetUID() {
+			continue
+		}
+		otherNamespace := targetNamespaceFor(&other)
+		status := ambassador.StatusFor(&other)
+		if status.DeployedRelease == nil ||
+			(otherNamespace == namespace && status.DeployedRelease.Name == chart.ReleaseName()) {
+			continue
+		}
+
+		overlap, err := overlappingResources(rendered.Manifest, namespace,
+			status.DeployedRelease.Manifest, otherNamespace, r.isClusterScoped)
+		if err != nil {
+			return "", err
+		}
+		if len(overlap) > 0 {
+			if len(overlap) > maxReportedConflicts {
+				overlap = append(overlap[:maxReportedConflicts], "...")
+			}
+			return fmt.Sprintf("resources already managed by %s %q: %s",
+				other.GetKind(), other.GetName(), strings.Join(overlap, ", ")), nil
+		}
+	}
+	return "", nil
+}
+
+// listAmbInsts returns all the installations of some GVK in a namespace (or in all the
+// namespaces when the namespace is empty)
+func (r *ReconcileAmbassadorInstallation) listAmbInsts(gvk schema.GroupVersionKind, namespace string) ([]unstructured.Unstructured, error) {
+	lst := &unstructured.UnstructuredList{}
+	lst.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+
+	log.V(3).Info("Getting list of installations", "kind", gvk.Kind, "namespace", namespace)
+	if err := r.Client.List(context.TODO(), lst, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	return lst.Items, nil
+}
+
+// listInstallations returns all the installations that could conflict with `o`: the installations
+// of the same kind in the same namespace (or all of them, for cluster-scoped installations),
+// as well as the installations of the other kind that could use the same target namespace.
+func (r *ReconcileAmbassadorInstallation) listInstallations(o *unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+	installations, err := r.listAmbInsts(r.GVK, o.GetNamespace())
+	if err != nil {
+		log.Error(err, "Failed to list resources",
+			"gkv", r.GVK.String(), "namespace", o.GetNamespace())
+		return nil, err
+	}
+
+	otherGVK, otherNamespace := ClusterGVK, ""
+	if r.GVK == ClusterGVK {
+		otherGVK, otherNamespace = DefaultGVK, targetNamespaceFor(o)
+	}
+	others, err := r.listAmbInsts(otherGVK, otherNamespace)
+	if meta.IsNoMatchError(err) {
+		// the CRD is not installed in the cluster
+		return installations, nil
+	}
+	if err != nil {
+		log.Error(err, "Failed to list resources",
+			"gkv", otherGVK.String(), "namespace", otherNamespace)
+		return nil, err
+	}
+	return append(installations, others...), nil
+}
+
+// listAllInstallations returns the installations of both kinds in all the namespaces
+func (r *ReconcileAmbassadorInstallation) listAllInstallations() ([]unstructured.Unstructured, error) {
+	installations := []unstructured.Unstructured{}
+	for _, gvk := range []schema.GroupVersionKind{DefaultGVK, ClusterGVK} {
+		lst, err := r.listAmbInsts(gvk, "")
+		if meta.IsNoMatchError(err) {
+			// the CRD is not installed in the cluster
+			continue
+		}
+		if err != nil {
+			log.Error(err, "Failed to list resources", "gkv", gvk.String())
+			return nil, err
+		}
+		installations = append(installations, lst...)
+	}
+	return installations, nil
+}
+
+// mapToBlockedAmbInsts maps an event in an installation to reconciliation requests for
+// all the installations (of the kind managed by this reconciler) in the same namespace
+// that are currently blocked, so they are re-evaluated when the blocking installation
+// goes away (or changes). Events in cluster-scoped installations are mapped to
+// installations in all the namespaces.
+func (r *ReconcileAmbassadorInstallation) mapToBlockedAmbInsts(a handler.MapObject) []reconcile.Request {
+	namespace := a.Meta.GetNamespace()
+	if r.GVK == ClusterGVK {
+		namespace = ""
+	}
+	installations, err := r.listAmbInsts(r.GVK, namespace)
+	if err != nil {
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for i := range installations {
+		other := &installations[i]
+		if other.GetUID() == a.Meta.GetUID() {
+			continue
+		}
+		if !isBlockedAmbInst(ambassador.StatusFor(other)) {
+			continue
+		}
+		log.V(1).Info("Re-evaluating blocked installation",
+			"namespace", other.GetNamespace(), "name", other.GetName(), "trigger", a.Meta.GetName())
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: other.GetNamespace(), Name: other.GetName()},
+		})
+	}
+	return requests
+}
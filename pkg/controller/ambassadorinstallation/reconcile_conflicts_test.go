@@ -0,0 +1,173 @@
+package ambassadorinstallation
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+)
+
+func newTestAmbInst(name string, created time.Time, spec map[string]interface{}) unstructured.Unstructured {
+	u := unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": spec,
+		},
+	}
+	u.SetGroupVersionKind(DefaultGVK)
+	u.SetNamespace("ambassador")
+	u.SetName(name)
+	u.SetUID(types.UID(name))
+	u.SetCreationTimestamp(metav1.NewTime(created))
+	return u
+}
+
+func TestEffectiveAmbassadorID(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		spec map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{}, defaultAmbassadorID},
+		{map[string]interface{}{"ambassadorID": "internal"}, "internal"},
+		{map[string]interface{}{"helmValues": map[string]interface{}{
+			"env": map[string]interface{}{"AMBASSADOR_ID": "external"}}}, "external"},
+		{map[string]interface{}{"helmValues": map[string]interface{}{
+			"env.AMBASSADOR_ID": "external"}}, "external"},
+		{map[string]interface{}{"ambassadorID": "internal", "helmValues": map[string]interface{}{
+			"env.AMBASSADOR_ID": "external"}}, "internal"},
+	}
+	for _, test := range tests {
+		ambIns := newTestAmbInst("ambassador", now, test.spec)
+		if got := effectiveAmbassadorID(&ambIns); got != test.want {
+			t.Errorf("effectiveAmbassadorID(%v) = %q, want %q", test.spec, got, test.want)
+		}
+	}
+}
+
+func TestFindBlockingAmbInst(t *testing.T) {
+	now := time.Now()
+
+	first := newTestAmbInst("first", now.Add(-3*time.Hour), map[string]interface{}{})
+	internal := newTestAmbInst("internal", now.Add(-2*time.Hour), map[string]interface{}{"ambassadorID": "internal"})
+	sameID := newTestAmbInst("same-id", now.Add(-time.Hour), map[string]interface{}{})
+	sameRelease := newTestAmbInst("same-release", now, map[string]interface{}{
+		"ambassadorID": "other", "releaseName": "internal"})
+	blockedByDup := newTestAmbInst("blocked-by-dup", now.Add(time.Hour), map[string]interface{}{
+		"ambassadorID": "other"})
+
+	installations := []unstructured.Unstructured{blockedByDup, sameRelease, sameID, internal, first}
+
+	tests := []struct {
+		ambIns      unstructured.Unstructured
+		wantBlocker string
+	}{
+		{first, ""},
+		{internal, ""},
+		{sameID, "first"},
+		{sameRelease, "internal"},
+		// shares the ambassador_id with `same-release`, but that one is blocked
+		{blockedByDup, ""},
+	}
+	for _, test := range tests {
+		blocker, conflict := findBlockingAmbInst(installations, &test.ambIns)
+		got := ""
+		if blocker != nil {
+			got = blocker.GetName()
+			if conflict == "" {
+				t.Errorf("no conflict description for %q", test.ambIns.GetName())
+			}
+		}
+		if got != test.wantBlocker {
+			t.Errorf("%q: blocked by %q, want %q", test.ambIns.GetName(), got, test.wantBlocker)
+		}
+	}
+}
+
+func TestOverlappingResources(t *testing.T) {
+	a := `---
+# Source: ambassador/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: ambassador
+---
+# Source: ambassador/templates/rbac.yaml
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: ambassador
+`
+	b := `---
+# Source: ambassador/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: internal-ambassador
+---
+# Source: ambassador/templates/rbac.yaml
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: ambassador
+---
+# Source: ambassador/templates/other.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: ambassador
+  namespace: other
+`
+	isClusterScoped := func(gvk schema.GroupVersionKind) bool { return wellKnownClusterScopedKinds[gvk.Kind] }
+
+	overlap, err := overlappingResources(a, "ambassador", b, "ambassador", isClusterScoped)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"ClusterRole/ambassador"}
+	if !reflect.DeepEqual(overlap, expected) {
+		t.Errorf("overlap %v, expected %v", overlap, expected)
+	}
+
+	// cluster-scoped resources conflict across target namespaces
+	overlap, err = overlappingResources(a, "other", b, "ambassador", isClusterScoped)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = []string{"ClusterRole/ambassador", "Service/other/ambassador"}
+	if !reflect.DeepEqual(overlap, expected) {
+		t.Errorf("overlap %v, expected %v", overlap, expected)
+	}
+}
+
+func TestReleaseNameChange(t *testing.T) {
+	deployed := func(releaseName string, spec map[string]interface{}) *unstructured.Unstructured {
+		o := newTestAmbInst("ambassador", time.Now(), spec)
+		if len(releaseName) > 0 {
+			o.Object["status"] = &ambassador.AmbassadorInstallationStatus{
+				DeployedRelease: &ambassador.AmbassadorRelease{Name: releaseName},
+			}
+		}
+		return &o
+	}
+
+	cases := []struct {
+		o       *unstructured.Unstructured
+		changed bool
+	}{
+		{deployed("", map[string]interface{}{"releaseName": "internal"}), false},
+		{deployed("internal", map[string]interface{}{"releaseName": "internal"}), false},
+		{deployed("ambassador", map[string]interface{}{}), false},
+		{deployed("ambassador", map[string]interface{}{"releaseName": "internal"}), true},
+	}
+	for i, c := range cases {
+		if changed := len(releaseNameChange(c.o)) > 0; changed != c.changed {
+			t.Errorf("case %d: changed %t, expected %t", i, changed, c.changed)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"helm.sh/helm/v3/pkg/storage/driver"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -14,6 +15,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/tracing"
 )
 
 const (
@@ -22,9 +24,13 @@ const (
 )
 
 // deleteRelease deletes the current release
-func (r *ReconcileAmbassadorInstallation) deleteRelease(o *unstructured.Unstructured, pendingFinalizers []string, chartsMgr HelmManager) (reconcile.Result, error) {
+func (r *ReconcileAmbassadorInstallation) deleteRelease(ctx context.Context, o *unstructured.Unstructured, pendingFinalizers []string, chartsMgr HelmManager) (reconcile.Result, error) {
+	ctx, span := tracing.Start(ctx, "deleteRelease")
+	defer span.End()
+
 	updateDeadline := time.Now().Add(defaultDeleteTimeout)
-	ctx, _ := context.WithDeadline(context.TODO(), updateDeadline)
+	ctx, cancel := context.WithDeadline(ctx, updateDeadline)
+	defer cancel()
 
 	r.ReportEvent("start_delete")
 
@@ -35,63 +41,102 @@ func (r *ReconcileAmbassadorInstallation) deleteRelease(o *unstructured.Unstruct
 
 	status := ambassador.StatusFor(o)
 
-	if err := chartsMgr.Download(); err != nil {
+	// an AmbassadorInstallation that has been blocked by some other installation (and
+	// never deployed anything) must not uninstall a release it does not own
+	if status.DeployedRelease == nil && isBlockedAmbInst(status) {
+		log.Info("AmbassadorInstallation was never installed: nothing to uninstall")
+		return r.removeFinalizer(o, pendingFinalizers)
+	}
+
+	// nothing is installed in the `render` mode (unless it was deployed before switching modes)
+	if status.DeployedRelease == nil && isRenderMode(o) {
+		log.Info("AmbassadorInstallation only rendered the manifests: nothing to uninstall")
+		return r.removeFinalizer(o, pendingFinalizers)
+	}
+
+	downloadCtx, downloadDone := startPhase(ctx, phaseDownload)
+	err := chartsMgr.Download(downloadCtx)
+	downloadDone(err)
+	if err != nil {
 		// Report to Metriton & log
 		r.ReportError("reconcile_delete_error", "Failed to download latest release", err)
 
-		status.SetCondition(ambassador.AmbInsCondition{
+		r.setCondition(o, status, ambassador.AmbInsCondition{
 			Type:    ambassador.ConditionReleaseFailed,
 			Status:  ambassador.StatusTrue,
 			Reason:  ambassador.ReasonDownloadError,
 			Message: err.Error(),
 		})
-		_ = r.updateResourceStatus(o, status)
+		_ = r.updateResourceStatus(ctx, o, status)
 
 		return reconcile.Result{RequeueAfter: r.checkInterval}, err
 	}
 	defer func() { _ = chartsMgr.Cleanup() }()
+	traceChart(ctx, chartsMgr)
+
+	// the patches are not needed for uninstalling (and an invalid patch must not block it)
+	withoutPatches := o.DeepCopy()
+	unstructured.RemoveNestedField(withoutPatches.Object, "spec", "patches")
 
-	manager, err := chartsMgr.GetManagerFor(o, HelmValuesStrings{})
+	manager, err := chartsMgr.GetManagerFor(withoutPatches, HelmValuesStrings{})
 	defer func() { _ = chartsMgr.Cleanup() }()
 	if err != nil {
 		return reconcile.Result{}, err
 	}
 
 	log.V(2).Info("Uninstalling release", "release", manager.ReleaseName())
-	_, err = manager.UninstallRelease(ctx)
+	r.recordEvent(o, corev1.EventTypeNormal, eventReasonUninstalling,
+		"Uninstalling Ambassador %s", releaseDescription(status.DeployedRelease))
+
+	uninstallCtx, uninstallDone := startPhase(ctx, phaseUninstall)
+	_, err = manager.UninstallRelease(uninstallCtx)
+	uninstallDone(err)
 	if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
 		// Report to Metriton & log
 		r.ReportError("fail_uninstall", "Failed to uninstall release", err)
 
-		status.SetCondition(ambassador.AmbInsCondition{
+		r.setCondition(o, status, ambassador.AmbInsCondition{
 			Type:    ambassador.ConditionReleaseFailed,
 			Status:  ambassador.StatusTrue,
 			Reason:  ambassador.ReasonUninstallError,
 			Message: err.Error(),
 		})
 
-		_ = r.updateResourceStatus(o, status)
+		_ = r.updateResourceStatus(ctx, o, status)
 		return reconcile.Result{}, err
 	}
 	status.RemoveCondition(ambassador.ConditionReleaseFailed)
 
+	if err := r.deleteTargetNamespace(ctx, o); err != nil {
+		// Report to Metriton & log
+		r.ReportError("fail_delete_namespace", "Failed to delete the target namespace", err)
+		return reconcile.Result{}, err
+	}
+
 	if errors.Is(err, driver.ErrReleaseNotFound) {
 		log.Info("Release not found, removing finalizer")
 	} else {
 		log.Info("Uninstalled release")
-		status.SetCondition(ambassador.AmbInsCondition{
+		r.setCondition(o, status, ambassador.AmbInsCondition{
 			Type:   ambassador.ConditionDeployed,
 			Status: ambassador.StatusFalse,
 			Reason: ambassador.ReasonUninstallSuccessful,
 		})
+		r.recordEvent(o, corev1.EventTypeNormal, string(ambassador.ReasonUninstallSuccessful),
+			"Ambassador %s uninstalled", releaseDescription(status.DeployedRelease))
 		status.DeployedRelease = nil
 	}
 
-	if err := r.updateResourceStatus(o, status); err != nil {
+	if err := r.updateResourceStatus(ctx, o, status); err != nil {
 		r.ReportError("fail_update_status", "Failed to update AmbassadorInstallation status", err)
 		return reconcile.Result{}, err
 	}
 
+	return r.removeFinalizer(o, pendingFinalizers)
+}
+
+// removeFinalizer removes our finalizer from the AmbassadorInstallation and waits for its deletion
+func (r *ReconcileAmbassadorInstallation) removeFinalizer(o *unstructured.Unstructured, pendingFinalizers []string) (reconcile.Result, error) {
 	finalizers := []string{}
 	for _, pendingFinalizer := range pendingFinalizers {
 		if pendingFinalizer != defFinalizerID {
@@ -113,6 +158,7 @@ func (r *ReconcileAmbassadorInstallation) deleteRelease(o *unstructured.Unstruct
 		return reconcile.Result{}, err
 	}
 
+	deleteInstallationMetrics(o)
 	r.ReportEvent("completed_delete")
 
 	return reconcile.Result{}, nil
@@ -0,0 +1,180 @@
+package ambassadorinstallation
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/helm/release"
+)
+
+// default namespace where a ClusterAmbassadorInstallation installs Ambassador
+const defClusterTargetNamespace = "ambassador"
+
+// targetNamespaceFor returns the namespace where Ambassador is (or will be) installed for
+// an AmbassadorInstallation: the namespace of the release currently deployed, the
+// `spec.targetNamespace` when nothing has been deployed yet, or the namespace of the
+// AmbassadorInstallation (`defClusterTargetNamespace` for a ClusterAmbassadorInstallation).
+func targetNamespaceFor(o *unstructured.Unstructured) string {
+	status := ambassador.StatusFor(o)
+	if status.DeployedRelease != nil && len(status.DeployedRelease.Namespace) > 0 {
+		return status.DeployedRelease.Namespace
+	}
+	if namespace, _, _ := unstructured.NestedString(o.Object, "spec", "targetNamespace"); len(namespace) > 0 {
+		return namespace
+	}
+	if len(o.GetNamespace()) == 0 {
+		return defClusterTargetNamespace
+	}
+	return o.GetNamespace()
+}
+
+// ensureTargetNamespace makes sure the target namespace exists when it is not the namespace
+// of the AmbassadorInstallation, creating it (and keeping its labels and annotations
+// up to date) if requested in the `spec.targetNamespaceOptions`.
+func (r *ReconcileAmbassadorInstallation) ensureTargetNamespace(o *unstructured.Unstructured) error {
+	name := targetNamespaceFor(o)
+	if name == o.GetNamespace() {
+		return nil
+	}
+
+	ambObj, err := unsToInstallation(o)
+	if err != nil {
+		return err
+	}
+	options := ambObj.GetSpec().TargetNamespaceOptions
+	create := options != nil && options.Create
+	log := log.WithValues("targetNamespace", name)
+
+	// note: use the API reader, as we do not want to cache all the namespaces in the cluster
+	ns := &corev1.Namespace{}
+	err = r.Manager.GetAPIReader().Get(context.TODO(), types.NamespacedName{Name: name}, ns)
+	if apierrors.IsNotFound(err) {
+		if !create {
+			return fmt.Errorf("target namespace %q does not exist", name)
+		}
+
+		log.Info("Creating target namespace")
+		ns.SetName(name)
+		ns.SetLabels(namespaceLabels(nil, o, options))
+		ns.SetAnnotations(mergeStringMaps(nil, options.Annotations))
+		return r.Client.Create(context.TODO(), ns)
+	}
+	if err != nil {
+		return err
+	}
+	if !create {
+		return nil
+	}
+
+	// only update namespaces we have created
+	if !isCreatedNamespace(ns, o) {
+		log.V(1).Info("Target namespace not created by this AmbassadorInstallation: labels/annotations not updated")
+		return nil
+	}
+
+	labels := namespaceLabels(ns.GetLabels(), o, options)
+	annotations := mergeStringMaps(ns.GetAnnotations(), options.Annotations)
+	if equalStringMaps(labels, ns.GetLabels()) && equalStringMaps(annotations, ns.GetAnnotations()) {
+		return nil
+	}
+
+	log.Info("Updating labels/annotations in target namespace")
+	ns.SetLabels(labels)
+	ns.SetAnnotations(annotations)
+	return r.Client.Update(context.TODO(), ns)
+}
+
+// deleteTargetNamespace deletes the target namespace of an AmbassadorInstallation that has been
+// uninstalled, but only when it was created by this installation (see ensureTargetNamespace)
+func (r *ReconcileAmbassadorInstallation) deleteTargetNamespace(ctx context.Context, o *unstructured.Unstructured) error {
+	name := targetNamespaceFor(o)
+	if name == o.GetNamespace() {
+		return nil
+	}
+	log := log.WithValues("targetNamespace", name)
+
+	ns := &corev1.Namespace{}
+	err := r.Manager.GetAPIReader().Get(ctx, types.NamespacedName{Name: name}, ns)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !isCreatedNamespace(ns, o) {
+		log.V(1).Info("Target namespace not created by this AmbassadorInstallation: not deleted")
+		return nil
+	}
+
+	log.Info("Deleting target namespace")
+	return client.IgnoreNotFound(r.Client.Delete(ctx, ns))
+}
+
+// isCreatedNamespace returns true if a namespace has been created by an AmbassadorInstallation
+// (ie, it has the owner labels of the installation)
+func isCreatedNamespace(ns *corev1.Namespace, o *unstructured.Unstructured) bool {
+	return equalStringMaps(release.OwnerLabels(o), ownerLabelsIn(ns.GetLabels()))
+}
+
+// namespaceLabels returns the labels for a target namespace, including the labels that identify the owner
+func namespaceLabels(current map[string]string, o *unstructured.Unstructured, options *ambassador.AmbassadorNamespaceOptions) map[string]string {
+	labels := mergeStringMaps(current, options.Labels)
+	return mergeStringMaps(labels, release.OwnerLabels(o))
+}
+
+// ownerLabelsIn returns the owner labels (see release.OwnerLabels) found in some labels
+func ownerLabelsIn(labels map[string]string) map[string]string {
+	res := map[string]string{}
+	for _, k := range []string{release.OwnerNameLabel, release.OwnerNamespaceLabel, release.OwnerKindLabel} {
+		if v, ok := labels[k]; ok {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// mergeStringMaps returns a new map with the values in `a`, overwritten by the values in `b`
+func mergeStringMaps(a, b map[string]string) map[string]string {
+	res := map[string]string{}
+	for k, v := range a {
+		res[k] = v
+	}
+	for k, v := range b {
+		res[k] = v
+	}
+	return res
+}
+
+// equalStringMaps returns true if both maps contain the same values (considering nil and empty maps equal)
+func equalStringMaps(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// mapOwnerLabels maps a resource to the installation pointed by its owner labels (see release.OwnerLabels)
+func (r *ReconcileAmbassadorInstallation) mapOwnerLabels(a handler.MapObject) []reconcile.Request {
+	labels := a.Meta.GetLabels()
+	name, namespace, kind := labels[release.OwnerNameLabel], labels[release.OwnerNamespaceLabel], labels[release.OwnerKindLabel]
+	if len(name) == 0 || kind != r.GVK.Kind {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}},
+	}
+}
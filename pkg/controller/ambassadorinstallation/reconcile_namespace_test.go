@@ -0,0 +1,86 @@
+package ambassadorinstallation
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+)
+
+func TestTargetNamespaceFor(t *testing.T) {
+	now := time.Now()
+
+	ambIns := newTestAmbInst("ambassador", now, map[string]interface{}{})
+	if ns := targetNamespaceFor(&ambIns); ns != "ambassador" {
+		t.Errorf("target namespace %q, expected the namespace of the AmbassadorInstallation", ns)
+	}
+
+	ambIns = newTestAmbInst("ambassador", now, map[string]interface{}{"targetNamespace": "ambassador-edge"})
+	if ns := targetNamespaceFor(&ambIns); ns != "ambassador-edge" {
+		t.Errorf("target namespace %q, expected %q", ns, "ambassador-edge")
+	}
+
+	// once deployed, the namespace of the release is used
+	ambIns.Object["status"] = &ambassador.AmbassadorInstallationStatus{
+		DeployedRelease: &ambassador.AmbassadorRelease{Name: "ambassador", Namespace: "previous"},
+	}
+	if ns := targetNamespaceFor(&ambIns); ns != "previous" {
+		t.Errorf("target namespace %q, expected %q", ns, "previous")
+	}
+}
+
+func TestNamespaceLabels(t *testing.T) {
+	ambIns := newTestAmbInst("ambassador", time.Now(), map[string]interface{}{})
+	options := &ambassador.AmbassadorNamespaceOptions{
+		Create: true,
+		Labels: map[string]string{"team": "platform", "env": "prod"},
+	}
+
+	labels := namespaceLabels(map[string]string{"env": "dev", "other": "value"}, &ambIns, options)
+	expected := map[string]string{
+		"team":                             "platform",
+		"env":                              "prod",
+		"other":                            "value",
+		"getambassador.io/owner-name":      "ambassador",
+		"getambassador.io/owner-namespace": "ambassador",
+		"getambassador.io/owner-kind":      "AmbassadorInstallation",
+	}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Errorf("labels %v, expected %v", labels, expected)
+	}
+	if !equalStringMaps(labels, expected) {
+		t.Errorf("equalStringMaps(%v, %v) = false", labels, expected)
+	}
+	if equalStringMaps(labels, options.Labels) {
+		t.Errorf("equalStringMaps(%v, %v) = true", labels, options.Labels)
+	}
+}
+
+func TestTargetNamespaceForCluster(t *testing.T) {
+	ambIns := newTestAmbInst("ambassador", time.Now(), map[string]interface{}{})
+	ambIns.SetGroupVersionKind(ClusterGVK)
+	ambIns.SetNamespace("")
+	if ns := targetNamespaceFor(&ambIns); ns != defClusterTargetNamespace {
+		t.Errorf("target namespace %q, expected %q", ns, defClusterTargetNamespace)
+	}
+}
+
+func TestIsCreatedNamespace(t *testing.T) {
+	ambIns := newTestAmbInst("ambassador", time.Now(), map[string]interface{}{})
+	other := newTestAmbInst("other", time.Now(), map[string]interface{}{})
+
+	ns := &corev1.Namespace{}
+	ns.SetLabels(namespaceLabels(nil, &ambIns, &ambassador.AmbassadorNamespaceOptions{Create: true}))
+	if !isCreatedNamespace(ns, &ambIns) {
+		t.Errorf("namespace %v not created by %q", ns.GetLabels(), ambIns.GetName())
+	}
+	if isCreatedNamespace(ns, &other) {
+		t.Errorf("namespace %v created by %q", ns.GetLabels(), other.GetName())
+	}
+	if isCreatedNamespace(&corev1.Namespace{}, &ambIns) {
+		t.Errorf("namespace without labels created by %q", ambIns.GetName())
+	}
+}
@@ -0,0 +1,284 @@
+package ambassadorinstallation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	rpb "helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/helm/release"
+	"github.com/datawire/ambassador-operator/pkg/render"
+)
+
+// suffix for the name of the default ConfigMap where the manifests are written in the `render` mode
+const renderConfigMapSuffix = "-manifests"
+
+// the maximum size of the data in a ConfigMap (see `validation.ValidateConfigMap` in Kubernetes)
+const maxConfigMapSize = 1024 * 1024
+
+// isRenderMode returns true when the installation only renders the manifests
+func isRenderMode(o *unstructured.Unstructured) bool {
+	mode, _, _ := unstructured.NestedString(o.Object, "spec", "mode")
+	return ambassador.AmbassadorInstallationMode(mode) == ambassador.ModeRender
+}
+
+// renderRelease renders the manifests of the chart (without installing anything in the cluster),
+// writes them to the destinations in `spec.render` and updates the `renderedRelease` in the status
+func (r *ReconcileAmbassadorInstallation) renderRelease(ctx context.Context, ambObj *unstructured.Unstructured,
+	status *ambassador.AmbassadorInstallationStatus, chartsMgr HelmManager, chart release.Manager,
+	window UpdateWindow, now time.Time, flavor string) (reconcile.Result, error) {
+	renderCtx, renderDone := startPhase(ctx, phaseRender)
+	rendered, err := r.writeRenderedRelease(renderCtx, ambObj, chart, flavor, now)
+	renderDone(err)
+	if err != nil {
+		// Report to Metriton & log
+		r.ReportError("fail_render", "Failed to render release", err)
+
+		r.setCondition(ambObj, status, ambassador.AmbInsCondition{
+			Type:    ambassador.ConditionReleaseFailed,
+			Status:  ambassador.StatusTrue,
+			Reason:  failureReason(err, ambassador.ReasonRenderError),
+			Message: err.Error(),
+		})
+
+		_ = r.updateResourceStatus(ctx, ambObj, status)
+		return reconcile.Result{RequeueAfter: r.checkInterval}, err
+	}
+
+	status.RemoveCondition(ambassador.ConditionReleaseFailed)
+	status.RemoveCondition(ambassador.ConditionIrreconcilable)
+	status.TimestampCheck(now)
+
+	if prev := status.RenderedRelease; prev == nil || prev.Digest != rendered.Digest {
+		r.recordEvent(ambObj, corev1.EventTypeNormal, string(ambassador.ReasonRenderSuccessful),
+			"Ambassador %s %s (chart %s) rendered to %s", flavor, rendered.AppVersion, rendered.Version,
+			strings.Join(rendered.Locations, ", "))
+	}
+	status.RenderedRelease = rendered
+
+	r.setCondition(ambObj, status, ambassador.AmbInsCondition{
+		Type:    ambassador.ConditionRendered,
+		Status:  ambassador.StatusTrue,
+		Reason:  ambassador.ReasonRenderSuccessful,
+		Message: "Manifests written to " + strings.Join(rendered.Locations, ", "),
+	})
+	r.updateAvailableVersions(&chartsMgr, status, window, now)
+
+	err = r.updateResourceStatus(ctx, ambObj, status)
+	return reconcile.Result{RequeueAfter: r.checkInterval}, err
+}
+
+// writeRenderedRelease renders the release and writes the manifests to all the destinations
+func (r *ReconcileAmbassadorInstallation) writeRenderedRelease(ctx context.Context, ambObj *unstructured.Unstructured,
+	chart release.Manager, flavor string, now time.Time) (*ambassador.AmbassadorRenderedRelease, error) {
+	sinks, err := r.renderSinksFor(ambObj)
+	if err != nil {
+		return nil, err
+	}
+
+	rel, err := chart.RenderRelease(ctx)
+	if err != nil {
+		return nil, err
+	}
+	postRenderers, err := postRenderersFor(ambObj)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := renderedManifest(rel, postRenderers)
+	if err != nil {
+		return nil, fmt.Errorf("could not post-render the manifests: %w", err)
+	}
+
+	digest := sha256.Sum256([]byte(manifest))
+	t := metav1.NewTime(now)
+	rendered := &ambassador.AmbassadorRenderedRelease{
+		Name:       rel.Name,
+		Namespace:  rel.Namespace,
+		Version:    rel.Chart.Metadata.Version,
+		AppVersion: rel.Chart.Metadata.AppVersion,
+		Flavor:     flavor,
+		Digest:     "sha256:" + hex.EncodeToString(digest[:]),
+		Time:       &t,
+	}
+
+	file := path.Join(rel.Namespace, rel.Name+".yaml")
+	message := fmt.Sprintf("Render Ambassador %s %s (chart %s) for %s %s/%s", flavor,
+		rendered.AppVersion, rendered.Version, ambObj.GetKind(), ambObj.GetNamespace(), ambObj.GetName())
+	for _, sink := range sinks {
+		location, err := sink.Write(ctx, file, []byte(manifest), message)
+		if err != nil {
+			return nil, err
+		}
+		log.Info("Manifests written", "location", location)
+		rendered.Locations = append(rendered.Locations, location)
+	}
+	return rendered, nil
+}
+
+// renderedManifest returns the full manifest of a rendered release: the CRDs in the chart,
+// the resources and the hooks (except the tests), with the post-renderers applied
+func renderedManifest(rel *rpb.Release, postRenderers []release.PostRenderer) (string, error) {
+	var b strings.Builder
+	if rel.Chart != nil {
+		for _, crd := range rel.Chart.CRDs() {
+			fmt.Fprintf(&b, "---\n# Source: %s\n%s\n", crd.Name, strings.TrimPrefix(string(crd.Data), "---\n"))
+		}
+	}
+	b.WriteString(rel.Manifest)
+	for _, h := range rel.Hooks {
+		if isTestHook(h) {
+			continue
+		}
+		fmt.Fprintf(&b, "\n---\n# Source: %s\n%s\n", h.Path, h.Manifest)
+	}
+	return release.PostRenderManifest(b.String(), postRenderers...)
+}
+
+// isTestHook returns true for the hooks that are only used by `helm test`
+func isTestHook(h *rpb.Hook) bool {
+	for _, e := range h.Events {
+		if e == rpb.HookTest {
+			return true
+		}
+	}
+	return false
+}
+
+// renderSinksFor returns the destinations where the manifests are written for an installation
+// (a ConfigMap named after the release when no destination has been provided)
+func (r *ReconcileAmbassadorInstallation) renderSinksFor(o *unstructured.Unstructured) ([]render.Sink, error) {
+	ambIns, err := unsToInstallation(o)
+	if err != nil {
+		return nil, err
+	}
+	options := ambIns.GetSpec().Render
+	if options == nil {
+		options = &ambassador.AmbassadorRenderOptions{}
+	}
+
+	var sinks []render.Sink
+	if len(options.ConfigMapName) > 0 {
+		sinks = append(sinks, newConfigMapSink(r.Manager.GetAPIReader(), r.Client, o, targetNamespaceFor(o), options.ConfigMapName))
+	}
+	if len(options.Directory) > 0 {
+		sinks = append(sinks, render.NewDirectorySink(options.Directory))
+	}
+	if options.Git != nil {
+		sink, err := render.NewGitSink(render.GitOptions{
+			URL:    options.Git.URL,
+			Branch: options.Git.Branch,
+			Path:   options.Git.Path,
+		})
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(sinks) == 0 {
+		sinks = append(sinks, newConfigMapSink(r.Manager.GetAPIReader(), r.Client, o, targetNamespaceFor(o), releaseNameFor(o)+renderConfigMapSuffix))
+	}
+	return sinks, nil
+}
+
+// configMapSink writes the manifests to a ConfigMap, labeled with the owner (and owned by it,
+// when the owner is cluster-scoped or in the same namespace). ConfigMaps without the labels
+// of the owner are never overwritten.
+type configMapSink struct {
+	reader    client.Reader
+	client    client.Client
+	owner     *unstructured.Unstructured
+	namespace string
+	name      string
+}
+
+// newConfigMapSink returns a sink that writes the manifests to a ConfigMap. The ConfigMap
+// is read with `reader` (ie, the API reader, as we do not want to cache all the ConfigMaps).
+func newConfigMapSink(reader client.Reader, c client.Client, owner *unstructured.Unstructured, namespace, name string) render.Sink {
+	return configMapSink{reader: reader, client: c, owner: owner, namespace: namespace, name: name}
+}
+
+func (s configMapSink) Write(ctx context.Context, file string, manifest []byte, _ string) (string, error) {
+	location := fmt.Sprintf("configmap:%s/%s", s.namespace, s.name)
+	key := path.Base(file)
+	if err := checkConfigMapSize(s.namespace, s.name, map[string]string{key: string(manifest)}); err != nil {
+		return "", err
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := s.reader.Get(ctx, types.NamespacedName{Namespace: s.namespace, Name: s.name}, cm)
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: s.namespace,
+				Name:      s.name,
+				Labels:    release.OwnerLabels(s.owner),
+			},
+			Data: map[string]string{key: string(manifest)},
+		}
+		if len(s.owner.GetNamespace()) == 0 || s.owner.GetNamespace() == s.namespace {
+			cm.SetOwnerReferences([]metav1.OwnerReference{*metav1.NewControllerRef(s.owner, s.owner.GroupVersionKind())})
+		}
+		if err := s.client.Create(ctx, cm); err != nil {
+			return "", fmt.Errorf("could not create ConfigMap %s/%s: %w", s.namespace, s.name, err)
+		}
+		return location, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !hasOwnerLabels(cm.GetLabels(), s.owner) {
+		return "", fmt.Errorf("the ConfigMap %s/%s already exists and it does not belong to %s %s/%s",
+			s.namespace, s.name, s.owner.GetKind(), s.owner.GetNamespace(), s.owner.GetName())
+	}
+
+	if cm.Data[key] == string(manifest) {
+		return location, nil
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[key] = string(manifest)
+	if err := checkConfigMapSize(s.namespace, s.name, cm.Data); err != nil {
+		return "", err
+	}
+	if err := s.client.Update(ctx, cm); err != nil {
+		return "", fmt.Errorf("could not update ConfigMap %s/%s: %w", s.namespace, s.name, err)
+	}
+	return location, nil
+}
+
+// hasOwnerLabels returns true if some labels contain all the owner labels of an installation
+func hasOwnerLabels(labels map[string]string, owner *unstructured.Unstructured) bool {
+	for k, v := range release.OwnerLabels(owner) {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// checkConfigMapSize checks that some data fits in a ConfigMap
+func checkConfigMapSize(namespace, name string, data map[string]string) error {
+	size := 0
+	for k, v := range data {
+		size += len(k) + len(v)
+	}
+	if size > maxConfigMapSize {
+		return fmt.Errorf("the manifests (%d bytes) do not fit in the ConfigMap %s/%s (the limit is %d bytes): "+
+			"write them to a directory or to a Git repository in spec.render", size, namespace, name, maxConfigMapSize)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package ambassadorinstallation
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	cpb "helm.sh/helm/v3/pkg/chart"
+	rpb "helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/helm/release"
+)
+
+func TestIsRenderMode(t *testing.T) {
+	now := time.Now()
+	for mode, expected := range map[string]bool{"": false, "install": false, "render": true} {
+		o := newTestAmbInst("ambassador", now, map[string]interface{}{"mode": mode})
+		if res := isRenderMode(&o); res != expected {
+			t.Errorf("isRenderMode() = %t for mode %q, expected %t", res, mode, expected)
+		}
+	}
+}
+
+func TestRenderedManifest(t *testing.T) {
+	rel := &rpb.Release{
+		Name:      "ambassador",
+		Namespace: "ambassador",
+		Chart: &cpb.Chart{
+			Metadata: &cpb.Metadata{Name: "ambassador", Version: "6.4.0", AppVersion: "1.5.0"},
+			Files: []*cpb.File{
+				{Name: "crds/mappings.yaml", Data: []byte("apiVersion: apiextensions.k8s.io/v1beta1\nkind: CustomResourceDefinition\nmetadata:\n  name: mappings.getambassador.io\n")},
+			},
+		},
+		Manifest: "---\n# Source: ambassador/templates/deployment.yaml\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: ambassador\nspec:\n  template:\n    spec:\n      containers:\n      - name: ambassador\n        image: quay.io/datawire/aes:1.5.0\n",
+		Hooks: []*rpb.Hook{
+			{Path: "ambassador/templates/tests/test-ready.yaml", Events: []rpb.HookEvent{rpb.HookTest},
+				Manifest: "apiVersion: v1\nkind: Pod\nmetadata:\n  name: ambassador-test-ready\n"},
+			{Path: "ambassador/templates/hooks/pre-upgrade.yaml", Events: []rpb.HookEvent{rpb.HookPreUpgrade},
+				Manifest: "apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: ambassador-pre-upgrade\n"},
+		},
+	}
+
+	manifest, err := renderedManifest(rel, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, expected := range []string{"mappings.getambassador.io", "kind: Deployment", "ambassador-pre-upgrade"} {
+		if !strings.Contains(manifest, expected) {
+			t.Errorf("%q not found in the manifest:\n%s", expected, manifest)
+		}
+	}
+	if strings.Contains(manifest, "ambassador-test-ready") {
+		t.Errorf("test hook found in the manifest:\n%s", manifest)
+	}
+	if crd, deployment := strings.Index(manifest, "CustomResourceDefinition"), strings.Index(manifest, "kind: Deployment"); crd > deployment {
+		t.Errorf("the CRDs are not at the beginning of the manifest:\n%s", manifest)
+	}
+
+	// post-renderers are applied to all the resources
+	manifest, err = renderedManifest(rel, []release.PostRenderer{newRegistryMirrorPostRenderer("registry.internal:5000/mirror")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(manifest, "image: registry.internal:5000/mirror/datawire/aes:1.5.0") {
+		t.Errorf("image not mirrored in the manifest:\n%s", manifest)
+	}
+	if strings.Count(manifest, "---\n") != 3 {
+		t.Errorf("expected 3 documents in the manifest:\n%s", manifest)
+	}
+}
+
+func TestConfigMapSink(t *testing.T) {
+	now := time.Now()
+	owner := newTestAmbInst("ambassador", now, map[string]interface{}{})
+	c := fake.NewFakeClient()
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: owner.GetNamespace(), Name: "ambassador-manifests"}
+
+	sink := newConfigMapSink(c, c, &owner, key.Namespace, key.Name)
+	location, err := sink.Write(ctx, "ambassador/ambassador.yaml", []byte("v1"), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "configmap:" + key.String(); location != expected {
+		t.Errorf("location %q, expected %q", location, expected)
+	}
+
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, key, cm); err != nil {
+		t.Fatalf("ConfigMap not created: %v", err)
+	}
+	if cm.Data["ambassador.yaml"] != "v1" {
+		t.Errorf("unexpected data in the ConfigMap: %v", cm.Data)
+	}
+	if refs := cm.GetOwnerReferences(); len(refs) != 1 || refs[0].Name != owner.GetName() {
+		t.Errorf("unexpected owner references %v", refs)
+	}
+	if cm.GetLabels()[release.OwnerNameLabel] != owner.GetName() {
+		t.Errorf("unexpected labels %v", cm.GetLabels())
+	}
+
+	if _, err := sink.Write(ctx, "ambassador/ambassador.yaml", []byte("v2"), ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.Get(ctx, key, cm); err != nil {
+		t.Fatal(err)
+	}
+	if cm.Data["ambassador.yaml"] != "v2" {
+		t.Errorf("ConfigMap not updated: %v", cm.Data)
+	}
+
+	// manifests that do not fit in a ConfigMap
+	large := strings.Repeat("x", maxConfigMapSize)
+	if _, err := sink.Write(ctx, "ambassador/ambassador.yaml", []byte(large), ""); err == nil || !strings.Contains(err.Error(), "do not fit") {
+		t.Errorf("expected an error for manifests larger than a ConfigMap, got %v", err)
+	}
+}
+
+func TestConfigMapSinkNotOwned(t *testing.T) {
+	owner := newTestAmbInst("ambassador", time.Now(), map[string]interface{}{})
+	key := types.NamespacedName{Namespace: owner.GetNamespace(), Name: "some-config"}
+	c := fake.NewFakeClient(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Data:       map[string]string{"config": "value"},
+	})
+	ctx := context.Background()
+
+	sink := newConfigMapSink(c, c, &owner, key.Namespace, key.Name)
+	if _, err := sink.Write(ctx, "ambassador/ambassador.yaml", []byte("v1"), ""); err == nil {
+		t.Fatalf("expected an error for a ConfigMap not created by the operator")
+	}
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, key, cm); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cm.Data["ambassador.yaml"]; ok || cm.Data["config"] != "value" {
+		t.Errorf("ConfigMap modified: %v", cm.Data)
+	}
+}
+
+func TestCurrentRelease(t *testing.T) {
+	deployed := &ambassador.AmbassadorRelease{Version: "6.3.0", AppVersion: "1.4.0"}
+	status := &ambassador.AmbassadorInstallationStatus{DeployedRelease: deployed}
+	if r := currentRelease(status); r != deployed {
+		t.Errorf("currentRelease() = %v, expected the deployed release", r)
+	}
+
+	status.RenderedRelease = &ambassador.AmbassadorRenderedRelease{Version: "6.4.0", AppVersion: "1.5.0"}
+	if r := currentRelease(status); r == nil || r.Version != "6.4.0" || r.AppVersion != "1.5.0" {
+		t.Errorf("currentRelease() = %v, expected the rendered release", r)
+	}
+}
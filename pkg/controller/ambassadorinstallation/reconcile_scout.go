@@ -1,15 +1,23 @@
 package ambassadorinstallation
 
 import (
+	"context"
+
 	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/datawire/ambassador-operator/pkg/tracing"
 )
 
 // Initialize the Scout instance and reset.
-func (r *ReconcileAmbassadorInstallation) BeginReporting(mode string, installID types.UID) {
-	r.Scout = NewScout(mode, installID)
+// When tracing is enabled, the ID of the current trace is used as the `trace_id`.
+func (r *ReconcileAmbassadorInstallation) BeginReporting(ctx context.Context, mode string, installID types.UID) {
+	r.Scout = NewScout(mode, installID, r.reporter)
+	if traceID, ok := tracing.SpanFromContext(ctx).TraceID(); ok {
+		r.Scout.baseMetadata["trace_id"] = traceID.String()
+	}
 }
 
-// ReportEvent sends an event to Metriton
+// ReportEvent sends an event to the telemetry sinks (Metriton by default)
 func (r *ReconcileAmbassadorInstallation) ReportEvent(eventName string, meta ...ScoutMeta) {
 	log.Info("[Metrics]", "event", eventName)
 	if err := r.Scout.Report(eventName, meta...); err != nil {
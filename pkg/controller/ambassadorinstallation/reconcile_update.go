@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"time"
 
+	rpb "helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/tracing"
 )
 
 // note: base on the code of the Helm operator:
@@ -29,16 +32,25 @@ const (
 	// environ var that overrides the update interval (in seconds)
 	defaultUpdateIntervalEnvVar = "AMB_UPDATE_INTERVAL"
 
+	// environ var that overrides the time after which a release stuck in a pending status is recovered
+	stalePendingThresholdEnvVar = "AMB_STALE_PENDING_THRESHOLD"
+
 	// timeout for performing the update
 	defaultUpdateTimeout = 5 * time.Minute
 )
 
 // tryInstallOrUpdate checks if we need to update the Helm chart
-func (r *ReconcileAmbassadorInstallation) tryInstallOrUpdate(ambObj *unstructured.Unstructured,
+func (r *ReconcileAmbassadorInstallation) tryInstallOrUpdate(ctx context.Context, ambObj *unstructured.Unstructured,
 	chartsMgr HelmManager, window UpdateWindow, helmValues HelmValuesStrings,
 	isMigrating bool, specChanged bool, flavor string) (reconcile.Result, error) {
-	updateDeadline := time.Now().Add(defaultUpdateTimeout)
-	ctx, _ := context.WithDeadline(context.TODO(), updateDeadline)
+	ctx, span := tracing.Start(ctx, "tryInstallOrUpdate",
+		tracing.String("installation.flavor", flavor),
+		tracing.Bool("installation.migrating", isMigrating))
+	defer span.End()
+
+	updateDeadline := time.Now().Add(updateTimeoutFor(ambObj))
+	ctx, cancel := context.WithDeadline(ctx, updateDeadline)
+	defer cancel()
 
 	r.ReportEvent("start_install_or_update")
 
@@ -52,14 +64,30 @@ func (r *ReconcileAmbassadorInstallation) tryInstallOrUpdate(ambObj *unstructure
 	// however, some exceptions will cause to ignore this time:
 	// 1) a migration from OSS to AES has been specified
 	// 2) the .spec has changed
+	// 3) an upgrade has been approved or forced (ie, with `kubectl ambassador`)
+	// 4) a reconciliation has been requested (but only the update interval is ignored)
 	ignoreTime := false
+	ignoreWindow := false
 	if isMigrating {
 		log.Info("Migrating OSS->AES: we will ignore the last check time")
-		ignoreTime = true
+		ignoreTime, ignoreWindow = true, true
 	}
 	if specChanged {
 		log.Info(".spec changes detected: we will ignore the last check time")
-		ignoreTime = true
+		ignoreTime, ignoreWindow = true, true
+	}
+	if request, ok := pendingUpgradeRequest(ambObj, status); ok {
+		log.Info("Upgrade check requested: we will ignore the last check time",
+			"request", request.description, "ignoreWindow", request.ignoreWindow)
+		ignoreTime = ignoreTime || request.ignoreInterval
+		ignoreWindow = ignoreWindow || request.ignoreWindow
+
+		// acknowledge the request, so it is handled only once
+		if acknowledgeRequests(ambObj, status, now) {
+			if err := r.updateResourceStatus(ctx, ambObj, status); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
 	}
 
 	// when Ambassador is currently happily deployed, do not continue with this upgrade check if:
@@ -68,65 +96,152 @@ func (r *ReconcileAmbassadorInstallation) tryInstallOrUpdate(ambObj *unstructure
 	// try to install/upgrade in any other case (ie, the initial installation, the deployment
 	// is in an error state, etc)
 	// We ignore this upgrade check when OSS to AES migration is set in AmbassadorInstallation
-	if (currCondition.Type == ambassador.ConditionDeployed) && !ignoreTime {
-		if !status.LastCheckTime.Time.IsZero() && now.Sub(status.LastCheckTime.Time) < r.updateInterval {
+	windowOpen := window.Allowed(now, r.checkInterval)
+	setUpdateWindowMetric(ambObj, windowOpen)
+
+	if currCondition.Type == ambassador.ConditionDeployed || currCondition.Type == ambassador.ConditionRendered {
+		if !ignoreTime && !status.LastCheckTime.Time.IsZero() && now.Sub(status.LastCheckTime.Time) < r.updateInterval {
 			log.Info("Last install/update was not so long ago", "updateInterval", r.updateInterval)
-			return reconcile.Result{RequeueAfter: r.checkInterval}, nil
+			return r.deferUpdate(ctx, ambObj, chartsMgr, window, now, false)
 		}
 
-		if !window.Allowed(now, r.checkInterval) {
+		if !ignoreWindow && !windowOpen {
 			log.V(2).Info("Update not allowed by window", "window", window)
-			return reconcile.Result{RequeueAfter: r.checkInterval}, nil
+			return r.deferUpdate(ctx, ambObj, chartsMgr, window, now, true)
 		}
 	}
 
 	// if we are supposed to migrate, check that the migration can be done (ie, no AuthService)
 	if isMigrating {
-		res, err := r.canMigrate(ambObj)
+		res, err := r.canMigrate(ctx, ambObj)
 		if err != nil {
 			return res, err
 		}
 	}
 
-	if err := chartsMgr.Download(); err != nil {
+	if err := r.ensureTargetNamespace(ambObj); err != nil {
+		// report to Metriton & log
+		r.ReportError("fail_target_namespace", "Failed to prepare the target namespace", err)
+
+		r.setCondition(ambObj, status, ambassador.AmbInsCondition{
+			Type:    ambassador.ConditionReleaseFailed,
+			Status:  ambassador.StatusTrue,
+			Reason:  ambassador.ReasonInstallError,
+			Message: err.Error(),
+		})
+
+		_ = r.updateResourceStatus(ctx, ambObj, status)
+		return reconcile.Result{RequeueAfter: r.checkInterval}, err
+	}
+
+	downloadCtx, downloadDone := startPhase(ctx, phaseDownload)
+	err := chartsMgr.Download(downloadCtx)
+	downloadDone(err)
+	if err != nil {
 		// report to Metriton & log
 		r.ReportError("fail_release_download", "Failed to download latest release", err)
 
-		status.SetCondition(ambassador.AmbInsCondition{
+		r.setCondition(ambObj, status, ambassador.AmbInsCondition{
 			Type:    ambassador.ConditionReleaseFailed,
 			Status:  ambassador.StatusTrue,
 			Reason:  ambassador.ReasonDownloadError,
 			Message: err.Error(),
 		})
 
-		_ = r.updateResourceStatus(ambObj, status)
+		_ = r.updateResourceStatus(ctx, ambObj, status)
 		return reconcile.Result{RequeueAfter: r.checkInterval}, err
 	}
 	defer func() { _ = chartsMgr.Cleanup() }()
+	traceChart(ctx, chartsMgr)
 
 	chart, err := chartsMgr.GetManagerFor(ambObj, helmValues)
 	defer func() { _ = chartsMgr.Cleanup() }()
 	if err != nil {
 		message := "when obtaining the chart manager"
 		log.Error(err, message)
+
+		// the patches are parsed when creating the manager: report them as a failure
+		if isPatchError(err) {
+			r.setCondition(ambObj, status, ambassador.AmbInsCondition{
+				Type:    ambassador.ConditionReleaseFailed,
+				Status:  ambassador.StatusTrue,
+				Reason:  ambassador.ReasonPatchError,
+				Message: err.Error(),
+			})
+			_ = r.updateResourceStatus(ctx, ambObj, status)
+		}
 		return reconcile.Result{RequeueAfter: r.checkInterval}, err
 	}
 	log := log.WithValues("release", chart.ReleaseName())
 
-	if err := chart.Sync(ctx); err != nil {
+	// do not even try to install/upgrade with some values the chart would reject
+	if err := chart.ValidateValues(); err != nil {
+		// Report to Metriton & log
+		r.ReportError("fail_values_schema", "Helm values do not match the chart schema", err)
+
+		r.setCondition(ambObj, status, ambassador.AmbInsCondition{
+			Type:    ambassador.ConditionReleaseFailed,
+			Status:  ambassador.StatusTrue,
+			Reason:  ambassador.ReasonParametersError,
+			Message: err.Error(),
+		})
+
+		_ = r.updateResourceStatus(ctx, ambObj, status)
+		return reconcile.Result{}, err
+	}
+
+	syncCtx, syncDone := startPhase(ctx, phaseSync)
+	err = chart.Sync(syncCtx)
+	syncDone(err)
+	if err != nil {
 		// Report to Metriton & log
 		r.ReportError("fail_no_sync", "Failed to sync release", err)
 
-		status.SetCondition(ambassador.AmbInsCondition{
+		r.setCondition(ambObj, status, ambassador.AmbInsCondition{
 			Type:    ambassador.ConditionIrreconcilable,
 			Status:  ambassador.StatusTrue,
-			Reason:  ambassador.ReasonReconcileError,
+			Reason:  failureReason(err, ambassador.ReasonReconcileError),
 			Message: err.Error(),
 		})
 
-		_ = r.updateResourceStatus(ambObj, status)
+		_ = r.updateResourceStatus(ctx, ambObj, status)
 		return reconcile.Result{RequeueAfter: r.checkInterval}, err
 	}
+	if recovery := chart.Recovery(); recovery != nil {
+		r.recordRecovery(ambObj, status, chartsMgr, flavor, recovery)
+	}
+
+	// in the `render` mode, nothing is installed: the manifests are only rendered
+	if isRenderMode(ambObj) {
+		return r.renderRelease(ctx, ambObj, status, chartsMgr, chart, window, now, flavor)
+	}
+	status.RemoveCondition(ambassador.ConditionRendered)
+	status.RenderedRelease = nil
+
+	// before installing/upgrading, make sure we will not take over resources
+	// managed by some other AmbassadorInstallation in this namespace
+	if !chart.IsInstalled() || isUpdateRequired(ambObj, status, chart) {
+		conflicts, err := r.findResourceConflicts(ctx, ambObj, chart)
+		if err != nil {
+			log.Error(err, "Failed to check for conflicting resources")
+			return reconcile.Result{RequeueAfter: r.checkInterval}, err
+		}
+		if len(conflicts) > 0 {
+			// Report to Metriton & log
+			r.ReportEvent("fail_resource_conflict", ScoutMeta{"message", conflicts})
+			log.Info("Release conflicts with some other installation", "conflicts", conflicts)
+
+			r.setCondition(ambObj, status, ambassador.AmbInsCondition{
+				Type:    ambassador.ConditionIrreconcilable,
+				Status:  ambassador.StatusTrue,
+				Reason:  ambassador.ReasonResourceConflictError,
+				Message: conflicts,
+			})
+
+			err = r.updateResourceStatus(ctx, ambObj, status)
+			return reconcile.Result{RequeueAfter: r.checkInterval}, err
+		}
+	}
 	status.RemoveCondition(ambassador.ConditionIrreconcilable)
 	status.TimestampCheck(now)
 
@@ -134,20 +249,32 @@ func (r *ReconcileAmbassadorInstallation) tryInstallOrUpdate(ambObj *unstructure
 		log.Info("Ambassador is not currently installed: installing...",
 			"newVersion", chartsMgr.GetVersionRule().String())
 
-		installedRelease, err := chart.InstallRelease(ctx)
+		r.recordEvent(ambObj, corev1.EventTypeNormal, eventReasonInstalling,
+			"Installing Ambassador %s (version %q)", flavor, chartsMgr.GetVersionRule().String())
+
+		installStart := time.Now()
+		installCtx, installDone := startPhase(ctx, phaseInstall)
+		installedRelease, err := chart.InstallRelease(installCtx)
+		installDone(err)
+		if err == nil {
+			err = r.verifyRelease(ctx, ambObj, status, chart, installedRelease)
+		}
+
+		status.AddHistory(newReleaseRecord(chartsMgr, flavor, historyTrigger(isMigrating, true),
+			installStart, installedRelease, err))
 
 		if err != nil {
 			// Report to Metriton & log
 			r.ReportError("fail_no_install", "Installation of a new release failed", err)
 
-			status.SetCondition(ambassador.AmbInsCondition{
+			r.setCondition(ambObj, status, ambassador.AmbInsCondition{
 				Type:    ambassador.ConditionReleaseFailed,
 				Status:  ambassador.StatusTrue,
-				Reason:  ambassador.ReasonInstallError,
+				Reason:  failureReason(err, ambassador.ReasonInstallError),
 				Message: err.Error(),
 			})
 
-			_ = r.updateResourceStatus(ambObj, status)
+			_ = r.updateResourceStatus(ctx, ambObj, status)
 			return reconcile.Result{}, err
 		}
 
@@ -176,42 +303,111 @@ func (r *ReconcileAmbassadorInstallation) tryInstallOrUpdate(ambObj *unstructure
 		r.ReportEvent("reconcile_install_complete",
 			ScoutMeta{"message", message})
 
-		status.SetCondition(ambassador.AmbInsCondition{
+		r.setCondition(ambObj, status, ambassador.AmbInsCondition{
 			Type:    ambassador.ConditionDeployed,
 			Status:  ambassador.StatusTrue,
 			Reason:  ambassador.ReasonInstallSuccessful,
 			Message: message,
 		})
 
-		status.DeployedRelease = &ambassador.AmbassadorRelease{
-			Name:       installedRelease.Name,
-			Version:    installedRelease.Chart.Metadata.Version,
-			AppVersion: installedRelease.Chart.Metadata.AppVersion,
-			Manifest:   installedRelease.Manifest,
-			Flavor:     flavor,
-		}
+		status.DeployedRelease = newAmbassadorRelease(ambObj, installedRelease, chartsMgr, flavor)
+		r.recordEvent(ambObj, corev1.EventTypeNormal, string(ambassador.ReasonInstallSuccessful),
+			"Ambassador %s %s installed", flavor, releaseDescription(status.DeployedRelease))
+		r.updateAvailableVersions(&chartsMgr, status, window, now)
 
-		err = r.updateResourceStatus(ambObj, status)
+		err = r.updateResourceStatus(ctx, ambObj, status)
 		return reconcile.Result{RequeueAfter: r.checkInterval}, err
 	}
 
-	if chart.IsUpdateRequired() {
+	if isUpdateRequired(ambObj, status, chart) {
 		log.Info("Ambassador is currently installed, but an upgrade is required",
-			"newVersion", chartsMgr.GetVersionRule().String())
+			"newVersion", chartsMgr.GetVersionRule().String(),
+			"newChartVersion", chartsMgr.GetChartVersionRule().String())
+
+		r.recordEvent(ambObj, corev1.EventTypeNormal, eventReasonUpgrading,
+			"Upgrading Ambassador from %s (version %q)",
+			releaseDescription(status.DeployedRelease), chartsMgr.GetVersionRule().String())
+
+		updateStart := time.Now()
+		versions := hookVersions{}
+		if c := chartsMgr.GetChart(); c != nil {
+			versions.toVersion, versions.toAppVersion = c.Version, c.AppVersion
+		}
+		if status.DeployedRelease != nil {
+			versions.fromAppVersion = status.DeployedRelease.AppVersion
+		}
+
+		// a failure in a pre-upgrade hook aborts the upgrade
+		if err := r.runUpgradeHooks(ctx, ambObj, status, ambassador.HookPreUpgrade, chart.ReleaseName(), versions); err != nil {
+			// Report to Metriton & log
+			r.ReportError("fail_pre_upgrade_hook", "Pre-upgrade hook failed", err)
+
+			status.AddHistory(newReleaseRecord(chartsMgr, flavor, historyTrigger(isMigrating, specChanged),
+				updateStart, nil, err))
+			r.setCondition(ambObj, status, ambassador.AmbInsCondition{
+				Type:    ambassador.ConditionReleaseFailed,
+				Status:  ambassador.StatusTrue,
+				Reason:  ambassador.ReasonHookError,
+				Message: err.Error(),
+			})
+
+			_ = r.updateResourceStatus(ctx, ambObj, status)
+			return reconcile.Result{RequeueAfter: r.checkInterval}, err
+		}
+
+		// the upgrade is not performed without the snapshot of the resources (when enabled)
+		snapshot, err := r.takeSnapshot(ctx, ambObj, status, chart.DeployedRelease())
+		if err != nil {
+			// Report to Metriton & log
+			r.ReportError("fail_snapshot", "Snapshot of the Ambassador resources failed", err)
+
+			status.AddHistory(newReleaseRecord(chartsMgr, flavor, historyTrigger(isMigrating, specChanged),
+				updateStart, nil, err))
+			r.setCondition(ambObj, status, ambassador.AmbInsCondition{
+				Type:    ambassador.ConditionReleaseFailed,
+				Status:  ambassador.StatusTrue,
+				Reason:  ambassador.ReasonSnapshotError,
+				Message: fmt.Sprintf("could not take a snapshot of the Ambassador resources: %v", err),
+			})
+
+			_ = r.updateResourceStatus(ctx, ambObj, status)
+			return reconcile.Result{RequeueAfter: r.checkInterval}, err
+		}
+
+		updateCtx, updateDone := startPhase(ctx, phaseUpdate)
+		previousRelease, updatedRelease, err := chart.UpdateRelease(updateCtx)
+		updateDone(err)
+		if err == nil {
+			err = r.verifyRelease(ctx, ambObj, status, chart, updatedRelease)
+		}
+
+		status.AddHistory(newReleaseRecord(chartsMgr, flavor, historyTrigger(isMigrating, specChanged),
+			updateStart, updatedRelease, err))
 
-		previousRelease, updatedRelease, err := chart.UpdateRelease(ctx)
 		if err != nil {
 			// Report to Metriton & log
 			r.ReportError("fail_update_release", "Release failed", err)
 
-			status.SetCondition(ambassador.AmbInsCondition{
+			switch {
+			case isVerificationError(err):
+				if r.rollbackRelease(ctx, ambObj, status, chart, chartsMgr, flavor) {
+					r.offerSnapshot(ctx, ambObj, snapshot)
+				}
+			case isRolledBackError(err):
+				// the upgrade failed in the middle, and the release manager has rolled it back
+				r.recordEvent(ambObj, corev1.EventTypeNormal, eventReasonRolledBack,
+					"Ambassador rolled back to %s after a failed upgrade", releaseDescription(status.DeployedRelease))
+				r.offerSnapshot(ctx, ambObj, snapshot)
+			}
+
+			r.setCondition(ambObj, status, ambassador.AmbInsCondition{
 				Type:    ambassador.ConditionReleaseFailed,
 				Status:  ambassador.StatusTrue,
-				Reason:  ambassador.ReasonUpdateError,
+				Reason:  failureReason(err, ambassador.ReasonUpdateError),
 				Message: err.Error(),
 			})
 
-			_ = r.updateResourceStatus(ambObj, status)
+			_ = r.updateResourceStatus(ctx, ambObj, status)
 			return reconcile.Result{}, err
 		}
 
@@ -242,21 +438,27 @@ func (r *ReconcileAmbassadorInstallation) tryInstallOrUpdate(ambObj *unstructure
 		r.ReportEvent("completed_update",
 			ScoutMeta{"message", message})
 
-		status.SetCondition(ambassador.AmbInsCondition{
+		r.setCondition(ambObj, status, ambassador.AmbInsCondition{
 			Type:    ambassador.ConditionDeployed,
 			Status:  ambassador.StatusTrue,
 			Reason:  ambassador.ReasonUpdateSuccessful,
 			Message: message,
 		})
 
-		status.DeployedRelease = &ambassador.AmbassadorRelease{
-			Name:       updatedRelease.Name,
-			Version:    updatedRelease.Chart.Metadata.Version,
-			AppVersion: updatedRelease.Chart.Metadata.AppVersion,
-			Manifest:   updatedRelease.Manifest,
-			Flavor:     flavor,
+		previousDeployed := status.DeployedRelease
+		status.DeployedRelease = newAmbassadorRelease(ambObj, updatedRelease, chartsMgr, flavor)
+		r.recordEvent(ambObj, corev1.EventTypeNormal, string(ambassador.ReasonUpdateSuccessful),
+			"Ambassador upgraded from %s to %s",
+			releaseDescription(previousDeployed), releaseDescription(status.DeployedRelease))
+
+		// the upgrade has already been performed: a failure in a post-upgrade hook is only reported
+		if err := r.runUpgradeHooks(ctx, ambObj, status, ambassador.HookPostUpgrade, chart.ReleaseName(), versions); err != nil {
+			r.ReportError("fail_post_upgrade_hook", "Post-upgrade hook failed", err)
+			r.recordEvent(ambObj, corev1.EventTypeWarning, string(ambassador.ReasonHookError), "%s", err.Error())
 		}
-		err = r.updateResourceStatus(ambObj, status)
+
+		r.updateAvailableVersions(&chartsMgr, status, window, now)
+		err = r.updateResourceStatus(ctx, ambObj, status)
 		return reconcile.Result{RequeueAfter: r.checkInterval}, err
 	}
 
@@ -268,23 +470,49 @@ func (r *ReconcileAmbassadorInstallation) tryInstallOrUpdate(ambObj *unstructure
 	// no longer being attempted.
 	status.RemoveCondition(ambassador.ConditionReleaseFailed)
 
-	expectedRelease, err := chart.ReconcileRelease(ctx)
+	reconcileStart := time.Now()
+	expectedRelease, repaired, err := chart.ReconcileRelease(ctx)
+	if err != nil || len(repaired) > 0 {
+		// only record the reconciliations that found (and fixed) some drift
+		log.Info("Release drift found", "repaired", repaired)
+		status.AddHistory(newReleaseRecord(chartsMgr, flavor, ambassador.TriggerDrift,
+			reconcileStart, expectedRelease, err))
+	}
 	if err != nil {
 		// Report to Metriton & log
 		r.ReportError("fail_reconciliation", "Failed to reconcile release", err)
 
-		status.SetCondition(ambassador.AmbInsCondition{
+		r.setCondition(ambObj, status, ambassador.AmbInsCondition{
 			Type:    ambassador.ConditionIrreconcilable,
 			Status:  ambassador.StatusTrue,
-			Reason:  ambassador.ReasonReconcileError,
+			Reason:  failureReason(err, ambassador.ReasonReconcileError),
 			Message: err.Error(),
 		})
 
-		_ = r.updateResourceStatus(ambObj, status)
+		_ = r.updateResourceStatus(ctx, ambObj, status)
 		return reconcile.Result{RequeueAfter: r.checkInterval}, err
 	}
+
 	status.RemoveCondition(ambassador.ConditionIrreconcilable)
 
+	// verify the release again when its last verification failed (or it was never verified)
+	if needsVerification(ambObj, status, expectedRelease) {
+		if err := r.verifyRelease(ctx, ambObj, status, chart, expectedRelease); err != nil {
+			// Report to Metriton & log
+			r.ReportError("fail_verification", "Release verification failed", err)
+
+			r.setCondition(ambObj, status, ambassador.AmbInsCondition{
+				Type:    ambassador.ConditionReleaseFailed,
+				Status:  ambassador.StatusTrue,
+				Reason:  failureReason(err, ambassador.ReasonVerificationError),
+				Message: err.Error(),
+			})
+
+			_ = r.updateResourceStatus(ctx, ambObj, status)
+			return reconcile.Result{RequeueAfter: r.checkInterval}, err
+		}
+	}
+
 	if r.releaseHook != nil {
 		if err := r.releaseHook(expectedRelease); err != nil {
 			log.Error(err, "Failed to run release hook when reconciling", "checkInterval", r.checkInterval)
@@ -302,34 +530,67 @@ func (r *ReconcileAmbassadorInstallation) tryInstallOrUpdate(ambObj *unstructure
 	// ... and log it
 	log.Info(message)
 
-	status.DeployedRelease = &ambassador.AmbassadorRelease{
-		Name:       expectedRelease.Name,
-		Version:    expectedRelease.Chart.Metadata.Version,
-		AppVersion: expectedRelease.Chart.Metadata.AppVersion,
-		Manifest:   expectedRelease.Manifest,
-		Flavor:     flavor,
-	}
+	status.DeployedRelease = newAmbassadorRelease(ambObj, expectedRelease, chartsMgr, flavor)
+	r.updateAvailableVersions(&chartsMgr, status, window, now)
 
-	_ = r.updateResourceStatus(ambObj, status)
+	_ = r.updateResourceStatus(ctx, ambObj, status)
 	return reconcile.Result{RequeueAfter: r.checkInterval}, nil
 }
 
+// updateTimeoutFor returns the maximum time for an install/upgrade of an installation,
+// including the hooks and the verification
+func updateTimeoutFor(o *unstructured.Unstructured) time.Duration {
+	timeout := defaultUpdateTimeout + hooksTimeout(o)
+	if v, err := verificationFor(o); err == nil {
+		timeout += verificationTimeout(v)
+	}
+	return timeout
+}
+
+// newAmbassadorRelease returns the AmbassadorRelease for a Helm release, recording the
+// version rules used for choosing the chart
+func newAmbassadorRelease(o *unstructured.Unstructured, release *rpb.Release, chartsMgr HelmManager, flavor string) *ambassador.AmbassadorRelease {
+	return &ambassador.AmbassadorRelease{
+		Name:             release.Name,
+		Namespace:        release.Namespace,
+		Version:          release.Chart.Metadata.Version,
+		AppVersion:       release.Chart.Metadata.AppVersion,
+		Manifest:         release.Manifest,
+		Flavor:           flavor,
+		VersionRule:      chartsMgr.GetVersionRule().String(),
+		ChartVersionRule: chartsMgr.GetChartVersionRule().String(),
+		PatchesDigest:    patchesDigest(o),
+	}
+}
+
+// traceChart adds the chart that has been chosen to the current span (and to the root span)
+func traceChart(ctx context.Context, chartsMgr HelmManager) {
+	c := chartsMgr.GetChart()
+	attributes := []tracing.Attribute{
+		tracing.String("chart.name", c.Name),
+		tracing.String("chart.version", c.Version),
+		tracing.String("chart.appVersion", c.AppVersion),
+	}
+	tracing.SpanFromContext(ctx).SetAttributes(attributes...)
+	tracing.RootSpanFromContext(ctx).SetAttributes(attributes...)
+}
+
 // canMigrate verifies that the migration can be performed, returning an error otherwise
-func (r *ReconcileAmbassadorInstallation) canMigrate(ambIns *unstructured.Unstructured) (reconcile.Result, error) {
+func (r *ReconcileAmbassadorInstallation) canMigrate(ctx context.Context, ambIns *unstructured.Unstructured) (reconcile.Result, error) {
 	status := ambassador.StatusFor(ambIns)
-	namespace := ambIns.GetNamespace()
+	namespace := targetNamespaceFor(ambIns)
 
 	resultError := func(message string, event string) (reconcile.Result, error) {
 		err := fmt.Errorf(message)
 		log.Error(err, "")
 
-		status.SetCondition(ambassador.AmbInsCondition{
+		r.setCondition(ambIns, status, ambassador.AmbInsCondition{
 			Type:    ambassador.ConditionReleaseFailed,
 			Status:  ambassador.StatusTrue,
 			Reason:  ambassador.ReasonUpgradePrecondError,
 			Message: message,
 		})
-		_ = r.updateResourceStatus(ambIns, status)
+		_ = r.updateResourceStatus(ctx, ambIns, status)
 		r.ReportError(event, message, err)
 		return reconcile.Result{RequeueAfter: r.checkInterval}, err
 	}
@@ -3,7 +3,6 @@ package ambassadorinstallation
 import (
 	"context"
 	"fmt"
-	"sort"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
@@ -11,7 +10,6 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
 )
@@ -44,47 +42,22 @@ func (r *ReconcileAmbassadorInstallation) lookupAmbInst(n types.NamespacedName)
 	return o, nil
 }
 
-// listAmbInst checks if the AmbassadorInstallations instance is the first one in the namespace
-func (r *ReconcileAmbassadorInstallation) isFirstAmbInst(ambInst *unstructured.Unstructured) (bool, error) {
-	log.V(1).Info("Reconciling")
-
-	listOpt := client.ListOptions{
-		Namespace: ambInst.GetNamespace(),
-	}
-
-	log.V(3).Info("Getting list of AmbassadorInstallations")
-	lst := ambassador.AmbassadorInstallationList{}
-	err := r.Client.List(context.TODO(), &lst, &listOpt)
-	if apierrors.IsNotFound(err) {
-		log.Error(err, "No resources found",
-			"gkv", r.GVK.String(), "namespace", ambInst.GetNamespace())
-		return false, nil
-	}
-	if err != nil {
-		log.Error(err, "Failed to list resources",
-			"gkv", r.GVK.String(), "namespace", ambInst.GetNamespace())
-		return false, err
-	}
-
-	installations := lst.Items
-	sort.Slice(installations, func(i, j int) bool {
-		return installations[i].CreationTimestamp.Before(&installations[j].CreationTimestamp)
-	})
-
-	return ambInst.GetCreationTimestamp() == installations[0].CreationTimestamp, nil
-}
-
-// unsToAmbIns returns an AmbassadorInstallation from a unstructured.Unstructured
-func unsToAmbIns(o *unstructured.Unstructured) (*ambassador.AmbassadorInstallation, error) {
+// unsToInstallation returns an AmbassadorInstallation (or a ClusterAmbassadorInstallation) from a unstructured.Unstructured
+func unsToInstallation(o *unstructured.Unstructured) (ambassador.Installation, error) {
 	uns := o.UnstructuredContent()
 
-	// convert unstructured.Unstructured to a AmbassadorInstallation
-	var ambInstallation *ambassador.AmbassadorInstallation
-	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(uns, &ambInstallation); err != nil {
+	// convert unstructured.Unstructured to a AmbassadorInstallation/ClusterAmbassadorInstallation
+	var installation ambassador.Installation
+	if o.GetKind() == ClusterGVK.Kind {
+		installation = &ambassador.ClusterAmbassadorInstallation{}
+	} else {
+		installation = &ambassador.AmbassadorInstallation{}
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(uns, installation); err != nil {
 		return nil, err
 	}
 
-	return ambInstallation, nil
+	return installation, nil
 }
 
 // lookupResourceList returns a list of resources that match the given gvk in the given namespace
@@ -104,6 +77,8 @@ func (r *ReconcileAmbassadorInstallation) lookupResourceList(gvk *schema.GroupVe
 		log.Error(err, fmt.Sprintf("Could not convert resource %v to list", o.GetKind()))
 		return nil, err
 	}
+	// the list must be a `<Kind>List` (the client removes the suffix when building the request)
+	oList.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
 
 	log.Info(fmt.Sprintf("Looking up resource list for %v in the cluster", o.GetKind()))
 	err = r.Client.List(context.TODO(), oList)
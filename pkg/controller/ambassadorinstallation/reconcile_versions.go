@@ -0,0 +1,130 @@
+package ambassadorinstallation
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/helm/pkg/repo"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/helm"
+)
+
+// deferUpdate is used when the update check is not allowed at this moment: it refreshes
+// the versions available (as well as the next upgrade) and requeues the request.
+// The index of the Helm repo is downloaded at most once per update interval here.
+// When the update has been deferred by the update window (`byWindow`), an Event is
+// recorded for every new upgrade found.
+func (r *ReconcileAmbassadorInstallation) deferUpdate(ctx context.Context, ambObj *unstructured.Unstructured,
+	chartsMgr HelmManager, window UpdateWindow, now time.Time, byWindow bool) (reconcile.Result, error) {
+	status := ambassador.StatusFor(ambObj)
+	prevStatus := status.DeepCopy()
+
+	chartsMgr.UseCachedVersions(r.updateInterval, now)
+	r.updateAvailableVersions(&chartsMgr, status, window, now)
+	if reflect.DeepEqual(prevStatus.AvailableVersions, status.AvailableVersions) &&
+		reflect.DeepEqual(prevStatus.NextUpgrade, status.NextUpgrade) {
+		return reconcile.Result{RequeueAfter: r.checkInterval}, nil
+	}
+
+	if next := status.NextUpgrade; byWindow && next != nil &&
+		(prevStatus.NextUpgrade == nil || prevStatus.NextUpgrade.Version != next.Version) {
+		when := "the update window opens"
+		if next.Time != nil {
+			when = next.Time.String()
+		}
+		r.recordEvent(ambObj, corev1.EventTypeNormal, eventReasonUpdateDeferred,
+			"Upgrade to %s (chart %s) deferred until %s", next.AppVersion, next.Version, when)
+	}
+
+	return reconcile.Result{RequeueAfter: r.checkInterval}, r.updateResourceStatus(ctx, ambObj, status)
+}
+
+// updateAvailableVersions updates the list of versions available in the Helm repo
+// and the next upgrade planned in the status
+func (r *ReconcileAmbassadorInstallation) updateAvailableVersions(chartsMgr *HelmManager,
+	status *ambassador.AmbassadorInstallationStatus, window UpdateWindow, now time.Time) {
+	versions, err := chartsMgr.FindVersions()
+	if err != nil {
+		log.Info("Could not get the versions available in the Helm repo", "error", err)
+		return
+	}
+
+	status.AvailableVersions = newerVersions(versions, currentRelease(status))
+	status.NextUpgrade = nil
+	if len(status.AvailableVersions) == 0 {
+		return
+	}
+
+	latest := status.AvailableVersions[0]
+	status.NextUpgrade = &ambassador.AmbassadorUpgrade{
+		Version:    latest.Version,
+		AppVersion: latest.AppVersion,
+	}
+	if t, ok := nextUpdateTime(status.LastCheckTime.Time, r.updateInterval, window, now); ok {
+		mt := metav1.NewTime(t)
+		status.NextUpgrade.Time = &mt
+	}
+}
+
+// currentRelease returns the release the versions available are compared with: the
+// release rendered (in the `render` mode) or the release deployed
+func currentRelease(status *ambassador.AmbassadorInstallationStatus) *ambassador.AmbassadorRelease {
+	if r := status.RenderedRelease; r != nil {
+		return &ambassador.AmbassadorRelease{
+			Name:       r.Name,
+			Namespace:  r.Namespace,
+			Version:    r.Version,
+			AppVersion: r.AppVersion,
+			Flavor:     r.Flavor,
+		}
+	}
+	return status.DeployedRelease
+}
+
+// newerVersions returns the versions that are more recent than the deployed release
+// (or all the versions when there is nothing deployed)
+func newerVersions(versions repo.ChartVersions, deployed *ambassador.AmbassadorRelease) []ambassador.AmbassadorChartVersion {
+	res := []ambassador.AmbassadorChartVersion{}
+	for _, v := range versions {
+		if deployed != nil && !isNewerThanRelease(v, deployed) {
+			continue
+		}
+		res = append(res, ambassador.AmbassadorChartVersion{
+			Version:    v.Version,
+			AppVersion: v.AppVersion,
+		})
+	}
+	return res
+}
+
+// isNewerThanRelease returns True if the chart version is more recent than the release:
+// it must have a more recent AppVersion, or a more recent chart version for the same AppVersion.
+func isNewerThanRelease(v *repo.ChartVersion, release *ambassador.AmbassadorRelease) bool {
+	if moreRecent, err := helm.MoreRecentThan(v.AppVersion, release.AppVersion); err == nil && moreRecent {
+		return true
+	}
+	if equal, err := helm.Equal(v.AppVersion, release.AppVersion); err == nil && equal {
+		if moreRecent, err := helm.MoreRecentThan(v.Version, release.Version); err == nil && moreRecent {
+			return true
+		}
+	}
+	return false
+}
+
+// nextUpdateTime returns the earliest time an update can be performed, considering
+// the last check time, the update interval and the update window.
+func nextUpdateTime(lastCheck time.Time, updateInterval time.Duration, window UpdateWindow, now time.Time) (time.Time, bool) {
+	earliest := now
+	if !lastCheck.IsZero() {
+		if t := lastCheck.Add(updateInterval); t.After(earliest) {
+			earliest = t
+		}
+	}
+	return window.Next(earliest)
+}
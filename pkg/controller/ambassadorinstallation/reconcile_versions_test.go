@@ -0,0 +1,82 @@
+package ambassadorinstallation
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/repo"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+)
+
+func TestNewerVersions(t *testing.T) {
+	versions := repo.ChartVersions{}
+	for _, v := range [][]string{
+		{"6.3.6", "1.4.3"},
+		{"6.3.5", "1.4.2"},
+		{"6.3.4", "1.4.2"},
+		{"6.3.3", "1.4.1"},
+	} {
+		versions = append(versions, &repo.ChartVersion{
+			Metadata: &chart.Metadata{Version: v[0], AppVersion: v[1]},
+		})
+	}
+
+	tests := []struct {
+		name     string
+		deployed *ambassador.AmbassadorRelease
+		expected []string
+	}{
+		{
+			name:     "nothing deployed, all versions are available",
+			deployed: nil,
+			expected: []string{"6.3.6", "6.3.5", "6.3.4", "6.3.3"},
+		},
+		{
+			name:     "newer charts for the same app version are available",
+			deployed: &ambassador.AmbassadorRelease{Version: "6.3.4", AppVersion: "1.4.2"},
+			expected: []string{"6.3.6", "6.3.5"},
+		},
+		{
+			name:     "latest version deployed, nothing available",
+			deployed: &ambassador.AmbassadorRelease{Version: "6.3.6", AppVersion: "1.4.3"},
+			expected: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("Running test: %v", test.name)
+		res := newerVersions(versions, test.deployed)
+		if len(res) != len(test.expected) {
+			t.Fatalf("Expected %d versions, got %d: %v", len(test.expected), len(res), res)
+		}
+		for i, v := range res {
+			if v.Version != test.expected[i] {
+				t.Errorf("Expected version %q at %d, got %q", test.expected[i], i, v.Version)
+			}
+		}
+	}
+}
+
+func TestNextUpdateTime(t *testing.T) {
+	now := time.Date(2020, 1, 15, 10, 10, 10, 0, time.UTC)
+	always, _ := NewUpdateWindow("")
+	hourly, _ := NewUpdateWindow("0 * * * *")
+
+	next, ok := nextUpdateTime(time.Time{}, defaultUpdateInterval, always, now)
+	if !ok || !next.Equal(now) {
+		t.Errorf("Expected an update now when never checked, got %v (%v)", next, ok)
+	}
+
+	lastCheck := now.Add(-time.Hour)
+	next, ok = nextUpdateTime(lastCheck, defaultUpdateInterval, always, now)
+	if expected := lastCheck.Add(defaultUpdateInterval); !ok || !next.Equal(expected) {
+		t.Errorf("Expected an update at %v, got %v (%v)", expected, next, ok)
+	}
+
+	next, ok = nextUpdateTime(lastCheck, defaultUpdateInterval, hourly, now)
+	if expected := time.Date(2020, 1, 16, 10, 0, 0, 0, time.UTC); !ok || !next.Equal(expected) {
+		t.Errorf("Expected an update at %v, got %v (%v)", expected, next, ok)
+	}
+}
@@ -0,0 +1,39 @@
+package ambassadorinstallation
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/helm/release"
+)
+
+// recoveryMessage returns a description of the recovery of a release stuck in a pending status
+func recoveryMessage(recovery *release.Recovery) string {
+	stuck := fmt.Sprintf("Release stuck in %s (revision %d) since %s",
+		recovery.Status, recovery.Revision, recovery.Since.Format(time.RFC3339))
+	if recovery.Action == release.RecoveryRolledBack && recovery.Release != nil {
+		return fmt.Sprintf("%s: rolled back to the last deployed release (revision %d)", stuck, recovery.Release.Version)
+	}
+	return fmt.Sprintf("%s: cleared, so it can be installed again", stuck)
+}
+
+// recordRecovery records the recovery of a release that was stuck in a pending status (ie, when
+// the operator was killed during an upgrade), so the reconciliation can continue as usual.
+func (r *ReconcileAmbassadorInstallation) recordRecovery(o *unstructured.Unstructured,
+	status *ambassador.AmbassadorInstallationStatus, chartsMgr HelmManager, flavor string, recovery *release.Recovery) {
+	message := recoveryMessage(recovery)
+	log.Info("Recovered stale pending release", "revision", recovery.Revision,
+		"status", recovery.Status, "action", recovery.Action)
+	r.ReportEvent("recovered_release", ScoutMeta{"message", message})
+	r.recordEvent(o, corev1.EventTypeNormal, eventReasonRecovered, "%s", message)
+
+	if recovery.Action == release.RecoveryRolledBack && recovery.Release != nil {
+		status.AddHistory(newReleaseRecord(chartsMgr, flavor, ambassador.TriggerRecovery,
+			recovery.Since, recovery.Release, nil))
+		status.DeployedRelease = newAmbassadorRelease(o, recovery.Release, chartsMgr, flavor)
+	}
+}
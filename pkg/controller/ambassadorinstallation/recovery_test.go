@@ -0,0 +1,27 @@
+package ambassadorinstallation
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	rpb "helm.sh/helm/v3/pkg/release"
+
+	"github.com/datawire/ambassador-operator/pkg/helm/release"
+)
+
+func TestRecoveryMessage(t *testing.T) {
+	since := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	recovery := &release.Recovery{Revision: 4, Status: rpb.StatusPendingUpgrade, Since: since,
+		Action: release.RecoveryRolledBack, Release: &rpb.Release{Version: 5}}
+
+	expected := "Release stuck in pending-upgrade (revision 4) since 2020-06-01T10:00:00Z: rolled back to the last deployed release (revision 5)"
+	if msg := recoveryMessage(recovery); msg != expected {
+		t.Errorf("unexpected message %q", msg)
+	}
+
+	recovery = &release.Recovery{Revision: 1, Status: rpb.StatusPendingInstall, Since: since, Action: release.RecoveryCleared}
+	if msg := recoveryMessage(recovery); !strings.HasSuffix(msg, "cleared, so it can be installed again") {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
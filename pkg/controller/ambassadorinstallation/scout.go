@@ -4,11 +4,11 @@ package ambassadorinstallation
 
 import (
 	"context"
+	"time"
 
 	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/datawire/ambassador-operator/version"
-	"github.com/datawire/ambassador/pkg/metriton"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 )
@@ -16,10 +16,12 @@ import (
 // The Scout structure maintains an index, which is the count of calls
 // to Report, incrementing each call.  This provides a sequence of actions
 // to make it easier to search through the reports.
-// The Reporter is simply the Metriton reporting object.
+// The Reporter is the sink where reports are sent to (Metriton by default).
 type Scout struct {
-	index    int
-	Reporter *metriton.Reporter
+	index        int
+	installID    types.UID
+	baseMetadata map[string]interface{}
+	Reporter     Reporter
 }
 
 // Metadata is simply a key and an untyped value, instances passed in as parameters
@@ -31,51 +33,61 @@ type ScoutMeta struct {
 
 // Create a new Scout object, with a parameter stating what the Scout instance
 // will be reporting on.  The Ambassador Operator may be installing, updating,
-// or deleting the Ambassador installation.
-func NewScout(mode string, installID types.UID) (s *Scout) {
+// or deleting the Ambassador installation. A nil reporter disables the reports.
+func NewScout(mode string, installID types.UID, reporter Reporter) (s *Scout) {
 	return &Scout{
-		index: 0,
-		Reporter: &metriton.Reporter{
-			Application: "ambassador-operator",
-			Version:     version.Version,
-			GetInstallID: func(r *metriton.Reporter) (string, error) {
-				return string(installID), nil
-			},
-			// Fixed (growing) metadata passed with every report
-			BaseMetadata: map[string]interface{}{
-				"mode":     mode,
-				"trace_id": uuid.New().String(),
-			},
+		index:     0,
+		installID: installID,
+		// Fixed (growing) metadata passed with every report
+		baseMetadata: map[string]interface{}{
+			"mode":     mode,
+			"trace_id": uuid.New().String(),
 		},
+		Reporter: reporter,
 	}
 }
 
-// Reporting out: Sends a report to Metriton which will create a new entry in the
-// Metriton database in the product_event table.
+// Reporting out: Sends a report to the Reporter (ie, Metriton, which will create
+// a new entry in the Metriton database in the product_event table).
 func (s *Scout) Report(action string, meta ...ScoutMeta) error {
+	if s.Reporter == nil {
+		return nil
+	}
+
 	// Construct the report's metadata. Include the fixed (growing) set of
 	// metadata in the Scout structure and the pairs passed as arguments to this
 	// call. Also include and increment the index, which can be used to
 	// determine the correct order of reported events for this installation
 	// attempt (correlated by the trace_id set at the start).
 	s.index++
-	metadata := map[string]interface{}{
-		"action": action,
-		"index":  s.index,
+	metadata := map[string]interface{}{}
+	for k, v := range s.baseMetadata {
+		metadata[k] = v
 	}
+	metadata["action"] = action
+	metadata["index"] = s.index
 	for _, metaItem := range meta {
+		// errors are not serializable: send their message
+		if err, ok := metaItem.Value.(error); ok {
+			metadata[metaItem.Key] = err.Error()
+			continue
+		}
 		metadata[metaItem.Key] = metaItem.Value
 	}
 
+	report := TelemetryReport{
+		Application: telemetryApplication,
+		Version:     version.Version,
+		InstallID:   string(s.installID),
+		Time:        time.Now(),
+		Metadata:    metadata,
+	}
+
 	// TODO: @Alvaro, please check--is this the context we want to pass through
 	// TODO to Metriton?
-	_, err := s.Reporter.Report(context.TODO(), metadata)
-	if err != nil {
+	if err := s.Reporter.Report(context.TODO(), report); err != nil {
 		return errors.Wrap(err, "scout report")
 	}
 
-	// TODO: Do something useful (alert the user if there's an available
-	// upgrade?) with the response (discarded as "_" above)?
-
 	return nil
 }
@@ -0,0 +1,308 @@
+package ambassadorinstallation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datawire/ambassador/pkg/metriton"
+
+	"github.com/datawire/ambassador-operator/pkg/tracing"
+)
+
+const (
+	// DisableTelemetryEnvVar is the environment variable for disabling all the telemetry reports
+	DisableTelemetryEnvVar = "AMB_DISABLE_TELEMETRY"
+
+	// TelemetrySinksEnvVar is the environment variable with a comma-separated list of sinks
+	// for the telemetry reports (see the `TelemetrySink*` constants). Defaults to `metriton`.
+	TelemetrySinksEnvVar = "AMB_TELEMETRY_SINKS"
+
+	// TelemetryFileEnvVar is the environment variable with the file used by the `file` sink
+	TelemetryFileEnvVar = "AMB_TELEMETRY_FILE"
+
+	// TelemetryWebhookURLEnvVar is the environment variable with the URL used by the `webhook` sink
+	TelemetryWebhookURLEnvVar = "AMB_TELEMETRY_WEBHOOK_URL"
+
+	// TelemetryOTLPEndpointEnvVar is the environment variable with the OTLP/HTTP endpoint
+	// (ie, `http://otel-collector:4318`) used by the `otlp` sink
+	TelemetryOTLPEndpointEnvVar = "AMB_TELEMETRY_OTLP_ENDPOINT"
+
+	// TelemetryMetritonURLEnvVar is the environment variable with an (optional) alternative
+	// endpoint for the `metriton` sink
+	TelemetryMetritonURLEnvVar = "AMB_TELEMETRY_METRITON_URL"
+)
+
+// the sinks available for the telemetry reports
+const (
+	TelemetrySinkMetriton = "metriton"
+	TelemetrySinkStdout   = "stdout"
+	TelemetrySinkFile     = "file"
+	TelemetrySinkWebhook  = "webhook"
+	TelemetrySinkOTLP     = "otlp"
+)
+
+const (
+	// the application name used in the telemetry reports
+	telemetryApplication = "ambassador-operator"
+
+	// timeout for sending a report to a remote sink
+	telemetryTimeout = 10 * time.Second
+
+	// path where logs are sent to in an OTLP/HTTP endpoint
+	otlpLogsPath = "/v1/logs"
+)
+
+// TelemetryReport is a report sent to a telemetry sink
+type TelemetryReport struct {
+	Application string                 `json:"application"`
+	Version     string                 `json:"version"`
+	InstallID   string                 `json:"install_id"`
+	Time        time.Time              `json:"time"`
+	Metadata    map[string]interface{} `json:"metadata"`
+}
+
+// Reporter is a sink for the telemetry reports
+type Reporter interface {
+	Report(ctx context.Context, report TelemetryReport) error
+}
+
+// NewReporterFromEnv returns the Reporter configured in the environment,
+// or nil when the telemetry has been disabled
+func NewReporterFromEnv() (Reporter, error) {
+	if disabled, _ := strconv.ParseBool(os.Getenv(DisableTelemetryEnvVar)); disabled {
+		return nil, nil
+	}
+
+	sinks := os.Getenv(TelemetrySinksEnvVar)
+	if len(sinks) == 0 {
+		sinks = TelemetrySinkMetriton
+	}
+
+	reporters := MultiReporter{}
+	for _, sink := range strings.Split(sinks, ",") {
+		switch sink = strings.TrimSpace(sink); sink {
+		case "":
+			continue
+		case TelemetrySinkMetriton:
+			reporters = append(reporters, &MetritonReporter{Endpoint: os.Getenv(TelemetryMetritonURLEnvVar)})
+		case TelemetrySinkStdout:
+			reporters = append(reporters, NewJSONReporter(os.Stdout))
+		case TelemetrySinkFile:
+			filename := os.Getenv(TelemetryFileEnvVar)
+			if len(filename) == 0 {
+				return nil, fmt.Errorf("no file provided in %s for the %q telemetry sink", TelemetryFileEnvVar, sink)
+			}
+			reporters = append(reporters, NewFileReporter(filename))
+		case TelemetrySinkWebhook:
+			url := os.Getenv(TelemetryWebhookURLEnvVar)
+			if len(url) == 0 {
+				return nil, fmt.Errorf("no URL provided in %s for the %q telemetry sink", TelemetryWebhookURLEnvVar, sink)
+			}
+			reporters = append(reporters, &WebhookReporter{URL: url})
+		case TelemetrySinkOTLP:
+			endpoint := os.Getenv(TelemetryOTLPEndpointEnvVar)
+			if len(endpoint) == 0 {
+				return nil, fmt.Errorf("no endpoint provided in %s for the %q telemetry sink", TelemetryOTLPEndpointEnvVar, sink)
+			}
+			reporters = append(reporters, &OTLPReporter{Endpoint: endpoint})
+		default:
+			return nil, fmt.Errorf("unknown telemetry sink %q in %s", sink, TelemetrySinksEnvVar)
+		}
+	}
+
+	switch len(reporters) {
+	case 0:
+		return nil, nil
+	case 1:
+		return reporters[0], nil
+	default:
+		return reporters, nil
+	}
+}
+
+// MultiReporter sends the reports to several sinks
+type MultiReporter []Reporter
+
+// Report sends the report to all the sinks, returning the first error found
+func (m MultiReporter) Report(ctx context.Context, report TelemetryReport) error {
+	var res error
+	for _, r := range m {
+		if err := r.Report(ctx, report); err != nil && res == nil {
+			res = err
+		}
+	}
+	return res
+}
+
+// MetritonReporter sends the reports to Metriton
+type MetritonReporter struct {
+	// An (optional) endpoint URL; metriton.DefaultEndpoint is used when empty.
+	Endpoint string
+	// An (optional) HTTP client.
+	Client *http.Client
+}
+
+// Report sends the report to Metriton
+func (m *MetritonReporter) Report(ctx context.Context, report TelemetryReport) error {
+	// note: create a new metriton.Reporter for every report, as the install
+	// ID is only obtained once and we have a different ID per installation.
+	r := &metriton.Reporter{
+		Application:  report.Application,
+		Version:      report.Version,
+		GetInstallID: func(*metriton.Reporter) (string, error) { return report.InstallID, nil },
+		Client:       m.Client,
+		Endpoint:     m.Endpoint,
+	}
+	_, err := r.Report(ctx, report.Metadata)
+	return err
+}
+
+// JSONReporter writes the reports to a writer, as JSON (one report per line)
+type JSONReporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONReporter creates a new reporter that writes the reports in a writer (ie, os.Stdout)
+func NewJSONReporter(w io.Writer) *JSONReporter {
+	return &JSONReporter{w: w}
+}
+
+// Report writes the report as JSON
+func (j *JSONReporter) Report(_ context.Context, report TelemetryReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.w.Write(append(data, '\n'))
+	return err
+}
+
+// FileReporter appends the reports to a local file, as JSON (one report per line)
+type FileReporter struct {
+	mu       sync.Mutex
+	filename string
+}
+
+// NewFileReporter creates a new reporter that appends the reports to a file
+func NewFileReporter(filename string) *FileReporter {
+	return &FileReporter{filename: filename}
+}
+
+// Report appends the report to the file
+func (f *FileReporter) Report(ctx context.Context, report TelemetryReport) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out, err := os.OpenFile(f.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := NewJSONReporter(out).Report(ctx, report); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// WebhookReporter POSTs the reports (as JSON) to a generic HTTP webhook
+type WebhookReporter struct {
+	URL string
+	// An (optional) HTTP client.
+	Client *http.Client
+}
+
+// Report sends the report to the webhook
+func (w *WebhookReporter) Report(ctx context.Context, report TelemetryReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, w.Client, w.URL, data)
+}
+
+// OTLPReporter sends the reports as OpenTelemetry log records to an OTLP/HTTP
+// endpoint (ie, an OpenTelemetry collector), using the JSON encoding
+type OTLPReporter struct {
+	// The OTLP/HTTP endpoint (ie, `http://otel-collector:4318`). The logs are sent to `/v1/logs`.
+	Endpoint string
+	// An (optional) HTTP client.
+	Client *http.Client
+}
+
+// Report sends the report as a log record, with the action as the body
+// and the rest of the metadata as attributes
+func (o *OTLPReporter) Report(ctx context.Context, report TelemetryReport) error {
+	attributes := append([]interface{}{tracing.OTLPAttribute("install_id", report.InstallID)},
+		tracing.OTLPAttributes(report.Metadata)...)
+
+	logs := map[string]interface{}{
+		"resourceLogs": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []interface{}{
+						tracing.OTLPAttribute("service.name", report.Application),
+						tracing.OTLPAttribute("service.version", report.Version),
+					},
+				},
+				"scopeLogs": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": report.Application + "/scout"},
+						"logRecords": []interface{}{
+							map[string]interface{}{
+								"timeUnixNano": strconv.FormatInt(report.Time.UnixNano(), 10),
+								"severityText": "INFO",
+								"body":         tracing.OTLPValue(report.Metadata["action"]),
+								"attributes":   attributes,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	data, err := json.Marshal(logs)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, o.Client, strings.TrimSuffix(o.Endpoint, "/")+otlpLogsPath, data)
+}
+
+// postJSON POSTs some JSON data to a URL, checking the response is a success
+func postJSON(ctx context.Context, client *http.Client, url string, data []byte) error {
+	if client == nil {
+		client = &http.Client{Timeout: telemetryTimeout}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response from %s: %s", url, resp.Status)
+	}
+	return nil
+}
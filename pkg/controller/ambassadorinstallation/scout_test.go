@@ -0,0 +1,214 @@
+package ambassadorinstallation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testTelemetryServer is a local stand-in for a telemetry endpoint, recording the requests received
+type testTelemetryServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	paths  []string
+	bodies []map[string]interface{}
+}
+
+func newTestTelemetryServer(t *testing.T, status int) *testTelemetryServer {
+	s := &testTelemetryServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("could not decode request: %v", err)
+		}
+		s.mu.Lock()
+		s.paths = append(s.paths, r.URL.Path)
+		s.bodies = append(s.bodies, body)
+		s.mu.Unlock()
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("{}"))
+	}))
+	return s
+}
+
+func TestScoutReport(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := NewScout("reconcile", "1234", NewJSONReporter(buf))
+	if err := s.Report("start_reconciliation"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.Report("fail_download", ScoutMeta{"error", errors.New("download failed")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 reports, got %q", buf.String())
+	}
+	report := TelemetryReport{}
+	if err := json.Unmarshal([]byte(lines[1]), &report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.InstallID != "1234" || report.Application != telemetryApplication {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	m := report.Metadata
+	if m["action"] != "fail_download" || m["index"] != float64(2) || m["mode"] != "reconcile" || m["error"] != "download failed" {
+		t.Errorf("unexpected metadata: %v", m)
+	}
+
+	// a Scout without reporter does nothing
+	if err := NewScout("reconcile", "1234", nil).Report("start_reconciliation"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewReporterFromEnv(t *testing.T) {
+	envVars := []string{DisableTelemetryEnvVar, TelemetrySinksEnvVar, TelemetryFileEnvVar,
+		TelemetryWebhookURLEnvVar, TelemetryOTLPEndpointEnvVar, TelemetryMetritonURLEnvVar}
+	setEnv := func(env map[string]string) {
+		for _, name := range envVars {
+			_ = os.Unsetenv(name)
+		}
+		for k, v := range env {
+			_ = os.Setenv(k, v)
+		}
+	}
+	defer setEnv(nil)
+
+	tests := []struct {
+		env      map[string]string
+		expected string
+		wantErr  bool
+	}{
+		{env: map[string]string{}, expected: "*ambassadorinstallation.MetritonReporter"},
+		{env: map[string]string{DisableTelemetryEnvVar: "true"}, expected: "<nil>"},
+		{env: map[string]string{TelemetrySinksEnvVar: "stdout"}, expected: "*ambassadorinstallation.JSONReporter"},
+		{env: map[string]string{TelemetrySinksEnvVar: "stdout, metriton"}, expected: "ambassadorinstallation.MultiReporter"},
+		{env: map[string]string{TelemetrySinksEnvVar: "file"}, wantErr: true},
+		{env: map[string]string{TelemetrySinksEnvVar: "webhook", TelemetryWebhookURLEnvVar: "http://localhost"}, expected: "*ambassadorinstallation.WebhookReporter"},
+		{env: map[string]string{TelemetrySinksEnvVar: "otlp"}, wantErr: true},
+		{env: map[string]string{TelemetrySinksEnvVar: "carrier-pigeon"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		setEnv(test.env)
+		r, err := NewReporterFromEnv()
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%v: expected error", test.env)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.env, err)
+			continue
+		}
+		if got := typeName(r); got != test.expected {
+			t.Errorf("%v: got %s, expected %s", test.env, got, test.expected)
+		}
+	}
+}
+
+func TestNewReconcilerInvalidTelemetry(t *testing.T) {
+	defer os.Unsetenv(TelemetrySinksEnvVar)
+
+	// a typo in the sinks must not disable the telemetry silently
+	os.Setenv(TelemetrySinksEnvVar, "metritn")
+	if _, err := newReconciler(nil, DefaultGVK); err == nil || !strings.Contains(err.Error(), "metritn") {
+		t.Errorf("expected an error for an unknown telemetry sink, got %v", err)
+	}
+}
+
+func typeName(r Reporter) string {
+	if r == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%T", r)
+}
+
+func TestRemoteReporters(t *testing.T) {
+	server := newTestTelemetryServer(t, http.StatusOK)
+	defer server.Close()
+
+	reporters := MultiReporter{
+		&MetritonReporter{Endpoint: server.URL + "/scout"},
+		&WebhookReporter{URL: server.URL + "/webhook"},
+		&OTLPReporter{Endpoint: server.URL + "/"},
+	}
+	s := NewScout("reconcile", "1234", reporters)
+	if err := s.Report("completed_update", ScoutMeta{"message", "updated"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if strings.Join(server.paths, ",") != "/scout,/webhook,/v1/logs" {
+		t.Fatalf("unexpected requests to %v", server.paths)
+	}
+
+	// Metriton
+	metadata, _ := server.bodies[0]["metadata"].(map[string]interface{})
+	if server.bodies[0]["install_id"] != "1234" || metadata["action"] != "completed_update" {
+		t.Errorf("unexpected Metriton report: %v", server.bodies[0])
+	}
+
+	// webhook
+	metadata, _ = server.bodies[1]["metadata"].(map[string]interface{})
+	if server.bodies[1]["install_id"] != "1234" || metadata["message"] != "updated" {
+		t.Errorf("unexpected webhook report: %v", server.bodies[1])
+	}
+
+	// OTLP
+	data, _ := json.Marshal(server.bodies[2])
+	for _, expected := range []string{
+		`"body":{"stringValue":"completed_update"}`,
+		`{"key":"message","value":{"stringValue":"updated"}}`,
+		`{"key":"index","value":{"intValue":"1"}}`,
+		`{"key":"service.name","value":{"stringValue":"ambassador-operator"}}`,
+	} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("OTLP logs do not contain %s: %s", expected, data)
+		}
+	}
+
+	// errors in the endpoint are reported
+	failing := newTestTelemetryServer(t, http.StatusInternalServerError)
+	defer failing.Close()
+	if err := (&WebhookReporter{URL: failing.URL}).Report(context.TODO(), TelemetryReport{}); err == nil {
+		t.Errorf("expected error from a failing webhook")
+	}
+}
+
+func TestFileReporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telemetry")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	filename := filepath.Join(dir, "telemetry.json")
+	s := NewScout("reconcile", "1234", NewFileReporter(filename))
+	for _, action := range []string{"start_reconciliation", "completed_reconciliation"} {
+		if err := s.Report(action); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], `"action":"completed_reconciliation"`) {
+		t.Errorf("unexpected file contents: %s", data)
+	}
+}
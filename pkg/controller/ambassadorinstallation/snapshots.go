@@ -0,0 +1,409 @@
+package ambassadorinstallation
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	rpb "helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/helm/release"
+)
+
+const (
+	// default number of snapshots kept
+	defaultSnapshotsKept = 3
+
+	// key of the compressed resources in the Secrets/ConfigMaps of the snapshots
+	snapshotDataKey = "resources.json.gz"
+
+	// label added to the snapshots, with the Helm revision of the release
+	snapshotRevisionLabel = "getambassador.io/snapshot-revision"
+)
+
+// the kinds of `getambassador.io/v2` resources included in the snapshots by default
+var defaultSnapshotKinds = []string{"Mapping", "Host", "Module", "TLSContext", "Filter"}
+
+// snapshotsFor returns the `spec.snapshots` of an AmbassadorInstallation (or nil when there are none)
+func snapshotsFor(o *unstructured.Unstructured) (*ambassador.AmbassadorSnapshots, error) {
+	ambIns, err := unsToInstallation(o)
+	if err != nil {
+		return nil, err
+	}
+	return ambIns.GetSpec().Snapshots, nil
+}
+
+// snapshotStorage returns where the snapshots are stored
+func snapshotStorage(s *ambassador.AmbassadorSnapshots) ambassador.AmbassadorSnapshotStorage {
+	if len(s.Storage) == 0 {
+		return ambassador.SnapshotStorageSecret
+	}
+	return s.Storage
+}
+
+// snapshotName returns the name of the Secret/ConfigMap with the snapshot of a release revision
+func snapshotName(releaseName string, revision int) string {
+	suffix := fmt.Sprintf("-snapshot-%d", revision)
+	if len(releaseName)+len(suffix) > 63 {
+		releaseName = strings.TrimSuffix(releaseName[:63-len(suffix)], "-")
+	}
+	return releaseName + suffix
+}
+
+// takeSnapshot stores the `getambassador.io` resources used by an installation in a Secret (or
+// a ConfigMap), tagged with the revision of the release currently deployed, and records it
+// in the status. It returns nil when `spec.snapshots` is not enabled.
+func (r *ReconcileAmbassadorInstallation) takeSnapshot(ctx context.Context, o *unstructured.Unstructured,
+	status *ambassador.AmbassadorInstallationStatus, deployed *rpb.Release) (*ambassador.AmbassadorSnapshotStatus, error) {
+	s, err := snapshotsFor(o)
+	if err != nil || s == nil || deployed == nil {
+		return nil, err
+	}
+
+	kinds := s.Kinds
+	if len(kinds) == 0 {
+		kinds = defaultSnapshotKinds
+	}
+	id := effectiveAmbassadorID(o)
+
+	var resources []unstructured.Unstructured
+	for _, kind := range kinds {
+		gvk := schema.GroupVersionKind{Group: "getambassador.io", Version: "v2", Kind: kind}
+		items, err := listSnapshotResources(ctx, r.Manager.GetAPIReader(), gvk, snapshotNamespaces(o, r.namespaces))
+		if err != nil {
+			return nil, fmt.Errorf("could not list the %s resources: %w", kind, err)
+		}
+		for _, item := range items {
+			if usesAmbassadorID(&item, id) {
+				resources = append(resources, sanitizeForSnapshot(item))
+			}
+		}
+	}
+
+	data, err := encodeSnapshot(resources)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &ambassador.AmbassadorSnapshotStatus{
+		Name:      snapshotName(deployed.Name, deployed.Version),
+		Namespace: targetNamespaceFor(o),
+		Storage:   snapshotStorage(s),
+		Revision:  deployed.Version,
+		Resources: len(resources),
+		Time:      metav1.Now(),
+	}
+	if deployed.Chart != nil && deployed.Chart.Metadata != nil {
+		snapshot.AppVersion = deployed.Chart.Metadata.AppVersion
+	}
+
+	log.Info("Taking a snapshot of the Ambassador resources", "storage", snapshot.Storage,
+		"name", snapshot.Name, "revision", snapshot.Revision, "resources", snapshot.Resources)
+	if err := writeSnapshot(ctx, r.Client, newSnapshotObject(o, snapshot, data)); err != nil {
+		return nil, err
+	}
+
+	keep := s.Keep
+	if keep <= 0 {
+		keep = defaultSnapshotsKept
+	}
+	for _, removed := range addSnapshot(status, *snapshot, keep) {
+		if err := r.Client.Delete(ctx, newSnapshotObject(o, &removed, nil)); err != nil && !apierrors.IsNotFound(err) {
+			log.Error(err, "Could not remove an old snapshot", "name", removed.Name)
+		}
+	}
+	return &status.Snapshots[0], nil
+}
+
+// snapshotNamespaces returns the namespaces where the Ambassador of an installation can find its
+// resources: the target namespace when Ambassador is restricted to it (`scope.singleNamespace`),
+// or the target namespace and the namespaces watched by the operator otherwise. It returns nil
+// when the operator watches all the namespaces.
+func snapshotNamespaces(o *unstructured.Unstructured, watched []string) []string {
+	target := targetNamespaceFor(o)
+	if helmValues := GetHelmValuesAmbIns(o); helmValues != nil {
+		single, _, _ := unstructured.NestedBool(helmValues, "scope", "singleNamespace")
+		if dotted, ok := helmValues["scope.singleNamespace"].(bool); ok {
+			single = single || dotted
+		}
+		if single {
+			return []string{target}
+		}
+	}
+	if len(watched) == 0 {
+		return nil
+	}
+	if contains(watched, target) {
+		return watched
+	}
+	return append([]string{target}, watched...)
+}
+
+// listSnapshotResources lists the resources of some kind in the given namespaces (in all the
+// namespaces when there are none). The API reader is used as the target namespace is not
+// always in the cache. Nothing is returned when the CRD is not installed in the cluster.
+func listSnapshotResources(ctx context.Context, reader client.Reader, gvk schema.GroupVersionKind,
+	namespaces []string) ([]unstructured.Unstructured, error) {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	var items []unstructured.Unstructured
+	for _, namespace := range namespaces {
+		list := &unstructured.UnstructuredList{}
+		// the list must be a `<Kind>List` (the client removes the suffix when building the request)
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		err := reader.List(ctx, list, client.InNamespace(namespace))
+		if meta.IsNoMatchError(err) || apierrors.IsNotFound(err) {
+			// the CRD is not installed in the cluster (ie, `Filter` with OSS)
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, list.Items...)
+	}
+	return items, nil
+}
+
+// usesAmbassadorID returns true when a resource is used by the Ambassador with an ambassador_id
+func usesAmbassadorID(u *unstructured.Unstructured, id string) bool {
+	ids, _, _ := unstructured.NestedFieldNoCopy(u.Object, "spec", "ambassador_id")
+	switch ids := ids.(type) {
+	case string:
+		return ids == id
+	case []interface{}:
+		for _, i := range ids {
+			if i == id {
+				return true
+			}
+		}
+		return false
+	default:
+		return id == defaultAmbassadorID
+	}
+}
+
+// sanitizeForSnapshot removes the status and the fields set by the API server from a resource
+func sanitizeForSnapshot(u unstructured.Unstructured) unstructured.Unstructured {
+	u = *u.DeepCopy()
+	delete(u.Object, "status")
+	for _, field := range []string{"resourceVersion", "uid", "selfLink", "creationTimestamp", "generation", "managedFields"} {
+		unstructured.RemoveNestedField(u.Object, "metadata", field)
+	}
+	return u
+}
+
+// addSnapshot adds a snapshot to the status (replacing any snapshot with the same name), keeping
+// only the `keep` newest ones. It returns the snapshots removed from the status.
+func addSnapshot(status *ambassador.AmbassadorInstallationStatus, snapshot ambassador.AmbassadorSnapshotStatus,
+	keep int) []ambassador.AmbassadorSnapshotStatus {
+	snapshots := []ambassador.AmbassadorSnapshotStatus{snapshot}
+	for _, s := range status.Snapshots {
+		if s.Name != snapshot.Name || s.Namespace != snapshot.Namespace {
+			snapshots = append(snapshots, s)
+		}
+	}
+	var removed []ambassador.AmbassadorSnapshotStatus
+	if len(snapshots) > keep {
+		removed = snapshots[keep:]
+		snapshots = snapshots[:keep]
+	}
+	status.Snapshots = snapshots
+	return removed
+}
+
+// encodeSnapshot returns the resources as a (gzipped) JSON `List`
+func encodeSnapshot(resources []unstructured.Unstructured) ([]byte, error) {
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{"apiVersion": "v1", "kind": "List"}}
+	list.Items = resources
+	raw, err := list.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	if buf.Len() > corev1.MaxSecretSize {
+		return nil, fmt.Errorf("the snapshot is too large (%d bytes compressed)", buf.Len())
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeSnapshot returns the resources in the data of a snapshot
+func DecodeSnapshot(data []byte) ([]unstructured.Unstructured, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not decompress the snapshot: %w", err)
+	}
+	raw, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("could not decompress the snapshot: %w", err)
+	}
+
+	var list struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("could not parse the snapshot: %w", err)
+	}
+	resources := make([]unstructured.Unstructured, 0, len(list.Items))
+	for _, item := range list.Items {
+		resources = append(resources, unstructured.Unstructured{Object: item})
+	}
+	return resources, nil
+}
+
+// newSnapshotObject returns the Secret (or ConfigMap) for a snapshot, labeled with the owner (and
+// owned by it, when they are in the same namespace)
+func newSnapshotObject(o *unstructured.Unstructured, snapshot *ambassador.AmbassadorSnapshotStatus, data []byte) runtime.Object {
+	objectMeta := metav1.ObjectMeta{
+		Namespace: snapshot.Namespace,
+		Name:      snapshot.Name,
+		Labels:    release.OwnerLabels(o),
+	}
+	objectMeta.Labels[snapshotRevisionLabel] = strconv.Itoa(snapshot.Revision)
+	if len(o.GetNamespace()) == 0 || o.GetNamespace() == snapshot.Namespace {
+		objectMeta.SetOwnerReferences([]metav1.OwnerReference{*metav1.NewControllerRef(o, o.GroupVersionKind())})
+	}
+
+	if snapshot.Storage == ambassador.SnapshotStorageConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: objectMeta,
+			BinaryData: map[string][]byte{snapshotDataKey: data},
+		}
+	}
+	return &corev1.Secret{
+		ObjectMeta: objectMeta,
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{snapshotDataKey: data},
+	}
+}
+
+// writeSnapshot creates the Secret/ConfigMap of a snapshot, replacing the previous one
+// with the same name (ie, from a previous upgrade from the same revision that failed)
+func writeSnapshot(ctx context.Context, c client.Client, obj runtime.Object) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	key := types.NamespacedName{Namespace: accessor.GetNamespace(), Name: accessor.GetName()}
+	if err := c.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("could not replace the snapshot %s: %w", key, err)
+	}
+	if err := c.Create(ctx, obj); err != nil {
+		return fmt.Errorf("could not create the snapshot %s: %w", key, err)
+	}
+	return nil
+}
+
+// LoadSnapshot reads the resources in a snapshot from the cluster
+func LoadSnapshot(ctx context.Context, reader client.Reader, snapshot ambassador.AmbassadorSnapshotStatus) ([]unstructured.Unstructured, error) {
+	key := types.NamespacedName{Namespace: snapshot.Namespace, Name: snapshot.Name}
+	var data []byte
+	if snapshot.Storage == ambassador.SnapshotStorageConfigMap {
+		cm := &corev1.ConfigMap{}
+		if err := reader.Get(ctx, key, cm); err != nil {
+			return nil, fmt.Errorf("could not get the ConfigMap %s: %w", key, err)
+		}
+		data = cm.BinaryData[snapshotDataKey]
+	} else {
+		secret := &corev1.Secret{}
+		if err := reader.Get(ctx, key, secret); err != nil {
+			return nil, fmt.Errorf("could not get the Secret %s: %w", key, err)
+		}
+		data = secret.Data[snapshotDataKey]
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("there is no %s in the snapshot %s", snapshotDataKey, key)
+	}
+	return DecodeSnapshot(data)
+}
+
+// RestoreResources creates (or replaces) the resources from a snapshot, returning the number
+// of resources restored. It tries to restore all the resources, returning the first error.
+func RestoreResources(ctx context.Context, c client.Client, resources []unstructured.Unstructured) (int, error) {
+	restored := 0
+	var firstErr error
+	for i := range resources {
+		desired := resources[i].DeepCopy()
+		key := types.NamespacedName{Namespace: desired.GetNamespace(), Name: desired.GetName()}
+
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(desired.GroupVersionKind())
+		err := c.Get(ctx, key, existing)
+		switch {
+		case apierrors.IsNotFound(err):
+			err = c.Create(ctx, desired)
+		case err == nil:
+			desired.SetResourceVersion(existing.GetResourceVersion())
+			err = c.Update(ctx, desired)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("could not restore %s %s: %w", desired.GetKind(), key, err)
+			}
+			continue
+		}
+		restored++
+	}
+	return restored, firstErr
+}
+
+// offerSnapshot is called when an upgrade has been rolled back: the snapshot taken before
+// the upgrade is restored when `spec.snapshots.restoreOnRollback` is enabled, or an Event
+// is recorded with instructions for restoring it otherwise.
+func (r *ReconcileAmbassadorInstallation) offerSnapshot(ctx context.Context, o *unstructured.Unstructured,
+	snapshot *ambassador.AmbassadorSnapshotStatus) {
+	s, err := snapshotsFor(o)
+	if err != nil || s == nil || snapshot == nil {
+		return
+	}
+	description := fmt.Sprintf("%s %s/%s", snapshot.Storage, snapshot.Namespace, snapshot.Name)
+
+	if !s.RestoreOnRollback {
+		r.recordEvent(o, corev1.EventTypeNormal, eventReasonSnapshotAvailable,
+			"The Ambassador resources before the upgrade (revision %d) are in the %s: restore them with `kubectl ambassador restore %s %s`",
+			snapshot.Revision, description, o.GetName(), snapshot.Name)
+		return
+	}
+
+	log.Info("Restoring the snapshot of the Ambassador resources", "name", snapshot.Name)
+	resources, err := LoadSnapshot(ctx, r.Manager.GetAPIReader(), *snapshot)
+	restored := 0
+	if err == nil {
+		restored, err = RestoreResources(ctx, r.Client, resources)
+	}
+	if err != nil {
+		log.Error(err, "Failed to restore the snapshot", "name", snapshot.Name)
+		r.recordEvent(o, corev1.EventTypeWarning, eventReasonSnapshotRestoreFailed,
+			"Could not restore the snapshot in the %s: %v", description, err)
+		return
+	}
+
+	now := metav1.Now()
+	snapshot.RestoreTime = &now
+	r.recordEvent(o, corev1.EventTypeNormal, eventReasonSnapshotRestored,
+		"%d Ambassador resources restored from the %s (revision %d)", restored, description, snapshot.Revision)
+}
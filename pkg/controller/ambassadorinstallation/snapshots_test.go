@@ -0,0 +1,239 @@
+package ambassadorinstallation
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	cpb "helm.sh/helm/v3/pkg/chart"
+	rpb "helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+)
+
+// readerManager is a manager that only provides an API reader
+type readerManager struct {
+	manager.Manager
+	reader client.Reader
+}
+
+func (m readerManager) GetAPIReader() client.Reader {
+	return m.reader
+}
+
+func newTestMapping(namespace, name string, ambassadorID interface{}) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec":   map[string]interface{}{"prefix": "/" + name + "/", "service": name},
+		"status": map[string]interface{}{"state": "Running"},
+	}}
+	if ambassadorID != nil {
+		u.Object["spec"].(map[string]interface{})["ambassador_id"] = ambassadorID
+	}
+	u.SetAPIVersion("getambassador.io/v2")
+	u.SetKind("Mapping")
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	u.SetResourceVersion("42")
+	return u
+}
+
+func TestSnapshotName(t *testing.T) {
+	if name := snapshotName("ambassador", 3); name != "ambassador-snapshot-3" {
+		t.Errorf("unexpected name %q", name)
+	}
+	if name := snapshotName(strings.Repeat("a", 70), 12); len(name) != 63 || !strings.HasSuffix(name, "-snapshot-12") {
+		t.Errorf("unexpected name %q", name)
+	}
+}
+
+func TestUsesAmbassadorID(t *testing.T) {
+	tests := []struct {
+		ambassadorID interface{}
+		id           string
+		want         bool
+	}{
+		{nil, defaultAmbassadorID, true},
+		{nil, "internal", false},
+		{"internal", "internal", true},
+		{[]interface{}{"external", "internal"}, "internal", true},
+		{[]interface{}{"external"}, defaultAmbassadorID, false},
+	}
+	for _, test := range tests {
+		if got := usesAmbassadorID(newTestMapping("default", "backend", test.ambassadorID), test.id); got != test.want {
+			t.Errorf("usesAmbassadorID(%v, %q) = %t, expected %t", test.ambassadorID, test.id, got, test.want)
+		}
+	}
+}
+
+func TestAddSnapshot(t *testing.T) {
+	status := &ambassador.AmbassadorInstallationStatus{}
+	for revision := 1; revision <= 3; revision++ {
+		addSnapshot(status, ambassador.AmbassadorSnapshotStatus{Name: snapshotName("ambassador", revision), Revision: revision}, 2)
+	}
+	if len(status.Snapshots) != 2 || status.Snapshots[0].Revision != 3 || status.Snapshots[1].Revision != 2 {
+		t.Fatalf("unexpected snapshots %+v", status.Snapshots)
+	}
+
+	// a new snapshot of the same revision replaces the previous one
+	removed := addSnapshot(status, ambassador.AmbassadorSnapshotStatus{Name: snapshotName("ambassador", 2), Revision: 2, Resources: 5}, 2)
+	if len(removed) != 0 || len(status.Snapshots) != 2 || status.Snapshots[0].Resources != 5 || status.Snapshots[1].Revision != 3 {
+		t.Errorf("unexpected snapshots %+v (removed %+v)", status.Snapshots, removed)
+	}
+}
+
+func TestTakeAndRestoreSnapshot(t *testing.T) {
+	owner := newTestAmbInst("ambassador", time.Now(), map[string]interface{}{
+		"snapshots": map[string]interface{}{"kinds": []interface{}{"Mapping"}},
+	})
+	// the fake client only lists the kinds registered in its scheme
+	s := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(s)
+	mappingGVK := schema.GroupVersionKind{Group: "getambassador.io", Version: "v2", Kind: "Mapping"}
+	s.AddKnownTypeWithName(mappingGVK, &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(mappingGVK.GroupVersion().WithKind("MappingList"), &unstructured.UnstructuredList{})
+
+	c := fake.NewFakeClientWithScheme(s,
+		newTestMapping("default", "backend", nil),
+		newTestMapping("edge", "quote", []interface{}{"default", "internal"}),
+		newTestMapping("default", "internal", "internal"),
+	)
+	r := &ReconcileAmbassadorInstallation{Client: c, Manager: readerManager{reader: c}}
+
+	deployed := &rpb.Release{Name: "ambassador", Version: 4, Chart: &cpb.Chart{Metadata: &cpb.Metadata{AppVersion: "1.5.0"}}}
+	status := &ambassador.AmbassadorInstallationStatus{}
+	snapshot, err := r.takeSnapshot(context.Background(), &owner, status, deployed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if snapshot.Name != "ambassador-snapshot-4" || snapshot.Storage != ambassador.SnapshotStorageSecret ||
+		snapshot.Resources != 2 || snapshot.AppVersion != "1.5.0" || len(status.Snapshots) != 1 {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: snapshot.Namespace, Name: snapshot.Name}, secret); err != nil {
+		t.Fatalf("the Secret was not created: %v", err)
+	}
+	if secret.Labels[snapshotRevisionLabel] != "4" || len(secret.OwnerReferences) != 1 {
+		t.Errorf("unexpected Secret metadata %+v", secret.ObjectMeta)
+	}
+
+	resources, err := LoadSnapshot(context.Background(), c, *snapshot)
+	if err != nil {
+		t.Fatalf("could not load the snapshot: %v", err)
+	}
+	if len(resources) != 2 || resources[0].GetResourceVersion() != "" || resources[0].Object["status"] != nil {
+		t.Fatalf("unexpected resources in the snapshot %+v", resources)
+	}
+
+	// the resources are restored (after being modified or removed)
+	modified := newTestMapping("default", "backend", nil)
+	_ = c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "backend"}, modified)
+	modified.Object["spec"] = map[string]interface{}{"prefix": "/broken/", "service": "backend"}
+	if err := c.Update(context.Background(), modified); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(context.Background(), newTestMapping("edge", "quote", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := RestoreResources(context.Background(), c, resources)
+	if err != nil || restored != 2 {
+		t.Fatalf("unexpected restore: %d resources, %v", restored, err)
+	}
+	current := newTestMapping("", "", nil)
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "backend"}, current); err != nil {
+		t.Fatal(err)
+	}
+	if prefix, _, _ := unstructured.NestedString(current.Object, "spec", "prefix"); prefix != "/backend/" {
+		t.Errorf("the Mapping was not restored: prefix %q", prefix)
+	}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "edge", Name: "quote"}, current); err != nil {
+		t.Errorf("the Mapping was not recreated: %v", err)
+	}
+}
+
+func TestSnapshotNamespaces(t *testing.T) {
+	tests := []struct {
+		spec    map[string]interface{}
+		watched []string
+		want    []string
+	}{
+		{map[string]interface{}{}, []string{}, nil},
+		{map[string]interface{}{}, []string{"ambassador"}, []string{"ambassador"}},
+		{map[string]interface{}{}, []string{"edge", "default"}, []string{"ambassador", "edge", "default"}},
+		{map[string]interface{}{"targetNamespace": "gateway"}, []string{"edge"}, []string{"gateway", "edge"}},
+		{map[string]interface{}{"helmValues": map[string]interface{}{"scope": map[string]interface{}{"singleNamespace": true}}}, []string{}, []string{"ambassador"}},
+		{map[string]interface{}{"helmValues": map[string]interface{}{"scope.singleNamespace": true}}, []string{"edge"}, []string{"ambassador"}},
+	}
+	for i, tc := range tests {
+		o := newTestAmbInst("ambassador", time.Now(), tc.spec)
+		if got := snapshotNamespaces(&o, tc.watched); strings.Join(got, ",") != strings.Join(tc.want, ",") || (got == nil) != (tc.want == nil) {
+			t.Errorf("case %d: got %v, want %v", i, got, tc.want)
+		}
+	}
+}
+
+func TestListSnapshotResources(t *testing.T) {
+	s := runtime.NewScheme()
+	mappingGVK := schema.GroupVersionKind{Group: "getambassador.io", Version: "v2", Kind: "Mapping"}
+	s.AddKnownTypeWithName(mappingGVK, &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(mappingGVK.GroupVersion().WithKind("MappingList"), &unstructured.UnstructuredList{})
+	c := fake.NewFakeClientWithScheme(s,
+		newTestMapping("default", "backend", nil),
+		newTestMapping("edge", "quote", nil),
+		newTestMapping("private", "secrets", nil),
+	)
+
+	tests := []struct {
+		namespaces []string
+		want       int
+	}{
+		{nil, 3},
+		{[]string{"ambassador"}, 0},
+		{[]string{"ambassador", "default", "edge"}, 2},
+	}
+	for _, tc := range tests {
+		items, err := listSnapshotResources(context.Background(), c, mappingGVK, tc.namespaces)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(items) != tc.want {
+			t.Errorf("namespaces %v: got %d resources, want %d", tc.namespaces, len(items), tc.want)
+		}
+		for _, item := range items {
+			if item.GetNamespace() == "private" && tc.namespaces != nil {
+				t.Errorf("namespaces %v: resource from another namespace %s/%s", tc.namespaces, item.GetNamespace(), item.GetName())
+			}
+		}
+	}
+}
+
+func TestSnapshotObjectStorage(t *testing.T) {
+	owner := newTestAmbInst("ambassador", time.Now(), map[string]interface{}{})
+	snapshot := &ambassador.AmbassadorSnapshotStatus{Name: "ambassador-snapshot-1", Namespace: "edge",
+		Storage: ambassador.SnapshotStorageConfigMap, Revision: 1}
+
+	obj := newSnapshotObject(&owner, snapshot, []byte("data"))
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		t.Fatalf("expected a ConfigMap, got %T", obj)
+	}
+	if string(cm.BinaryData[snapshotDataKey]) != "data" || len(cm.OwnerReferences) != 0 {
+		t.Errorf("unexpected ConfigMap %+v", cm)
+	}
+
+	if _, err := DecodeSnapshot([]byte("data")); err == nil {
+		t.Errorf("expected an error for a snapshot that is not compressed")
+	}
+}
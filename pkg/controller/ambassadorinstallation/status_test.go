@@ -0,0 +1,147 @@
+package ambassadorinstallation
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/record"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+)
+
+func TestStatusSetReady(t *testing.T) {
+	tests := []struct {
+		description string
+		conditions  []ambassador.AmbInsCondition
+		status      ambassador.AmbInsConditionStatus
+		reason      ambassador.AmbInsConditionReason
+	}{
+		{
+			description: "no conditions",
+			status:      ambassador.StatusUnknown,
+			reason:      ambassador.ReasonReconciling,
+		},
+		{
+			description: "initialized",
+			conditions: []ambassador.AmbInsCondition{
+				{Type: ambassador.ConditionInitialized, Status: ambassador.StatusTrue},
+			},
+			status: ambassador.StatusUnknown,
+			reason: ambassador.ReasonReconciling,
+		},
+		{
+			description: "deployed",
+			conditions: []ambassador.AmbInsCondition{
+				{Type: ambassador.ConditionInitialized, Status: ambassador.StatusTrue},
+				{Type: ambassador.ConditionDeployed, Status: ambassador.StatusTrue, Reason: ambassador.ReasonUpdateSuccessful},
+			},
+			status: ambassador.StatusTrue,
+			reason: ambassador.ReasonUpdateSuccessful,
+		},
+		{
+			description: "deployed but the upgrade failed",
+			conditions: []ambassador.AmbInsCondition{
+				{Type: ambassador.ConditionDeployed, Status: ambassador.StatusTrue, Reason: ambassador.ReasonInstallSuccessful},
+				{Type: ambassador.ConditionReleaseFailed, Status: ambassador.StatusTrue, Reason: ambassador.ReasonUpdateError},
+			},
+			status: ambassador.StatusFalse,
+			reason: ambassador.ReasonUpdateError,
+		},
+		{
+			description: "duplicate",
+			conditions: []ambassador.AmbInsCondition{
+				{Type: ambassador.ConditionIrreconcilable, Status: ambassador.StatusFalse, Reason: ambassador.ReasonDuplicateError},
+			},
+			status: ambassador.StatusFalse,
+			reason: ambassador.ReasonDuplicateError,
+		},
+		{
+			description: "rendered",
+			conditions: []ambassador.AmbInsCondition{
+				{Type: ambassador.ConditionDeployed, Status: ambassador.StatusFalse, Reason: ambassador.ReasonUninstallSuccessful},
+				{Type: ambassador.ConditionRendered, Status: ambassador.StatusTrue, Reason: ambassador.ReasonRenderSuccessful},
+			},
+			status: ambassador.StatusTrue,
+			reason: ambassador.ReasonRenderSuccessful,
+		},
+		{
+			description: "uninstalled",
+			conditions: []ambassador.AmbInsCondition{
+				{Type: ambassador.ConditionDeployed, Status: ambassador.StatusFalse, Reason: ambassador.ReasonUninstallSuccessful},
+			},
+			status: ambassador.StatusFalse,
+			reason: ambassador.ReasonUninstallSuccessful,
+		},
+	}
+
+	for _, test := range tests {
+		status := &ambassador.AmbassadorInstallationStatus{}
+		for _, c := range test.conditions {
+			status.SetCondition(c)
+		}
+		status.SetReady(3)
+
+		ready := status.FindCondition(ambassador.ConditionReady)
+		if ready == nil {
+			t.Errorf("%s: no Ready condition", test.description)
+			continue
+		}
+		if ready.Status != test.status || ready.Reason != test.reason {
+			t.Errorf("%s: got Ready=%s (%s), expected Ready=%s (%s)",
+				test.description, ready.Status, ready.Reason, test.status, test.reason)
+		}
+		if ready.ObservedGeneration != 3 || status.ObservedGeneration != 3 {
+			t.Errorf("%s: unexpected observedGeneration", test.description)
+		}
+	}
+}
+
+func TestStatusReadyTransitions(t *testing.T) {
+	status := &ambassador.AmbassadorInstallationStatus{}
+	status.SetCondition(ambassador.AmbInsCondition{Type: ambassador.ConditionDeployed, Status: ambassador.StatusTrue,
+		Reason: ambassador.ReasonInstallSuccessful})
+	status.SetReady(1)
+	first := status.FindCondition(ambassador.ConditionReady).LastTransitionTime
+
+	// the transition time only changes when the status changes
+	status.SetCondition(ambassador.AmbInsCondition{Type: ambassador.ConditionDeployed, Status: ambassador.StatusTrue,
+		Reason: ambassador.ReasonUpdateSuccessful})
+	status.SetReady(2)
+	if second := status.FindCondition(ambassador.ConditionReady).LastTransitionTime; !second.Equal(&first) {
+		t.Errorf("transition time changed without a status change: %v -> %v", first, second)
+	}
+
+	// the Ready condition is not considered by LastCondition (unless explicitly requested)
+	if last := status.LastCondition(ambassador.AmbInsCondition{}); last.Type != ambassador.ConditionDeployed {
+		t.Errorf("unexpected last condition: %s", last.Type)
+	}
+	if last := status.LastCondition(ambassador.AmbInsCondition{Type: ambassador.ConditionReady}); last.Type != ambassador.ConditionReady {
+		t.Errorf("unexpected last condition: %s", last.Type)
+	}
+
+	status.RemoveCondition(ambassador.ConditionDeployed)
+	status.SetReady(2)
+	if ready := status.FindCondition(ambassador.ConditionReady); ready.Status != ambassador.StatusUnknown || ready.LastTransitionTime.Equal(&first) {
+		t.Errorf("unexpected Ready condition after removing Deployed: %+v", ready)
+	}
+}
+
+func TestSetConditionGeneration(t *testing.T) {
+	r := &ReconcileAmbassadorInstallation{EventRecorder: record.NewFakeRecorder(10)}
+	ambIns := newTestAmbInst("ambassador", time.Now(), map[string]interface{}{})
+	ambIns.SetGeneration(7)
+	status := &ambassador.AmbassadorInstallationStatus{}
+
+	r.setCondition(&ambIns, status, ambassador.AmbInsCondition{
+		Type:   ambassador.ConditionInitialized,
+		Status: ambassador.StatusTrue,
+	})
+	c := status.FindCondition(ambassador.ConditionInitialized)
+	if c == nil || c.ObservedGeneration != 7 {
+		t.Fatalf("unexpected condition: %+v", c)
+	}
+	// metav1.Condition requires a reason
+	if c.Reason != ambassador.AmbInsConditionReason(ambassador.ConditionInitialized) {
+		t.Errorf("unexpected reason: %q", c.Reason)
+	}
+}
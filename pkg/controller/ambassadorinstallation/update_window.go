@@ -38,7 +38,7 @@ func NewUpdateWindow(def string) (UpdateWindow, error) {
 	// “Sat 10:00-Sat 11:00 ET” which means Sat from 10am to 11am ET every week.
 	//
 
-	updateWindow := UpdateWindow{}
+	updateWindow := UpdateWindow{s: def}
 
 	// If no updateWindow is specified by the user, then it's allowed to update at all times
 	if len(def) == 0 {
@@ -55,6 +55,15 @@ func NewUpdateWindow(def string) (UpdateWindow, error) {
 		}
 	}
 
+	// check that all the windows can be parsed, so errors are detected as soon as possible
+	if updateWindow.updatePriority != NeverUpdate && len(def) > 0 {
+		for _, window := range allWindows {
+			if _, err := cronexpr.Parse(window); err != nil {
+				return UpdateWindow{}, fmt.Errorf("could not parse update window %q: %w", window, err)
+			}
+		}
+	}
+
 	updateWindow.intervals = allWindows
 
 	return updateWindow, nil
@@ -98,6 +107,34 @@ func (u UpdateWindow) Allowed(now time.Time, checkInterval time.Duration) bool {
 	return updateNow
 }
 
+// Next returns the first time (at or after `after`) when an update will be allowed
+// by the window. It returns False if the window never allows updates.
+func (u UpdateWindow) Next(after time.Time) (time.Time, bool) {
+	if u.updatePriority == AlwaysUpdate {
+		return after, true
+	} else if u.updatePriority == NeverUpdate {
+		return time.Time{}, false
+	}
+
+	var next time.Time
+	for _, window := range u.intervals {
+		expression, err := cronexpr.Parse(window)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("Could not parse updateWindow: %v", window))
+			return time.Time{}, false
+		}
+
+		t := expression.Next(after)
+		if t.IsZero() {
+			continue
+		}
+		if next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+	return next, !next.IsZero()
+}
+
 // String returns the string representation of the update window
 func (u UpdateWindow) String() string {
 	return u.s
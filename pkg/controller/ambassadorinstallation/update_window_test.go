@@ -65,3 +65,68 @@ func TestUpdateWindowAllowed(t *testing.T) {
 		}
 	}
 }
+
+func TestUpdateWindowNext(t *testing.T) {
+	now := time.Date(2020, 1, 15, 10, 10, 10, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		cron     string
+		expected time.Time
+		found    bool
+	}{
+		{
+			name:     "updateWindow not specified, next update is now",
+			cron:     "",
+			expected: now,
+			found:    true,
+		},
+		{
+			name:     "update every hour, next update at the next o'clock",
+			cron:     "0 * * * *",
+			expected: time.Date(2020, 1, 15, 11, 0, 0, 0, time.UTC),
+			found:    true,
+		},
+		{
+			name:     "two windows, the earliest one is used",
+			cron:     "0 * * * *,30 * * * *",
+			expected: time.Date(2020, 1, 15, 10, 30, 0, 0, time.UTC),
+			found:    true,
+		},
+		{
+			name:     "update every minute and Never, no next update",
+			cron:     "* * * * *,Never",
+			expected: time.Time{},
+			found:    false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("Running test: %v", test.name)
+		uw, err := NewUpdateWindow(test.cron)
+		if err != nil {
+			t.Errorf("Cannot create new update window: %v", err)
+		}
+
+		next, found := uw.Next(now)
+		if test.found != found {
+			t.Errorf("updateWindow next found? Expected %v, got %v", test.found, found)
+		}
+		if !test.expected.Equal(next) {
+			t.Errorf("updateWindow next: Expected %v, got %v", test.expected, next)
+		}
+	}
+}
+
+func TestNewUpdateWindowErrors(t *testing.T) {
+	for _, cron := range []string{"whatever", "* * * * *,60 * * * *"} {
+		if _, err := NewUpdateWindow(cron); err == nil {
+			t.Errorf("NewUpdateWindow(%q) should fail", cron)
+		}
+	}
+	for _, cron := range []string{"", "Never", "* * * * *,never,whatever"} {
+		if _, err := NewUpdateWindow(cron); err != nil {
+			t.Errorf("NewUpdateWindow(%q) failed: %v", cron, err)
+		}
+	}
+}
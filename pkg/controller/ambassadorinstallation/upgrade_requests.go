@@ -0,0 +1,79 @@
+package ambassadorinstallation
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+)
+
+// annotations used for operating an installation (ie, with `kubectl ambassador`)
+const (
+	// ApprovedVersionAnnotation approves the upgrade to a chart version: when this is the
+	// next upgrade planned (`status.nextUpgrade.version`), it is performed as soon as possible,
+	// ignoring the update window.
+	ApprovedVersionAnnotation = "getambassador.io/approved-version"
+
+	// RequestedAtAnnotation requests an upgrade check in the next reconciliation, ignoring
+	// the update interval (but not the update window). Any new value (ie, the current time)
+	// is a new request, acknowledged in `status.lastHandledRequest.requestedAt`.
+	RequestedAtAnnotation = "reconcile.getambassador.io/requestedAt"
+
+	// ForceUpgradeAnnotation forces an upgrade check in the next reconciliation, ignoring
+	// the update interval and the update window. Any new value (ie, the current time)
+	// is a new request, acknowledged in `status.lastHandledRequest.forceUpgradeAt`.
+	ForceUpgradeAnnotation = "reconcile.getambassador.io/forceUpgradeAt"
+)
+
+// upgradeRequest is an on-demand request for an upgrade check
+type upgradeRequest struct {
+	description    string
+	ignoreInterval bool
+	ignoreWindow   bool
+}
+
+// pendingUpgradeRequest returns the upgrade request (if any) that has not been handled yet for
+// an installation: an upgrade forced or approved (ignoring the update window) or a reconciliation
+// requested (ignoring only the update interval)
+func pendingUpgradeRequest(o *unstructured.Unstructured, status *ambassador.AmbassadorInstallationStatus) (upgradeRequest, bool) {
+	annotations := o.GetAnnotations()
+	handled := status.LastHandledRequest
+	if handled == nil {
+		handled = &ambassador.AmbassadorHandledRequest{}
+	}
+
+	if forced := annotations[ForceUpgradeAnnotation]; len(forced) > 0 && forced != handled.ForceUpgradeAt {
+		return upgradeRequest{description: "upgrade forced at " + forced, ignoreInterval: true, ignoreWindow: true}, true
+	}
+	if approved, ok := annotations[ApprovedVersionAnnotation]; ok && len(approved) > 0 &&
+		status.NextUpgrade != nil && status.NextUpgrade.Version == approved {
+		return upgradeRequest{description: "upgrade to chart " + approved + " approved", ignoreInterval: true, ignoreWindow: true}, true
+	}
+	if requested := annotations[RequestedAtAnnotation]; len(requested) > 0 && requested != handled.RequestedAt {
+		return upgradeRequest{description: "reconciliation requested at " + requested, ignoreInterval: true}, true
+	}
+	return upgradeRequest{}, false
+}
+
+// acknowledgeRequests records the current values of the request annotations as handled in the
+// status, returning true when the status has been modified (it does not update the resource
+// in the cluster)
+func acknowledgeRequests(o *unstructured.Unstructured, status *ambassador.AmbassadorInstallationStatus, now time.Time) bool {
+	annotations := o.GetAnnotations()
+	requested, forced := annotations[RequestedAtAnnotation], annotations[ForceUpgradeAnnotation]
+	if len(requested) == 0 && len(forced) == 0 {
+		return false
+	}
+	if handled := status.LastHandledRequest; handled != nil && handled.RequestedAt == requested && handled.ForceUpgradeAt == forced {
+		return false
+	}
+	t := metav1.NewTime(now)
+	status.LastHandledRequest = &ambassador.AmbassadorHandledRequest{
+		RequestedAt:    requested,
+		ForceUpgradeAt: forced,
+		Time:           &t,
+	}
+	return true
+}
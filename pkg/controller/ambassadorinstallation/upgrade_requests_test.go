@@ -0,0 +1,69 @@
+package ambassadorinstallation
+
+import (
+	"testing"
+	"time"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+)
+
+func TestPendingUpgradeRequest(t *testing.T) {
+	next := &ambassador.AmbassadorUpgrade{Version: "6.5.0", AppVersion: "1.5.1"}
+	handled := &ambassador.AmbassadorHandledRequest{RequestedAt: "1", ForceUpgradeAt: "1"}
+	tests := []struct {
+		description    string
+		annotations    map[string]string
+		nextUpgrade    *ambassador.AmbassadorUpgrade
+		handled        *ambassador.AmbassadorHandledRequest
+		expected       bool
+		expectedWindow bool
+	}{
+		{description: "no annotations", nextUpgrade: next, expected: false},
+		{description: "forced", annotations: map[string]string{ForceUpgradeAnnotation: "1"}, expected: true, expectedWindow: true},
+		{description: "forced and handled", annotations: map[string]string{ForceUpgradeAnnotation: "1"}, handled: handled, expected: false},
+		{description: "forced again", annotations: map[string]string{ForceUpgradeAnnotation: "2"}, handled: handled, expected: true, expectedWindow: true},
+		{description: "requested", annotations: map[string]string{RequestedAtAnnotation: "1"}, expected: true, expectedWindow: false},
+		{description: "requested and handled", annotations: map[string]string{RequestedAtAnnotation: "1"}, handled: handled, expected: false},
+		{description: "next upgrade approved", annotations: map[string]string{ApprovedVersionAnnotation: "6.5.0"}, nextUpgrade: next, expected: true, expectedWindow: true},
+		{description: "other version approved", annotations: map[string]string{ApprovedVersionAnnotation: "6.4.0"}, nextUpgrade: next, expected: false},
+		{description: "no upgrade planned", annotations: map[string]string{ApprovedVersionAnnotation: "6.5.0"}, expected: false},
+	}
+
+	for _, test := range tests {
+		ambIns := newTestAmbInst("ambassador", time.Now(), map[string]interface{}{})
+		ambIns.SetAnnotations(test.annotations)
+		status := &ambassador.AmbassadorInstallationStatus{NextUpgrade: test.nextUpgrade, LastHandledRequest: test.handled}
+		request, ok := pendingUpgradeRequest(&ambIns, status)
+		if ok != test.expected {
+			t.Errorf("%s: got %v, expected %v", test.description, ok, test.expected)
+			continue
+		}
+		if ok && (!request.ignoreInterval || request.ignoreWindow != test.expectedWindow) {
+			t.Errorf("%s: unexpected request %+v", test.description, request)
+		}
+	}
+}
+
+func TestAcknowledgeRequests(t *testing.T) {
+	now := time.Now()
+	ambIns := newTestAmbInst("ambassador", now, map[string]interface{}{})
+	status := &ambassador.AmbassadorInstallationStatus{}
+
+	if acknowledgeRequests(&ambIns, status, now) || status.LastHandledRequest != nil {
+		t.Errorf("nothing should be acknowledged without requests")
+	}
+
+	ambIns.SetAnnotations(map[string]string{RequestedAtAnnotation: "a", ForceUpgradeAnnotation: "b"})
+	if !acknowledgeRequests(&ambIns, status, now) {
+		t.Fatalf("the requests were not acknowledged")
+	}
+	if h := status.LastHandledRequest; h.RequestedAt != "a" || h.ForceUpgradeAt != "b" || h.Time == nil {
+		t.Errorf("unexpected handled request: %+v", h)
+	}
+	if _, pending := pendingUpgradeRequest(&ambIns, status); pending {
+		t.Errorf("the requests are still pending after acknowledging them")
+	}
+	if acknowledgeRequests(&ambIns, status, now) {
+		t.Errorf("the requests were acknowledged twice")
+	}
+}
@@ -3,8 +3,9 @@ package ambassadorinstallation
 import (
 	"fmt"
 	"os"
-	"strings"
 	"time"
+
+	"github.com/docker/distribution/reference"
 )
 
 const (
@@ -23,12 +24,37 @@ func contains(l []string, s string) bool {
 
 // parse a repo and tag from an image name
 // for example: "quay.io/datawire/aes:1.0" -> ("quay.io/datawire/aes", "1.0")
+// or "localhost:5000/aes:1.0" -> ("localhost:5000/aes", "1.0")
+//
+// images pinned to a digest are also accepted. As the chart renders the image as `repository:tag`,
+// the digest algorithm is moved to the repository and the tag is the digest value. For example,
+// "quay.io/datawire/aes@sha256:abc..." -> ("quay.io/datawire/aes@sha256", "abc...")
 func parseRepoTag(s string) (string, string, error) {
-	res := strings.Split(s, ":")
-	if len(res) != 2 {
-		return "", "", fmt.Errorf("could not parse image name %s", s)
+	ref, err := reference.Parse(s)
+	if err != nil {
+		return "", "", fmt.Errorf("could not parse image name %s: %w", s, err)
+	}
+	named, ok := ref.(reference.Named)
+	if !ok {
+		return "", "", fmt.Errorf("could not parse image name %s: no repository", s)
+	}
+
+	repo := named.Name()
+	tag := ""
+	if tagged, ok := ref.(reference.Tagged); ok {
+		tag = tagged.Tag()
+	}
+	if digested, ok := ref.(reference.Digested); ok {
+		if len(tag) > 0 {
+			repo = repo + ":" + tag
+		}
+		d := digested.Digest()
+		return repo + "@" + d.Algorithm().String(), d.Hex(), nil
+	}
+	if len(tag) == 0 {
+		return "", "", fmt.Errorf("could not parse image name %s: no tag or digest", s)
 	}
-	return res[0], res[1], nil
+	return repo, tag, nil
 }
 
 func getEnvDuration(name string, d time.Duration) (time.Duration, string) {
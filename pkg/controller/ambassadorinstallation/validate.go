@@ -0,0 +1,218 @@
+package ambassadorinstallation
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/helm"
+	"github.com/datawire/ambassador-operator/pkg/render"
+)
+
+// ValidateInstallation checks the spec of an AmbassadorInstallation (or a ClusterAmbassadorInstallation)
+// with the same parsers used by the controller (ie, for validating a file offline)
+func ValidateInstallation(o *unstructured.Unstructured) field.ErrorList {
+	return validateInstallation(o, nil)
+}
+
+// validateInstallation checks the spec of an AmbassadorInstallation (or a ClusterAmbassadorInstallation),
+// using the same parsers used by the controller. When `old` is not nil, `o` is an update of `old`,
+// and changes that cannot be performed by the controller are rejected too.
+func validateInstallation(o *unstructured.Unstructured, old *unstructured.Unstructured) field.ErrorList {
+	errs := field.ErrorList{}
+	specPath := field.NewPath("spec")
+
+	ambObj, err := unsToInstallation(o)
+	if err != nil {
+		return append(errs, field.Invalid(specPath, nil, err.Error()))
+	}
+	spec := ambObj.GetSpec()
+
+	if _, err := helm.NewChartVersionRule(spec.Version); err != nil {
+		errs = append(errs, field.Invalid(specPath.Child("version"), spec.Version,
+			"could not parse version: "+err.Error()))
+	}
+
+	if len(spec.ChartVersion) > 0 {
+		if _, err := helm.NewChartVersionRule(spec.ChartVersion); err != nil {
+			errs = append(errs, field.Invalid(specPath.Child("chartVersion"), spec.ChartVersion,
+				"could not parse chart version: "+err.Error()))
+		}
+	}
+
+	if _, err := NewUpdateWindow(spec.UpdateWindow); err != nil {
+		errs = append(errs, field.Invalid(specPath.Child("updateWindow"), spec.UpdateWindow, err.Error()))
+	}
+
+	if len(spec.BaseImage) > 0 {
+		if _, _, err := parseRepoTag(spec.BaseImage); err != nil {
+			errs = append(errs, field.Invalid(specPath.Child("baseImage"), spec.BaseImage,
+				"must be an image name with a tag or a digest (ie, quay.io/datawire/aes:1.0)"))
+		}
+	}
+
+	if len(spec.ImageRegistryMirror) > 0 {
+		if err := validateRegistryMirror(spec.ImageRegistryMirror); err != nil {
+			errs = append(errs, field.Invalid(specPath.Child("imageRegistryMirror"), spec.ImageRegistryMirror, err.Error()))
+		}
+	}
+
+	for i, p := range spec.Patches {
+		if _, err := compilePatch(i, p); err != nil {
+			errs = append(errs, field.Invalid(specPath.Child("patches").Index(i), p.Patch, err.Error()))
+		}
+	}
+
+	if spec.Render != nil {
+		errs = append(errs, validateRenderOptions(spec.Render, specPath.Child("render"))...)
+	}
+
+	if spec.Verification != nil {
+		errs = append(errs, validateVerification(spec.Verification, specPath.Child("verification"))...)
+	}
+
+	if spec.Hooks != nil {
+		errs = append(errs, validateHooks(spec.Hooks, specPath.Child("hooks"))...)
+	}
+
+	if spec.Snapshots != nil {
+		errs = append(errs, validateSnapshots(spec.Snapshots, specPath.Child("snapshots"))...)
+	}
+
+	// `enableAES: true` means `installOSS: false`, and `enableAES: false` means `installOSS: true`
+	if enableAES, ok := GetHelmValuesAmbIns(o)["enableAES"].(bool); ok && enableAES == spec.InstallOSS {
+		errs = append(errs, field.Invalid(specPath.Child("helmValues", "enableAES"), enableAES,
+			"conflicts with spec.installOSS"))
+	}
+
+	if old != nil && spec.InstallOSS {
+		status := ambassador.StatusFor(old)
+		if status.DeployedRelease != nil && status.DeployedRelease.Flavor != flavorOSS {
+			errs = append(errs, field.Forbidden(specPath.Child("installOSS"),
+				"migration from AES to OSS not supported"))
+		}
+	}
+
+	if old != nil && len(spec.ReleaseName) > 0 {
+		status := ambassador.StatusFor(old)
+		if status.DeployedRelease != nil && len(status.DeployedRelease.Name) > 0 && status.DeployedRelease.Name != spec.ReleaseName {
+			errs = append(errs, field.Forbidden(specPath.Child("releaseName"),
+				fmt.Sprintf("cannot be changed once Ambassador has been deployed (with the release %q)", status.DeployedRelease.Name)))
+		}
+	}
+
+	return errs
+}
+
+// validateRenderOptions checks the destinations for the manifests in the `render` mode
+func validateRenderOptions(options *ambassador.AmbassadorRenderOptions, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if len(options.Directory) > 0 && !filepath.IsAbs(options.Directory) {
+		errs = append(errs, field.Invalid(path.Child("directory"), options.Directory, "must be an absolute path"))
+	}
+	if git := options.Git; git != nil {
+		if len(git.URL) == 0 {
+			errs = append(errs, field.Required(path.Child("git", "url"), ""))
+		} else if err := render.ValidateGitURL(git.URL); err != nil {
+			errs = append(errs, field.Invalid(path.Child("git", "url"), git.URL, err.Error()))
+		}
+		if len(git.Path) > 0 && (filepath.IsAbs(git.Path) || strings.HasPrefix(filepath.Clean(git.Path), "..")) {
+			errs = append(errs, field.Invalid(path.Child("git", "path"), git.Path, "must be relative to the root of the repository"))
+		}
+	}
+	return errs
+}
+
+// validateVerification checks the checks performed after installing/upgrading
+func validateVerification(v *ambassador.AmbassadorVerification, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if v.Timeout != nil && v.Timeout.Duration <= 0 {
+		errs = append(errs, field.Invalid(path.Child("timeout"), v.Timeout.Duration.String(), "must be positive"))
+	}
+	for i, p := range v.Probes {
+		errs = append(errs, validateProbe(p, path.Child("probes").Index(i))...)
+	}
+	return errs
+}
+
+// validateProbe checks a request sent for verifying a release. The Service must be a Service
+// name (in the target namespace) and the host a DNS name, so a probe cannot be sent anywhere else.
+func validateProbe(p ambassador.AmbassadorProbe, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if len(p.Service) > 0 {
+		for _, msg := range validation.IsDNS1123Label(p.Service) {
+			errs = append(errs, field.Invalid(path.Child("service"), p.Service, msg))
+		}
+	}
+	if len(p.Host) > 0 {
+		for _, msg := range validation.IsDNS1123Subdomain(p.Host) {
+			errs = append(errs, field.Invalid(path.Child("host"), p.Host, msg))
+		}
+	}
+	if p.Port < 0 || p.Port > 65535 {
+		errs = append(errs, field.Invalid(path.Child("port"), p.Port, "must be a valid port number"))
+	}
+	if len(p.Scheme) > 0 && p.Scheme != "http" && p.Scheme != "https" {
+		errs = append(errs, field.NotSupported(path.Child("scheme"), p.Scheme, []string{"http", "https"}))
+	}
+	if p.ExpectedStatus != 0 && (p.ExpectedStatus < 100 || p.ExpectedStatus > 599) {
+		errs = append(errs, field.Invalid(path.Child("expectedStatus"), p.ExpectedStatus, "must be a valid HTTP status code"))
+	}
+	return errs
+}
+
+// validateHooks checks the Jobs run around the upgrades
+func validateHooks(hooks *ambassador.AmbassadorHooks, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if hooks.Timeout != nil && hooks.Timeout.Duration <= 0 {
+		errs = append(errs, field.Invalid(path.Child("timeout"), hooks.Timeout.Duration.String(), "must be positive"))
+	}
+	for _, phase := range []struct {
+		child string
+		hooks []ambassador.AmbassadorHook
+	}{{"preUpgrade", hooks.PreUpgrade}, {"postUpgrade", hooks.PostUpgrade}} {
+		names := map[string]bool{}
+		for i, hook := range phase.hooks {
+			hookPath := path.Child(phase.child).Index(i)
+			if msgs := validation.IsDNS1123Label(hook.Name); len(msgs) > 0 {
+				errs = append(errs, field.Invalid(hookPath.Child("name"), hook.Name, strings.Join(msgs, ", ")))
+			} else if names[hook.Name] {
+				errs = append(errs, field.Duplicate(hookPath.Child("name"), hook.Name))
+			}
+			names[hook.Name] = true
+			if _, err := parseHookTemplate(hook); err != nil {
+				errs = append(errs, field.Invalid(hookPath.Child("template"), nil, err.Error()))
+			}
+		}
+	}
+	return errs
+}
+
+// validateSnapshots checks the snapshots of the resources taken before the upgrades
+func validateSnapshots(s *ambassador.AmbassadorSnapshots, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	switch s.Storage {
+	case "", ambassador.SnapshotStorageSecret, ambassador.SnapshotStorageConfigMap:
+	default:
+		errs = append(errs, field.NotSupported(path.Child("storage"), s.Storage,
+			[]string{string(ambassador.SnapshotStorageSecret), string(ambassador.SnapshotStorageConfigMap)}))
+	}
+	if s.Keep < 0 {
+		errs = append(errs, field.Invalid(path.Child("keep"), s.Keep, "must be positive"))
+	}
+	kinds := map[string]bool{}
+	for i, kind := range s.Kinds {
+		if len(kind) == 0 {
+			errs = append(errs, field.Required(path.Child("kinds").Index(i), "must be a kind of getambassador.io/v2 resource"))
+		} else if kinds[kind] {
+			errs = append(errs, field.Duplicate(path.Child("kinds").Index(i), kind))
+		}
+		kinds[kind] = true
+	}
+	return errs
+}
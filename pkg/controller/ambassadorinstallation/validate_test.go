@@ -0,0 +1,245 @@
+package ambassadorinstallation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestValidateInstallation(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		spec       map[string]interface{}
+		oldFlavor  string
+		oldRelease string
+		wantFields []string
+	}{
+		{
+			name: "empty spec",
+			spec: map[string]interface{}{},
+		},
+		{
+			name: "valid spec",
+			spec: map[string]interface{}{
+				"version":      "1.*",
+				"chartVersion": "~6.5",
+				"updateWindow": "* 0-6 * * SAT",
+				"baseImage":    "quay.io/datawire/aes:1.0",
+			},
+		},
+		{
+			name: "wrong versions",
+			spec: map[string]interface{}{
+				"version":      "1.x.WRONG",
+				"chartVersion": "not-a-version",
+			},
+			wantFields: []string{"spec.version", "spec.chartVersion"},
+		},
+		{
+			name:       "wrong update window",
+			spec:       map[string]interface{}{"updateWindow": "* * * * *,every-day"},
+			wantFields: []string{"spec.updateWindow"},
+		},
+		{
+			name:       "never is always accepted",
+			spec:       map[string]interface{}{"updateWindow": "Never,whatever"},
+			wantFields: []string{},
+		},
+		{
+			name:       "wrong base image",
+			spec:       map[string]interface{}{"baseImage": "quay.io/datawire/aes"},
+			wantFields: []string{"spec.baseImage"},
+		},
+		{
+			name: "base image with a registry port and a digest",
+			spec: map[string]interface{}{
+				"baseImage":           "localhost:5000/ambassador@sha256:2bd6f1e2c3a4b5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e",
+				"imageRegistryMirror": "registry.internal:5000/mirror",
+			},
+		},
+		{
+			name:       "wrong registry mirror",
+			spec:       map[string]interface{}{"imageRegistryMirror": "https://registry.internal"},
+			wantFields: []string{"spec.imageRegistryMirror"},
+		},
+		{
+			name: "render options",
+			spec: map[string]interface{}{
+				"mode": "render",
+				"render": map[string]interface{}{
+					"directory": "/manifests",
+					"git":       map[string]interface{}{"url": "https://example.com/repo.git", "path": "clusters/prod"},
+				},
+			},
+		},
+		{
+			name: "wrong render options",
+			spec: map[string]interface{}{
+				"mode": "render",
+				"render": map[string]interface{}{
+					"directory": "manifests",
+					"git":       map[string]interface{}{"path": "../prod"},
+				},
+			},
+			wantFields: []string{"spec.render.directory", "spec.render.git.url", "spec.render.git.path"},
+		},
+		{
+			name: "git URL taken as an option",
+			spec: map[string]interface{}{
+				"mode": "render",
+				"render": map[string]interface{}{
+					"git": map[string]interface{}{"url": "--upload-pack=touch /tmp/pwned"},
+				},
+			},
+			wantFields: []string{"spec.render.git.url"},
+		},
+		{
+			name: "wrong verification",
+			spec: map[string]interface{}{
+				"verification": map[string]interface{}{
+					"timeout": "-1m",
+					"probes": []interface{}{
+						map[string]interface{}{"path": "/backend/", "scheme": "ftp", "expectedStatus": 1000},
+					},
+				},
+			},
+			wantFields: []string{"spec.verification.timeout", "spec.verification.probes[0].scheme",
+				"spec.verification.probes[0].expectedStatus"},
+		},
+		{
+			name: "probes sent outside the target namespace",
+			spec: map[string]interface{}{
+				"verification": map[string]interface{}{
+					"probes": []interface{}{
+						map[string]interface{}{"service": "metadata.google.internal/computeMetadata/v1/#", "host": "evil.com:80@"},
+						map[string]interface{}{"service": "ambassador-admin", "host": "app.example.com"},
+					},
+				},
+			},
+			wantFields: []string{"spec.verification.probes[0].service", "spec.verification.probes[0].host"},
+		},
+		{
+			name: "wrong hooks",
+			spec: map[string]interface{}{
+				"hooks": map[string]interface{}{
+					"preUpgrade": []interface{}{
+						map[string]interface{}{"name": "backup", "template": map[string]interface{}{
+							"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+								"containers": []interface{}{map[string]interface{}{"name": "backup", "image": "bitnami/kubectl"}},
+							}}},
+						}},
+						map[string]interface{}{"name": "backup", "template": map[string]interface{}{"spec": map[string]interface{}{}}},
+					},
+					"postUpgrade": []interface{}{
+						map[string]interface{}{"name": "Notify_LB", "template": map[string]interface{}{"spec": map[string]interface{}{}}},
+					},
+				},
+			},
+			wantFields: []string{"spec.hooks.preUpgrade[1].name", "spec.hooks.preUpgrade[1].template",
+				"spec.hooks.postUpgrade[0].name", "spec.hooks.postUpgrade[0].template"},
+		},
+		{
+			name: "wrong snapshots",
+			spec: map[string]interface{}{
+				"snapshots": map[string]interface{}{
+					"storage": "PersistentVolume",
+					"keep":    -1,
+					"kinds":   []interface{}{"Mapping", "", "Mapping"},
+				},
+			},
+			wantFields: []string{"spec.snapshots.storage", "spec.snapshots.keep",
+				"spec.snapshots.kinds[1]", "spec.snapshots.kinds[2]"},
+		},
+		{
+			name: "enableAES and installOSS conflict",
+			spec: map[string]interface{}{
+				"installOSS": true,
+				"helmValues": map[string]interface{}{"enableAES": true},
+			},
+			wantFields: []string{"spec.helmValues.enableAES"},
+		},
+		{
+			name:       "migration from AES to OSS",
+			spec:       map[string]interface{}{"installOSS": true},
+			oldFlavor:  flavorAES,
+			wantFields: []string{"spec.installOSS"},
+		},
+		{
+			name:      "migration from OSS to AES",
+			spec:      map[string]interface{}{},
+			oldFlavor: flavorOSS,
+		},
+		{
+			name:       "release renamed",
+			spec:       map[string]interface{}{"releaseName": "internal"},
+			oldRelease: "ambassador",
+			wantFields: []string{"spec.releaseName"},
+		},
+		{
+			name:       "release name set to the current one",
+			spec:       map[string]interface{}{"releaseName": "ambassador"},
+			oldRelease: "ambassador",
+		},
+	}
+
+	for _, test := range tests {
+		ambIns := newTestAmbInst("ambassador", now, test.spec)
+
+		var old *unstructured.Unstructured
+		if len(test.oldFlavor) > 0 || len(test.oldRelease) > 0 {
+			o := newTestAmbInst("ambassador", now, map[string]interface{}{})
+			o.Object["status"] = map[string]interface{}{
+				"deployedRelease": map[string]interface{}{"flavor": test.oldFlavor, "name": test.oldRelease},
+			}
+			old = &o
+		}
+
+		errs := validateInstallation(&ambIns, old)
+		if len(errs) != len(test.wantFields) {
+			t.Errorf("%s: got errors %v, want errors in %v", test.name, errs, test.wantFields)
+			continue
+		}
+		for i, err := range errs {
+			if err.Field != test.wantFields[i] {
+				t.Errorf("%s: got error in %q, want %q", test.name, err.Field, test.wantFields[i])
+			}
+		}
+	}
+}
+
+func TestInstallationValidatorHandle(t *testing.T) {
+	now := time.Now()
+	valid := newTestAmbInst("valid", now, map[string]interface{}{"version": "1.*"})
+	invalid := newTestAmbInst("invalid", now, map[string]interface{}{"version": "1.x.WRONG"})
+
+	v := &installationValidator{}
+	for _, test := range []struct {
+		obj       *unstructured.Unstructured
+		operation admissionv1beta1.Operation
+		allowed   bool
+	}{
+		{&valid, admissionv1beta1.Create, true},
+		{&invalid, admissionv1beta1.Create, false},
+		{&invalid, admissionv1beta1.Update, false},
+		{&invalid, admissionv1beta1.Delete, true},
+	} {
+		raw, err := test.obj.MarshalJSON()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		req := admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+			Operation: test.operation,
+			Object:    runtime.RawExtension{Raw: raw},
+		}}
+		resp := v.Handle(context.TODO(), req)
+		if resp.Allowed != test.allowed {
+			t.Errorf("%s %q: allowed=%t, want %t (%v)", test.operation, test.obj.GetName(), resp.Allowed, test.allowed, resp.Result)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package ambassadorinstallation
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	rpb "helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/helm/release"
+)
+
+const (
+	// default maximum time for the verification of a release
+	defaultVerificationTimeout = 2 * time.Minute
+
+	// defaults for the probes in `spec.verification.probes`
+	defaultProbePath   = "/ambassador/v0/check_ready"
+	defaultProbePort   = 80
+	defaultProbeStatus = http.StatusOK
+
+	// time between the attempts of a probe
+	probeInterval = 5 * time.Second
+)
+
+// the client used for the probes: the certificates are not verified, as Ambassador
+// is contacted through the cluster DNS name of the Service
+var probeHTTPClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	},
+}
+
+// VerificationError is the error returned when some check in `spec.verification` did not pass
+type VerificationError struct {
+	// Failed are the checks that did not pass
+	Failed []ambassador.AmbassadorCheckResult
+}
+
+func (e *VerificationError) Error() string {
+	failed := make([]string, 0, len(e.Failed))
+	for _, c := range e.Failed {
+		failed = append(failed, fmt.Sprintf("%s %q: %s", c.Type, c.Name, c.Message))
+	}
+	return "verification failed: " + strings.Join(failed, "; ")
+}
+
+// isVerificationError returns true when an error has been caused by a failed verification
+func isVerificationError(err error) bool {
+	var verificationErr *VerificationError
+	return errors.As(err, &verificationErr)
+}
+
+// isRolledBackError returns true when an upgrade has failed and the release manager has
+// rolled it back to the previous revision
+func isRolledBackError(err error) bool {
+	var rolledBackErr *release.RolledBackError
+	return errors.As(err, &rolledBackErr)
+}
+
+// verificationFor returns the `spec.verification` of an AmbassadorInstallation
+// (or nil when there is nothing to verify)
+func verificationFor(o *unstructured.Unstructured) (*ambassador.AmbassadorVerification, error) {
+	ambIns, err := unsToInstallation(o)
+	if err != nil {
+		return nil, err
+	}
+	v := ambIns.GetSpec().Verification
+	if v == nil || (!v.HelmTests && len(v.Probes) == 0) {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// verificationTimeout returns the maximum time for a verification (zero when there is nothing to verify)
+func verificationTimeout(v *ambassador.AmbassadorVerification) time.Duration {
+	switch {
+	case v == nil:
+		return 0
+	case v.Timeout != nil && v.Timeout.Duration > 0:
+		return v.Timeout.Duration
+	default:
+		return defaultVerificationTimeout
+	}
+}
+
+// needsVerification returns true when a release must be verified: it has not been
+// verified yet, or its last verification failed
+func needsVerification(o *unstructured.Unstructured, status *ambassador.AmbassadorInstallationStatus, rel *rpb.Release) bool {
+	if v, err := verificationFor(o); err != nil || v == nil {
+		return status.Verification != nil
+	}
+	last := status.Verification
+	return last == nil || last.Revision != rel.Version || last.Outcome != ambassador.OutcomeSucceeded
+}
+
+// verifyRelease runs the checks in `spec.verification` for a release that has just been
+// installed/upgraded, recording the results in the status. It returns a *VerificationError
+// when some check did not pass.
+func (r *ReconcileAmbassadorInstallation) verifyRelease(ctx context.Context, o *unstructured.Unstructured,
+	status *ambassador.AmbassadorInstallationStatus, chart release.Manager, rel *rpb.Release) error {
+	v, err := verificationFor(o)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		status.Verification = nil
+		return nil
+	}
+
+	timeout := verificationTimeout(v)
+	verifyCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	verifyCtx, verifyDone := startPhase(verifyCtx, phaseVerify)
+
+	log.Info("Verifying release", "revision", rel.Version, "helmTests", v.HelmTests, "probes", len(v.Probes))
+
+	var checks []ambassador.AmbassadorCheckResult
+	if v.HelmTests {
+		start := time.Now()
+		testedRelease, err := chart.TestRelease(verifyCtx, timeout)
+		checks = append(checks, helmTestChecks(testedRelease, start, err)...)
+	}
+	for i, p := range v.Probes {
+		name, url := probeTarget(p, rel)
+		// the probes are validated by the webhook, but it is optional
+		if errs := validateProbe(p, field.NewPath("spec", "verification", "probes").Index(i)); len(errs) > 0 {
+			checks = append(checks, ambassador.AmbassadorCheckResult{Name: name, Type: ambassador.CheckProbe,
+				Outcome: ambassador.OutcomeFailed, Message: errs.ToAggregate().Error()})
+			continue
+		}
+		expected := p.ExpectedStatus
+		if expected == 0 {
+			expected = defaultProbeStatus
+		}
+		checks = append(checks, runProbe(verifyCtx, probeHTTPClient, name, url, p.Host, expected))
+	}
+
+	now := metav1.Now()
+	result := &ambassador.AmbassadorVerificationStatus{
+		Revision: rel.Version,
+		Outcome:  ambassador.OutcomeSucceeded,
+		Time:     &now,
+		Checks:   checks,
+	}
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		result.Version = rel.Chart.Metadata.Version
+		result.AppVersion = rel.Chart.Metadata.AppVersion
+	}
+
+	var failed []ambassador.AmbassadorCheckResult
+	for _, c := range checks {
+		if c.Outcome != ambassador.OutcomeSucceeded {
+			failed = append(failed, c)
+		}
+	}
+	if len(failed) > 0 {
+		result.Outcome = ambassador.OutcomeFailed
+		err = &VerificationError{Failed: failed}
+	}
+	verifyDone(err)
+	status.Verification = result
+
+	if err == nil {
+		r.recordEvent(o, corev1.EventTypeNormal, string(ambassador.ReasonVerificationSuccessful),
+			"Ambassador %s (chart %s) verified: %d checks passed", result.AppVersion, result.Version, len(checks))
+	}
+	return err
+}
+
+// helmTestChecks returns the results of the Helm tests run at `start` (ignoring the
+// tests that were not run, as Helm stops at the first failure)
+func helmTestChecks(rel *rpb.Release, start time.Time, err error) []ambassador.AmbassadorCheckResult {
+	var checks []ambassador.AmbassadorCheckResult
+	failed := false
+	if rel != nil {
+		for _, h := range rel.Hooks {
+			if !isTestHook(h) || h.LastRun.StartedAt.Time.Before(start) {
+				continue
+			}
+			c := ambassador.AmbassadorCheckResult{
+				Name:    h.Name,
+				Type:    ambassador.CheckHelmTest,
+				Outcome: ambassador.OutcomeSucceeded,
+				Message: h.LastRun.Phase.String(),
+			}
+			if h.LastRun.Phase != rpb.HookPhaseSucceeded {
+				c.Outcome = ambassador.OutcomeFailed
+				failed = true
+			}
+			checks = append(checks, c)
+		}
+	}
+	if err != nil && !failed {
+		checks = append(checks, ambassador.AmbassadorCheckResult{
+			Name:    "helm-test",
+			Type:    ambassador.CheckHelmTest,
+			Outcome: ambassador.OutcomeFailed,
+			Message: err.Error(),
+		})
+	}
+	return checks
+}
+
+// probeTarget returns the name and the URL of a probe for a release
+func probeTarget(p ambassador.AmbassadorProbe, rel *rpb.Release) (string, string) {
+	service := p.Service
+	if len(service) == 0 {
+		service = chartServiceName(rel)
+	}
+	port := p.Port
+	if port == 0 {
+		port = defaultProbePort
+	}
+	scheme := p.Scheme
+	if len(scheme) == 0 {
+		scheme = "http"
+	}
+	path := p.Path
+	if len(path) == 0 {
+		path = defaultProbePath
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	name := p.Name
+	if len(name) == 0 {
+		name = path
+	}
+	return name, fmt.Sprintf("%s://%s.%s.svc:%d%s", scheme, service, rel.Namespace, port, path)
+}
+
+// chartServiceName returns the name of the Service created by the Ambassador chart for a
+// release (the "fullname" of the chart)
+func chartServiceName(rel *rpb.Release) string {
+	if fullname, ok := rel.Config["fullnameOverride"].(string); ok && len(fullname) > 0 {
+		return truncateName(fullname)
+	}
+	name := "ambassador"
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		name = rel.Chart.Metadata.Name
+	}
+	if override, ok := rel.Config["nameOverride"].(string); ok && len(override) > 0 {
+		name = override
+	}
+	if strings.Contains(rel.Name, name) {
+		return truncateName(rel.Name)
+	}
+	return truncateName(rel.Name + "-" + name)
+}
+
+// truncateName truncates a name like the Helm charts do (`trunc 63 | trimSuffix "-"`)
+func truncateName(name string) string {
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.TrimSuffix(name, "-")
+}
+
+// runProbe sends GET requests to a URL until the expected status is received or the
+// context is done, returning the result of the last attempt
+func runProbe(ctx context.Context, client *http.Client, name, url, host string, expected int) ambassador.AmbassadorCheckResult {
+	result := ambassador.AmbassadorCheckResult{Name: name, Type: ambassador.CheckProbe, Outcome: ambassador.OutcomeFailed}
+	for {
+		message, ok := probeOnce(ctx, client, url, host, expected)
+		if ok {
+			result.Outcome = ambassador.OutcomeSucceeded
+			result.Message = message
+			return result
+		}
+		// keep the result of the last real attempt when the context has been cancelled
+		if ctx.Err() == nil || len(result.Message) == 0 {
+			result.Message = message
+		}
+
+		select {
+		case <-ctx.Done():
+			return result
+		case <-time.After(probeInterval):
+		}
+	}
+}
+
+// probeOnce sends a GET request to a URL, returning a description of the
+// result and whether the expected status was received
+func probeOnce(ctx context.Context, client *http.Client, url, host string, expected int) (string, bool) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err.Error(), false
+	}
+	req = req.WithContext(ctx)
+	if len(host) > 0 {
+		req.Host = host
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err.Error(), false
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	message := fmt.Sprintf("GET %s: status %d", url, resp.StatusCode)
+	if resp.StatusCode != expected {
+		return fmt.Sprintf("%s (expected %d)", message, expected), false
+	}
+	return message, true
+}
+
+// rollbackRelease rolls back an upgrade that did not pass the verification (when
+// `spec.verification.rollbackOnFailure` is enabled), recording it in the history.
+// It returns true when the release has been rolled back.
+func (r *ReconcileAmbassadorInstallation) rollbackRelease(ctx context.Context, o *unstructured.Unstructured,
+	status *ambassador.AmbassadorInstallationStatus, chart release.Manager, chartsMgr HelmManager, flavor string) bool {
+	v, err := verificationFor(o)
+	if err != nil || v == nil || !v.RollbackOnFailure {
+		return false
+	}
+
+	log.Info("Rolling back the release after a failed verification")
+	start := time.Now()
+	rolledBackRelease, err := chart.RollbackRelease(ctx)
+	status.AddHistory(newReleaseRecord(chartsMgr, flavor, ambassador.TriggerRollback, start, rolledBackRelease, err))
+	if err != nil {
+		log.Error(err, "Failed to roll back the release")
+		r.recordEvent(o, corev1.EventTypeWarning, eventReasonRollbackFailed, "Could not roll back Ambassador: %v", err)
+		return false
+	}
+
+	status.DeployedRelease = newAmbassadorRelease(o, rolledBackRelease, chartsMgr, flavor)
+	r.recordEvent(o, corev1.EventTypeNormal, eventReasonRolledBack,
+		"Ambassador rolled back to %s after a failed verification", releaseDescription(status.DeployedRelease))
+	return true
+}
@@ -0,0 +1,147 @@
+package ambassadorinstallation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cpb "helm.sh/helm/v3/pkg/chart"
+	rpb "helm.sh/helm/v3/pkg/release"
+	htime "helm.sh/helm/v3/pkg/time"
+
+	ambassador "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v2"
+	"github.com/datawire/ambassador-operator/pkg/helm/release"
+)
+
+func TestProbeTarget(t *testing.T) {
+	rel := &rpb.Release{
+		Name:      "ambassador",
+		Namespace: "edge",
+		Chart:     &cpb.Chart{Metadata: &cpb.Metadata{Name: "ambassador"}},
+	}
+
+	name, url := probeTarget(ambassador.AmbassadorProbe{}, rel)
+	if name != defaultProbePath || url != "http://ambassador.edge.svc:80/ambassador/v0/check_ready" {
+		t.Errorf("unexpected default probe %q %q", name, url)
+	}
+
+	name, url = probeTarget(ambassador.AmbassadorProbe{Name: "backend", Scheme: "https", Port: 443, Path: "backend/"}, rel)
+	if name != "backend" || url != "https://ambassador.edge.svc:443/backend/" {
+		t.Errorf("unexpected probe %q %q", name, url)
+	}
+
+	// the Service is named after the release and the chart
+	rel.Name = "edge"
+	if _, url := probeTarget(ambassador.AmbassadorProbe{}, rel); !strings.HasPrefix(url, "http://edge-ambassador.edge.svc:80/") {
+		t.Errorf("unexpected URL %q", url)
+	}
+	rel.Config = map[string]interface{}{"fullnameOverride": "gateway"}
+	if _, url := probeTarget(ambassador.AmbassadorProbe{}, rel); !strings.HasPrefix(url, "http://gateway.edge.svc:80/") {
+		t.Errorf("unexpected URL %q", url)
+	}
+}
+
+func TestRunProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "example.com" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	res := runProbe(ctx, server.Client(), "ready", server.URL+"/ready", "example.com", http.StatusOK)
+	if res.Outcome != ambassador.OutcomeSucceeded || res.Type != ambassador.CheckProbe {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	res = runProbe(ctx, server.Client(), "ready", server.URL+"/ready", "", http.StatusOK)
+	if res.Outcome != ambassador.OutcomeFailed || !strings.Contains(res.Message, "status 404 (expected 200)") {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
+
+func TestHelmTestChecks(t *testing.T) {
+	start := time.Now()
+	hook := func(name string, phase rpb.HookPhase, started time.Time) *rpb.Hook {
+		return &rpb.Hook{
+			Name:    name,
+			Events:  []rpb.HookEvent{rpb.HookTest},
+			LastRun: rpb.HookExecution{StartedAt: htime.Time{Time: started}, Phase: phase},
+		}
+	}
+	rel := &rpb.Release{Hooks: []*rpb.Hook{
+		hook("test-ready", rpb.HookPhaseSucceeded, start.Add(time.Second)),
+		hook("test-mapping", rpb.HookPhaseFailed, start.Add(2*time.Second)),
+		hook("test-not-run", rpb.HookPhaseSucceeded, start.Add(-time.Hour)),
+		{Name: "pre-upgrade", Events: []rpb.HookEvent{rpb.HookPreUpgrade}},
+	}}
+
+	checks := helmTestChecks(rel, start, errors.New("pod test-mapping failed"))
+	if len(checks) != 2 {
+		t.Fatalf("expected 2 checks, got %+v", checks)
+	}
+	if checks[0].Outcome != ambassador.OutcomeSucceeded || checks[1].Outcome != ambassador.OutcomeFailed {
+		t.Errorf("unexpected checks %+v", checks)
+	}
+
+	// errors without any test result are reported as a failed check
+	checks = helmTestChecks(nil, start, errors.New("release not found"))
+	if len(checks) != 1 || checks[0].Outcome != ambassador.OutcomeFailed {
+		t.Errorf("unexpected checks %+v", checks)
+	}
+}
+
+func TestNeedsVerification(t *testing.T) {
+	now := time.Now()
+	rel := &rpb.Release{Version: 3}
+	status := &ambassador.AmbassadorInstallationStatus{}
+
+	o := newTestAmbInst("ambassador", now, map[string]interface{}{})
+	if needsVerification(&o, status, rel) {
+		t.Errorf("verification needed without spec.verification")
+	}
+
+	o = newTestAmbInst("ambassador", now, map[string]interface{}{
+		"verification": map[string]interface{}{"helmTests": true},
+	})
+	if !needsVerification(&o, status, rel) {
+		t.Errorf("verification not needed for a release never verified")
+	}
+	status.Verification = &ambassador.AmbassadorVerificationStatus{Revision: 3, Outcome: ambassador.OutcomeSucceeded}
+	if needsVerification(&o, status, rel) {
+		t.Errorf("verification needed for a release already verified")
+	}
+	status.Verification.Outcome = ambassador.OutcomeFailed
+	if !needsVerification(&o, status, rel) {
+		t.Errorf("verification not needed for a release that failed the verification")
+	}
+
+	err := fmt.Errorf("failed to upgrade: %w", &VerificationError{Failed: []ambassador.AmbassadorCheckResult{{Name: "ready"}}})
+	if reason := failureReason(err, ambassador.ReasonUpdateError); reason != ambassador.ReasonVerificationError {
+		t.Errorf("failureReason() = %s, expected %s", reason, ambassador.ReasonVerificationError)
+	}
+}
+
+func TestIsRolledBackError(t *testing.T) {
+	patchErr := &PatchError{Index: 1, Resource: "Deployment/ambassador", Err: errors.New("boom")}
+	err := &release.RolledBackError{Err: fmt.Errorf("failed to update release: %w", patchErr)}
+	if !isRolledBackError(fmt.Errorf("upgrade: %w", err)) {
+		t.Errorf("the rollback of the release manager has not been detected")
+	}
+	if reason := failureReason(err, ambassador.ReasonUpdateError); reason != ambassador.ReasonPatchError {
+		t.Errorf("failureReason() = %s, expected %s", reason, ambassador.ReasonPatchError)
+	}
+	if isRolledBackError(fmt.Errorf("failed to update release: %w", patchErr)) {
+		t.Errorf("rollback detected for an upgrade that was not rolled back")
+	}
+}
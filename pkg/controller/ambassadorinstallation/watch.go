@@ -0,0 +1,66 @@
+package ambassadorinstallation
+
+import (
+	"os"
+	"strings"
+
+	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	crtpredicate "sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// WatchLabelSelectorEnvVar is the environment variable with the label selector for the
+	// installations processed by the operator
+	WatchLabelSelectorEnvVar = "WATCH_LABEL_SELECTOR"
+)
+
+// GetWatchNamespaces returns the list of namespaces watched by the operator, obtained from
+// the (comma-separated) WATCH_NAMESPACE environment variable. An empty list means
+// that all the namespaces are watched.
+func GetWatchNamespaces() ([]string, error) {
+	ns, err := k8sutil.GetWatchNamespace()
+	if err != nil {
+		return nil, err
+	}
+	return parseNamespaces(ns), nil
+}
+
+// parseNamespaces parses a comma-separated list of namespaces
+func parseNamespaces(s string) []string {
+	res := []string{}
+	for _, ns := range strings.Split(s, ",") {
+		ns = strings.TrimSpace(ns)
+		if len(ns) > 0 && !contains(res, ns) {
+			res = append(res, ns)
+		}
+	}
+	return res
+}
+
+// GetWatchLabelSelector returns the label selector for the installations processed by the
+// operator, obtained from the WATCH_LABEL_SELECTOR environment variable. All the
+// installations are processed when it is not set.
+func GetWatchLabelSelector() (labels.Selector, error) {
+	return labels.Parse(os.Getenv(WatchLabelSelectorEnvVar))
+}
+
+// SelectorPredicateFuncs returns functions for filtering the events in objects that do not
+// match a label selector
+func SelectorPredicateFuncs(selector labels.Selector) crtpredicate.Funcs {
+	return crtpredicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return selector.Matches(labels.Set(e.Meta.GetLabels()))
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return selector.Matches(labels.Set(e.Meta.GetLabels()))
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return selector.Matches(labels.Set(e.Meta.GetLabels()))
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return selector.Matches(labels.Set(e.MetaNew.GetLabels()))
+		},
+	}
+}
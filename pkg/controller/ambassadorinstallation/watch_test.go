@@ -0,0 +1,55 @@
+package ambassadorinstallation
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestParseNamespaces(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"", []string{}},
+		{"ambassador", []string{"ambassador"}},
+		{"sales, marketing,,sales", []string{"sales", "marketing"}},
+	}
+	for _, test := range tests {
+		if got := parseNamespaces(test.s); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseNamespaces(%q) = %v, want %v", test.s, got, test.want)
+		}
+	}
+}
+
+func TestGetWatchLabelSelector(t *testing.T) {
+	defer os.Unsetenv(WatchLabelSelectorEnvVar)
+
+	os.Unsetenv(WatchLabelSelectorEnvVar)
+	selector, err := GetWatchLabelSelector()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !selector.Matches(labels.Set{"unit": "sales"}) {
+		t.Errorf("empty selector should match everything")
+	}
+
+	os.Setenv(WatchLabelSelectorEnvVar, "unit=sales")
+	selector, err = GetWatchLabelSelector()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !selector.Matches(labels.Set{"unit": "sales"}) {
+		t.Errorf("selector %q should match unit=sales", selector)
+	}
+	if selector.Matches(labels.Set{"unit": "marketing"}) {
+		t.Errorf("selector %q should not match unit=marketing", selector)
+	}
+
+	os.Setenv(WatchLabelSelectorEnvVar, "unit in (")
+	if _, err := GetWatchLabelSelector(); err == nil {
+		t.Errorf("expected an error for an invalid selector")
+	}
+}
@@ -0,0 +1,204 @@
+package ambassadorinstallation
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+
+	ambassadorv3 "github.com/datawire/ambassador-operator/pkg/apis/getambassador/v3"
+)
+
+const (
+	// EnableWebhooksEnvVar is the environment variable used for enabling the admission webhooks
+	EnableWebhooksEnvVar = "ENABLE_WEBHOOKS"
+
+	// WebhookServiceNameEnvVar is the environment variable with the name of the Service
+	// for the webhook server, used for configuring the conversion webhook in the CRD
+	WebhookServiceNameEnvVar = "WEBHOOK_SERVICE_NAME"
+
+	// DefaultWebhookPort is the default port where the webhook server listens at
+	DefaultWebhookPort = 9443
+
+	// ConversionWebhookPath is the path where the conversion webhook is served
+	ConversionWebhookPath = "/convert"
+
+	// name of the CA certificate file in the webhook server's certificates directory
+	webhookCAName = "ca.crt"
+)
+
+// the CRD with more than one version, that needs a conversion webhook
+const conversionCRDName = "ambassadorinstallations.getambassador.io"
+
+var crdGVK = schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1beta1", Kind: "CustomResourceDefinition"}
+
+// WebhooksEnabled returns true if the admission webhooks have been enabled in the environment
+func WebhooksEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(EnableWebhooksEnvVar))
+	return enabled
+}
+
+// ValidatingWebhookPath returns the path where the validating webhook for some GVK is served
+// (ie, `/validate-getambassador-io-v2-ambassadorinstallation`)
+func ValidatingWebhookPath(gvk schema.GroupVersionKind) string {
+	return "/validate-" + strings.Replace(gvk.Group, ".", "-", -1) + "-" + gvk.Version + "-" + strings.ToLower(gvk.Kind)
+}
+
+// MutatingWebhookPath returns the path where the defaulting webhook for some GVK is served
+// (ie, `/mutate-getambassador-io-v3-ambassadorinstallation`)
+func MutatingWebhookPath(gvk schema.GroupVersionKind) string {
+	return "/mutate-" + strings.Replace(gvk.Group, ".", "-", -1) + "-" + gvk.Version + "-" + strings.ToLower(gvk.Kind)
+}
+
+// AddWebhooks registers the admission webhooks for all the installation kinds in the
+// webhook server of the Manager, as well as the defaulting and conversion webhooks
+// for the `v3` AmbassadorInstallations.
+func AddWebhooks(mgr manager.Manager) error {
+	selector, err := GetWatchLabelSelector()
+	if err != nil {
+		return err
+	}
+	server := mgr.GetWebhookServer()
+	for _, gvk := range []schema.GroupVersionKind{DefaultGVK, ClusterGVK} {
+		path := ValidatingWebhookPath(gvk)
+		log.Info("Registering validating webhook", "kind", gvk.Kind, "path", path)
+		server.Register(path, &webhook.Admission{
+			Handler: &installationValidator{selector: selector},
+		})
+	}
+
+	v3GVK := ambassadorv3.SchemeGroupVersion.WithKind(DefaultGVK.Kind)
+	log.Info("Registering defaulting webhook", "kind", v3GVK.Kind, "path", MutatingWebhookPath(v3GVK))
+	server.Register(MutatingWebhookPath(v3GVK), admission.DefaultingWebhookFor(&ambassadorv3.AmbassadorInstallation{}))
+
+	log.Info("Registering conversion webhook", "path", ConversionWebhookPath)
+	server.Register(ConversionWebhookPath, &conversion.Webhook{})
+
+	return configureConversionWebhook(mgr.GetConfig(), server.CertDir)
+}
+
+// configureConversionWebhook points the conversion webhook in the AmbassadorInstallations CRD to
+// the webhook server. This is skipped when the Service or the CA certificate are unknown: the
+// CRD must be configured by some other means.
+func configureConversionWebhook(cfg *rest.Config, certDir string) error {
+	serviceName := os.Getenv(WebhookServiceNameEnvVar)
+	if len(serviceName) == 0 {
+		log.Info("No webhook Service provided: conversion webhook not configured in the CRD", "env", WebhookServiceNameEnvVar)
+		return nil
+	}
+	serviceNamespace, err := k8sutil.GetOperatorNamespace()
+	if err != nil {
+		return err
+	}
+	caBundle, err := ioutil.ReadFile(filepath.Join(certDir, webhookCAName))
+	if err != nil {
+		log.Info("No CA certificate found: conversion webhook not configured in the CRD", "error", err)
+		return nil
+	}
+
+	// note: use a new client, as the manager's client cannot be used until the manager has been started
+	c, err := client.New(cfg, client.Options{})
+	if err != nil {
+		return err
+	}
+
+	crd := &unstructured.Unstructured{}
+	crd.SetGroupVersionKind(crdGVK)
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: conversionCRDName}, crd); err != nil {
+		return err
+	}
+	conversion := map[string]interface{}{
+		"strategy": "Webhook",
+		"webhookClientConfig": map[string]interface{}{
+			"service": map[string]interface{}{
+				"name":      serviceName,
+				"namespace": serviceNamespace,
+				"path":      ConversionWebhookPath,
+			},
+			"caBundle": base64.StdEncoding.EncodeToString(caBundle),
+		},
+		"conversionReviewVersions": []interface{}{"v1beta1"},
+	}
+	if err := enableConversionWebhook(crd, conversion); err != nil {
+		return err
+	}
+
+	log.Info("Configuring the conversion webhook in the CRD", "crd", conversionCRDName,
+		"service", serviceName, "namespace", serviceNamespace)
+	return c.Update(context.TODO(), crd)
+}
+
+// enableConversionWebhook sets the conversion webhook in the CRD and starts serving
+// all its versions, as the versions that are not stored (ie, `v3`) are not
+// served until they can be converted.
+func enableConversionWebhook(crd *unstructured.Unstructured, conversion map[string]interface{}) error {
+	versions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		if version, ok := v.(map[string]interface{}); ok {
+			version["served"] = true
+		}
+	}
+	if err := unstructured.SetNestedSlice(crd.Object, versions, "spec", "versions"); err != nil {
+		return err
+	}
+	return unstructured.SetNestedMap(crd.Object, conversion, "spec", "conversion")
+}
+
+// installationValidator is an admission handler that validates AmbassadorInstallations
+// (and ClusterAmbassadorInstallations)
+type installationValidator struct {
+	// installations not matching this selector are not processed by this operator
+	selector labels.Selector
+}
+
+// Handle validates a create/update of an installation
+func (v *installationValidator) Handle(_ context.Context, req admission.Request) admission.Response {
+	if req.Operation == admissionv1beta1.Delete {
+		return admission.Allowed("")
+	}
+
+	o := &unstructured.Unstructured{}
+	if err := o.UnmarshalJSON(req.Object.Raw); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// installations not matching our label selector are managed by some other operator
+	if v.selector != nil && !v.selector.Matches(labels.Set(o.GetLabels())) {
+		return admission.Allowed("")
+	}
+
+	var old *unstructured.Unstructured
+	if req.Operation == admissionv1beta1.Update && len(req.OldObject.Raw) > 0 {
+		old = &unstructured.Unstructured{}
+		if err := old.UnmarshalJSON(req.OldObject.Raw); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+
+	if errs := validateInstallation(o, old); len(errs) > 0 {
+		log.V(1).Info("Rejecting invalid installation",
+			"kind", o.GetKind(), "namespace", o.GetNamespace(), "name", o.GetName(), "errors", errs.ToAggregate().Error())
+		return admission.Denied(errs.ToAggregate().Error())
+	}
+	return admission.Allowed("")
+}
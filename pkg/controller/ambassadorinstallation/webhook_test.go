@@ -0,0 +1,34 @@
+package ambassadorinstallation
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestEnableConversionWebhook(t *testing.T) {
+	crd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"versions": []interface{}{
+				map[string]interface{}{"name": "v2", "served": true, "storage": true},
+				map[string]interface{}{"name": "v3", "served": false, "storage": false},
+			},
+		},
+	}}
+	conversion := map[string]interface{}{"strategy": "Webhook"}
+
+	if err := enableConversionWebhook(crd, conversion); err != nil {
+		t.Fatalf("could not enable the conversion webhook: %s", err)
+	}
+
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, v := range versions {
+		version := v.(map[string]interface{})
+		if served, _ := version["served"].(bool); !served {
+			t.Errorf("version %q not served with the conversion webhook", version["name"])
+		}
+	}
+	if strategy, _, _ := unstructured.NestedString(crd.Object, "spec", "conversion", "strategy"); strategy != "Webhook" {
+		t.Errorf("conversion strategy %q, expected %q", strategy, "Webhook")
+	}
+}
@@ -1,6 +1,7 @@
 package helm
 
 import (
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -12,6 +13,9 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/mholt/archiver/v3"
 	"k8s.io/helm/pkg/chartutil"
@@ -22,6 +26,8 @@ import (
 	"k8s.io/helm/pkg/repo"
 
 	"github.com/datawire/ambassador/pkg/k8s"
+
+	"github.com/datawire/ambassador-operator/pkg/tracing"
 )
 
 const (
@@ -48,23 +54,29 @@ var (
 
 // DownloadFile will download a url to a local file. It's efficient because it will
 // write as it downloads and not load the whole file into memory.
-func downloadFile(filepath string, url string) error {
-	resp, err := http.Get(url)
+// It returns the number of bytes downloaded. The download is cancelled with the context.
+func downloadFile(ctx context.Context, filepath string, url string) (int64, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	// Create the file
 	out, err := os.Create(filepath)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer func() { _ = out.Close() }()
 
 	// Write the body to file
-	_, err = io.Copy(out, resp.Body)
-	return err
+	return io.Copy(out, resp.Body)
 }
 
 // fileIsArchive returns True if the URL points to an archive
@@ -85,6 +97,9 @@ type Downloader struct {
 	URL     *url.URL
 	Version ChartVersionRule
 
+	// An (optional) rule for the version of the Chart (the Version is applied on the AppVersion)
+	ChartVersion ChartVersionRule
+
 	KubeInfo  *k8s.KubeInfo
 	ChartName string
 
@@ -96,16 +111,20 @@ type Downloader struct {
 	downChartDir   string
 	downDirCleanup bool
 
+	// All the versions of the chart found in the repo index (when the URL is a Helm repo)
+	repoVersions repo.ChartVersions
+
 	log *log.Logger
 }
 
 // DownloaderOptions specifies options for creating the Helm manager
 type DownloaderOptions struct {
-	URL       string
-	KubeInfo  *k8s.KubeInfo
-	Version   ChartVersionRule
-	Logger    *log.Logger
-	ChartName string
+	URL          string
+	KubeInfo     *k8s.KubeInfo
+	Version      ChartVersionRule
+	ChartVersion ChartVersionRule
+	Logger       *log.Logger
+	ChartName    string
 }
 
 // NewDownloader creates a new charts manager
@@ -129,11 +148,12 @@ func NewDownloader(options DownloaderOptions) (Downloader, error) {
 	}
 
 	return Downloader{
-		URL:       pu,
-		KubeInfo:  options.KubeInfo,
-		Version:   options.Version,
-		log:       options.Logger,
-		ChartName: options.ChartName,
+		URL:          pu,
+		KubeInfo:     options.KubeInfo,
+		Version:      options.Version,
+		ChartVersion: options.ChartVersion,
+		log:          options.Logger,
+		ChartName:    options.ChartName,
 	}, nil
 }
 
@@ -152,27 +172,47 @@ func (lc Downloader) GetVersionRule() ChartVersionRule {
 	return lc.Version
 }
 
+// GetChartVersionRule returns the chart version rule associated with this Helm manager
+func (lc Downloader) GetChartVersionRule() ChartVersionRule {
+	return lc.ChartVersion
+}
+
 // GetReleaseMgr returns a manager for the latest
-func (lc *Downloader) Download() error {
-	var err error
+func (lc *Downloader) Download(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "Downloader.Download",
+		tracing.String("helm.repo", lc.URL.String()),
+		tracing.String("helm.version", lc.Version.String()),
+		tracing.String("helm.chartVersion", lc.ChartVersion.String()))
+	defer func() {
+		if err == nil && lc.downChart != nil {
+			span.SetAttributes(
+				tracing.String("chart.version", lc.downChart.Version),
+				tracing.String("chart.appVersion", lc.downChart.AppVersion))
+		}
+		span.RecordError(err)
+		span.End()
+	}()
 
 	// parse the helm repo URL and try to download the helm chart
 	switch lc.URL.Scheme {
 	case "http", "https":
 		if fileIsArchive(*lc.URL) {
 			lc.log.Printf("URL points to an archive: downloading")
-			if err := lc.downloadChartFile(lc.URL); err != nil {
+			if err := lc.downloadChartFile(ctx, lc.URL); err != nil {
 				return err
 			}
 		} else {
 			lc.log.Printf("URL is a Helm repo: looking for version in repo")
+			_, repoSpan := tracing.Start(ctx, "Downloader.findInRepo")
 			u, err := lc.findInRepo()
+			repoSpan.RecordError(err)
+			repoSpan.End()
 			if err != nil {
 				return err
 			}
 
 			lc.log.Printf("Downloading release from %q", u)
-			if err := lc.downloadChartFile(u); err != nil {
+			if err := lc.downloadChartFile(ctx, u); err != nil {
 				return err
 			}
 		}
@@ -209,11 +249,18 @@ func (lc *Downloader) Cleanup() error {
 	lc.downChartDir = ""
 	lc.downChartFile = ""
 	lc.downChart = nil
+	lc.repoVersions = nil
 	return nil
 }
 
 // downloadChartFile downloads a Chart archive from a URL
-func (lc *Downloader) downloadChartFile(url *url.URL) error {
+func (lc *Downloader) downloadChartFile(ctx context.Context, url *url.URL) (err error) {
+	ctx, span := tracing.Start(ctx, "Downloader.downloadChartFile", tracing.String("url", url.String()))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
 	// creates/erases the downloads directory, ignoring any error (just in case it does not exist)
 	d, err := ioutil.TempDir("", "chart-download")
 	if err != nil {
@@ -230,7 +277,11 @@ func (lc *Downloader) downloadChartFile(url *url.URL) error {
 	tempFilename := filepath.Join(os.TempDir(), fmt.Sprintf("%s-%s", hex.EncodeToString(randBytes), filename))
 
 	lc.log.Printf("Downloading file %q (temp=%q) (dest=%q)", url, tempFilename, lc.downChartDir)
-	if err := downloadFile(tempFilename, url.String()); err != nil {
+	start := time.Now()
+	n, err := downloadFile(ctx, tempFilename, url.String())
+	observeChartDownload(n, time.Since(start), err)
+	span.SetAttributes(tracing.Int("bytes", int(n)))
+	if err != nil {
 		return err
 	}
 	defer func() { _ = os.Remove(tempFilename) }()
@@ -244,7 +295,98 @@ func (lc *Downloader) downloadChartFile(url *url.URL) error {
 	return nil
 }
 
-func (lc *Downloader) findInRepo() (*url.URL, error) {
+// IsRepo returns True if the URL points to a Helm repo (and not to an archive or a directory)
+func (lc Downloader) IsRepo() bool {
+	switch lc.URL.Scheme {
+	case "http", "https":
+		return !fileIsArchive(*lc.URL)
+	default:
+		return false
+	}
+}
+
+// FindVersions returns all the chart versions in the Helm repo that are allowed
+// by the version rule, sorted from the most recent to the oldest one. It returns
+// an empty list when the URL does not point to a Helm repo.
+func (lc *Downloader) FindVersions() (repo.ChartVersions, error) {
+	if !lc.IsRepo() {
+		return repo.ChartVersions{}, nil
+	}
+
+	versions, err := lc.loadRepoVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, err := lc.allowedVersions(versions)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(allowed, func(i, j int) bool {
+		return moreRecentChart(allowed[i], allowed[j])
+	})
+	return allowed, nil
+}
+
+// repoIndexEntry are the versions of a chart found in the index of a Helm repo
+type repoIndexEntry struct {
+	versions repo.ChartVersions
+	loaded   time.Time
+}
+
+// repoIndexCache keeps the last versions found in the Helm repos, by repo URL and
+// chart name, so they can be reused across reconciliations. Entries older than the
+// last `maxAge` requested in UseCachedVersions are evicted.
+var repoIndexCache = struct {
+	sync.Mutex
+	entries map[string]repoIndexEntry
+	maxAge  time.Duration
+}{entries: map[string]repoIndexEntry{}}
+
+func (lc *Downloader) repoIndexKey() string {
+	return lc.URL.String() + "#" + lc.ChartName
+}
+
+// evictRepoIndexCache removes the entries that are too old for being used again.
+// The cache must be locked.
+func evictRepoIndexCache(now time.Time) {
+	if repoIndexCache.maxAge == 0 {
+		return
+	}
+	for key, entry := range repoIndexCache.entries {
+		if now.Sub(entry.loaded) >= repoIndexCache.maxAge {
+			delete(repoIndexCache.entries, key)
+		}
+	}
+}
+
+// UseCachedVersions makes FindVersions use the versions found in the Helm repo by any
+// Downloader in the last `maxAge`, instead of downloading the index again. It returns
+// false when there are no versions recent enough.
+func (lc *Downloader) UseCachedVersions(maxAge time.Duration, now time.Time) bool {
+	if !lc.IsRepo() {
+		return false
+	}
+	repoIndexCache.Lock()
+	defer repoIndexCache.Unlock()
+	repoIndexCache.maxAge = maxAge
+	evictRepoIndexCache(now)
+	entry, ok := repoIndexCache.entries[lc.repoIndexKey()]
+	if !ok {
+		return false
+	}
+	lc.repoVersions = entry.versions
+	return true
+}
+
+// loadRepoVersions downloads the index of the Helm repo and returns all the
+// versions of the chart. The index is downloaded only once until the next Cleanup().
+func (lc *Downloader) loadRepoVersions() (repo.ChartVersions, error) {
+	if lc.repoVersions != nil {
+		return lc.repoVersions, nil
+	}
+
 	chartName := lc.ChartName
 	repoURL := lc.URL.String()
 
@@ -285,6 +427,83 @@ func (lc *Downloader) findInRepo() (*url.URL, error) {
 		return nil, repo.ErrNoChartVersion
 	}
 
+	lc.repoVersions = versions
+
+	now := time.Now()
+	repoIndexCache.Lock()
+	evictRepoIndexCache(now)
+	repoIndexCache.entries[lc.repoIndexKey()] = repoIndexEntry{versions: versions, loaded: now}
+	repoIndexCache.Unlock()
+	return versions, nil
+}
+
+// allowedVersions filters the list of versions, returning only the ones allowed by
+// the version rule (and the chart version rule, when provided)
+func (lc *Downloader) allowedVersions(versions repo.ChartVersions) (repo.ChartVersions, error) {
+	res := repo.ChartVersions{}
+	for _, curVer := range versions {
+		allowed, err := lc.Version.Allowed(curVer.AppVersion)
+		if err != nil {
+			return nil, fmt.Errorf("%w while checking if allowed for %s", err, lc.Version)
+		}
+		if !allowed {
+			lc.log.Printf("Chart not allowed by version constraint: version=%q, required=%q", curVer.AppVersion, lc.Version)
+			continue
+		}
+
+		if !lc.ChartVersion.IsEmpty() {
+			allowed, err := lc.ChartVersion.Allowed(curVer.Version)
+			if err != nil {
+				return nil, fmt.Errorf("%w while checking if allowed for chart version %s", err, lc.ChartVersion)
+			}
+			if !allowed {
+				lc.log.Printf("Chart not allowed by chart version constraint: chartVersion=%q, required=%q", curVer.Version, lc.ChartVersion)
+				continue
+			}
+		}
+		res = append(res, curVer)
+	}
+	return res, nil
+}
+
+// moreRecentChart returns True if the chart `a` is more recent than `b`
+//
+// note: when looking for the right chart, there are two versions to consider:
+//
+// - the AppVersion is the version of the software **installed by** the Chart (ie, Ambassador 1.0)
+// - the Version is the version of the Chart (ie, Ambassador Chart 0.6)
+//
+// So there can be multiple Chart Versions for the same `AppVersion`. For example, we updated
+// the Helm Chart several times for AppVersion=1.0 (AES) because there were some changes
+// in the templates, etc... So once we have a valid/latest `AppVersion`, we must get the chart
+// with the highest `Version`.
+func moreRecentChart(a, b *repo.ChartVersion) bool {
+	// compare the versions: first, the `AppVersion`, and then the `Chart` version
+	if moreRecent, err := MoreRecentThan(a.AppVersion, b.AppVersion); err == nil && moreRecent {
+		return true
+	} else if equal, err := Equal(a.AppVersion, b.AppVersion); err == nil && equal {
+		// if this chart has the same version of Ambassador, then check if it is a more recent Chart
+		if moreRecent, err := MoreRecentThan(a.Version, b.Version); err == nil && moreRecent {
+			return true
+		}
+	}
+	return false
+}
+
+func (lc *Downloader) findInRepo() (*url.URL, error) {
+	chartName := lc.ChartName
+	repoURL := lc.URL.String()
+
+	versions, err := lc.loadRepoVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, err := lc.allowedVersions(versions)
+	if err != nil {
+		return nil, err
+	}
+
 	parsedURL := func(u string) (*url.URL, error) {
 		absoluteChartURL, err := repo.ResolveReferenceURL(repoURL, u)
 		if err != nil {
@@ -299,53 +518,25 @@ func (lc *Downloader) findInRepo() (*url.URL, error) {
 		return pu, nil
 	}
 
-	//
-	// note: when looking for the right chart, there are two versions to consider:
-	//
-	// - the AppVersion is the version of the software **installed by** the Chart (ie, Ambassador 1.0)
-	// - the Version is the version of the Chart (ie, Ambassador Chart 0.6)
-	//
-	// So there can be multiple Chart Versions for the same `AppVersion`. For example, we updated
-	// the Helm Chart several times for AppVersion=1.0 (AES) because there were some changes
-	// in the templates, etc... So once we have a valid/latest `AppVersion`, we must get the chart
-	// with the highest `Version`.
-	//
 	var latest *repo.ChartVersion
-	for _, curVer := range versions {
-		allowed, err := lc.Version.Allowed(curVer.AppVersion)
-		if err != nil {
-			return nil, fmt.Errorf("%w while checking if allowed for %s", err, lc.Version)
-		}
-		if !allowed {
-			lc.log.Printf("Chart not allowed by version constraint: version=%q, required=%q", curVer.AppVersion, lc.Version)
-			continue
-		}
+	for _, curVer := range allowed {
 		if len(curVer.URLs) == 0 {
 			return nil, fmt.Errorf("no URL found for %s-%s", chartName, lc.Version)
 		}
 
 		// no previous `latest` chart: use this one
-		if latest == nil {
-			latest = curVer
-			continue
-		}
-
-		// compare the versions: first, the `AppVersion`, and then the `Chart` version
-		if moreRecent, err := MoreRecentThan(curVer.AppVersion, latest.AppVersion); err == nil && moreRecent {
+		if latest == nil || moreRecentChart(curVer, latest) {
 			lc.log.Printf("Updating 'latest chart version' to %q", curVer)
 			latest = curVer
-		} else if equal, err := Equal(curVer.AppVersion, latest.AppVersion); err == nil && equal {
-			// if this chart has the same version of Ambassador, then check if it is a more recent Chart
-			if moreRecent, err := MoreRecentThan(curVer.Version, latest.Version); err == nil && moreRecent {
-				lc.log.Printf("Updating 'latest chart version' to %q", curVer)
-				latest = curVer
-			}
 		}
 	}
 	if latest != nil {
 		return parsedURL(latest.URLs[0])
 	}
 
+	if !lc.ChartVersion.IsEmpty() {
+		return nil, fmt.Errorf("no chart version found for %s-%s (chart version %s)", chartName, lc.Version, lc.ChartVersion)
+	}
 	return nil, fmt.Errorf("no chart version found for %s-%s", chartName, lc.Version)
 }
 
@@ -0,0 +1,209 @@
+package helm
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testRepoIndex = `apiVersion: v1
+entries:
+  ambassador:
+  - apiVersion: v1
+    appVersion: 1.4.2
+    name: ambassador
+    urls:
+    - ambassador-6.3.5.tgz
+    version: 6.3.5
+  - apiVersion: v1
+    appVersion: 1.4.2
+    name: ambassador
+    urls:
+    - ambassador-6.3.4.tgz
+    version: 6.3.4
+  - apiVersion: v1
+    appVersion: 1.5.0
+    name: ambassador
+    urls:
+    - ambassador-6.4.0.tgz
+    version: 6.4.0
+  - apiVersion: v1
+    appVersion: 1.3.2
+    name: ambassador
+    urls:
+    - ambassador-6.2.0.tgz
+    version: 6.2.0
+generated: "2020-05-01T00:00:00Z"
+`
+
+func newTestRepo(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/index.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(testRepoIndex))
+	}))
+}
+
+func TestFindVersions(t *testing.T) {
+	server := newTestRepo(t)
+	defer server.Close()
+
+	rule, err := NewChartVersionRule("1.4.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDownloader(DownloaderOptions{
+		URL:     server.URL,
+		Version: rule,
+		Logger:  log.New(ioutil.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := d.FindVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"6.3.5", "6.3.4"}
+	if len(versions) != len(expected) {
+		t.Fatalf("Expected %d versions, got %d", len(expected), len(versions))
+	}
+	for i, v := range versions {
+		if v.Version != expected[i] {
+			t.Errorf("Expected chart version %q at %d, got %q", expected[i], i, v.Version)
+		}
+	}
+
+	u, err := d.findInRepo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.String() != server.URL+"/ambassador-6.3.5.tgz" {
+		t.Errorf("Unexpected chart URL %q", u)
+	}
+}
+
+func TestFindVersionsWithChartVersion(t *testing.T) {
+	server := newTestRepo(t)
+	defer server.Close()
+
+	rule, err := NewChartVersionRule("1.4.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chartRule, err := NewChartVersionRule("<6.3.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDownloader(DownloaderOptions{
+		URL:          server.URL,
+		Version:      rule,
+		ChartVersion: chartRule,
+		Logger:       log.New(ioutil.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := d.FindVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 || versions[0].Version != "6.3.4" {
+		t.Fatalf("Expected only chart version 6.3.4, got %v", versions)
+	}
+
+	u, err := d.findInRepo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.String() != server.URL+"/ambassador-6.3.4.tgz" {
+		t.Errorf("Unexpected chart URL %q", u)
+	}
+}
+
+func TestUseCachedVersions(t *testing.T) {
+	var downloads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downloads, 1)
+		_, _ = w.Write([]byte(testRepoIndex))
+	}))
+	defer server.Close()
+
+	rule, err := NewChartVersionRule("1.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newDownloader := func() Downloader {
+		d, err := NewDownloader(DownloaderOptions{URL: server.URL, Version: rule, Logger: log.New(ioutil.Discard, "", 0)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	d := newDownloader()
+	if d.UseCachedVersions(time.Hour, time.Now()) {
+		t.Errorf("there should be no cached versions before downloading the index")
+	}
+	if _, err := d.FindVersions(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new Downloader (ie, in the next reconciliation) reuses the versions
+	d = newDownloader()
+	if !d.UseCachedVersions(time.Hour, time.Now()) {
+		t.Errorf("the versions were not cached")
+	}
+	if versions, err := d.FindVersions(); err != nil || len(versions) != 4 {
+		t.Errorf("unexpected versions %v (%v)", versions, err)
+	}
+	if n := atomic.LoadInt32(&downloads); n != 1 {
+		t.Errorf("the index was downloaded %d times", n)
+	}
+
+	// ...but only while they are recent enough
+	d = newDownloader()
+	if d.UseCachedVersions(time.Hour, time.Now().Add(time.Hour)) {
+		t.Errorf("old versions should not be used")
+	}
+	// ...and they are evicted from the cache
+	repoIndexCache.Lock()
+	defer repoIndexCache.Unlock()
+	if _, ok := repoIndexCache.entries[d.repoIndexKey()]; ok {
+		t.Errorf("old versions were not evicted")
+	}
+}
+
+func TestDownloadFileCancelled(t *testing.T) {
+	server := newTestRepo(t)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	dest := filepath.Join(dir, "index.yaml")
+	if n, err := downloadFile(context.Background(), dest, server.URL+"/index.yaml"); err != nil || n != int64(len(testRepoIndex)) {
+		t.Fatalf("unexpected download: %d bytes, %v", n, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := downloadFile(ctx, dest, server.URL+"/index.yaml"); err == nil {
+		t.Errorf("expected an error for a cancelled context")
+	}
+}
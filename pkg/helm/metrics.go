@@ -0,0 +1,42 @@
+package helm
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	chartDownloadBytes = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "ambassador_operator",
+			Name:      "chart_download_bytes_total",
+			Help:      "Number of bytes downloaded for Helm charts",
+		},
+	)
+
+	chartDownloadDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "ambassador_operator",
+			Name:      "chart_download_duration_seconds",
+			Help:      "Latency of the Helm chart downloads, by result (success or error)",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"result"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(chartDownloadBytes, chartDownloadDuration)
+}
+
+// observeChartDownload records the size and latency of a chart download in the metrics
+func observeChartDownload(n int64, d time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	chartDownloadBytes.Add(float64(n))
+	chartDownloadDuration.WithLabelValues(result).Observe(d.Seconds())
+}
@@ -0,0 +1,114 @@
+package release
+
+import (
+	"io"
+
+	"helm.sh/helm/v3/pkg/kube"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+const (
+	// OwnerNameLabel is the label used for recording the name of the owner of a resource
+	// when an owner reference cannot be used (ie, the resource lives in a different namespace)
+	OwnerNameLabel = "getambassador.io/owner-name"
+
+	// OwnerNamespaceLabel is the label used for recording the namespace of the owner of a resource
+	// when an owner reference cannot be used (ie, the resource lives in a different namespace)
+	OwnerNamespaceLabel = "getambassador.io/owner-namespace"
+
+	// OwnerKindLabel is the label used for recording the kind of the owner of a resource
+	// when an owner reference cannot be used (ie, the resource lives in a different namespace)
+	OwnerKindLabel = "getambassador.io/owner-kind"
+
+	// the kind of the CRDs: they are never garbage-collected
+	crdKind = "CustomResourceDefinition"
+)
+
+// OwnerLabels returns the labels that identify a resource owned by `owner`
+func OwnerLabels(owner *unstructured.Unstructured) map[string]string {
+	return map[string]string{
+		OwnerNameLabel:      owner.GetName(),
+		OwnerNamespaceLabel: owner.GetNamespace(),
+		OwnerKindLabel:      owner.GetKind(),
+	}
+}
+
+// NewOwnerLabelsInjectingClient returns a client that adds some labels pointing to
+// the owner to all the namespaced resources created. This is used instead of owner
+// references when the owner lives in a different namespace, as Kubernetes does
+// not support cross-namespace owner references.
+func NewOwnerLabelsInjectingClient(base kube.Client, owner *unstructured.Unstructured) kube.Interface {
+	labels := OwnerLabels(owner)
+	return &ownerInjectingClient{
+		Client: base,
+		inject: func(u *unstructured.Unstructured, clusterScoped bool) {
+			if !clusterScoped {
+				addLabels(u, labels)
+			}
+		},
+	}
+}
+
+// NewClusterOwnerInjectingClient returns a client for a cluster-scoped owner: it injects
+// the owner as an owner reference in all the resources created, namespaced or not.
+// CRDs are the exception: they are labeled with the owner (see OwnerLabels) instead,
+// as deleting a CRD would remove all the resources of that kind in the cluster.
+func NewClusterOwnerInjectingClient(base kube.Client, owner *unstructured.Unstructured) kube.Interface {
+	labels := OwnerLabels(owner)
+	refs := []metav1.OwnerReference{*metav1.NewControllerRef(owner, owner.GroupVersionKind())}
+	return &ownerInjectingClient{
+		Client: base,
+		inject: func(u *unstructured.Unstructured, clusterScoped bool) {
+			if u.GetKind() == crdKind {
+				addLabels(u, labels)
+				return
+			}
+			u.SetOwnerReferences(refs)
+		},
+	}
+}
+
+var _ kube.Interface = &ownerInjectingClient{}
+
+type ownerInjectingClient struct {
+	inject func(u *unstructured.Unstructured, clusterScoped bool)
+	kube.Client
+}
+
+func (c *ownerInjectingClient) Build(reader io.Reader, validate bool) (kube.ResourceList, error) {
+	resourceList, err := c.Client.Build(reader, validate)
+	if err != nil {
+		return resourceList, err
+	}
+	err = resourceList.Visit(func(r *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		objMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(r.Object)
+		if err != nil {
+			return err
+		}
+		u := &unstructured.Unstructured{Object: objMap}
+		c.inject(u, r.ResourceMapping().Scope.Name() == meta.RESTScopeNameRoot)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resourceList, nil
+}
+
+func addLabels(u *unstructured.Unstructured, extra map[string]string) {
+	labels := u.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for k, v := range extra {
+		labels[k] = v
+	}
+	u.SetLabels(labels)
+}
@@ -0,0 +1,24 @@
+// Package release provides a manager for the Helm releases of a custom resource.
+//
+// manager.go and manager_factory.go are a fork of the release manager of the Helm
+// operator in the Operator SDK v0.15.0, available at
+// https://github.com/operator-framework/operator-sdk/tree/v0.15.0/pkg/helm/release.
+// The upstream manager cannot be extended from outside of its package: it always
+// derives the release name from the custom resource, and it does not give access
+// to the Helm actions and the storage. The Ambassador Operator needs:
+//
+//   - custom release names (ManagerOptions.ReleaseName), for several installations
+//     in the same namespace.
+//   - rendering a release without installing it (RenderRelease).
+//   - post-renderers for the manifests (ManagerOptions.PostRenderers).
+//   - validating the values against the chart schema (ValidateValues).
+//   - running the Helm tests and rolling back releases (TestRelease, RollbackRelease).
+//   - knowing when UpdateRelease has rolled back a failed upgrade (RolledBackError).
+//   - the resources repaired by ReconcileRelease, and the DeployedRelease.
+//   - recovering releases stuck in a pending status in Sync (Recovery).
+//
+// The upstream `internal/types` status is not used, and `pborman/uuid` has been replaced
+// by `google/uuid`. When rebasing against a newer Operator SDK, start from the upstream
+// files and re-apply these changes (the code added for the operator lives in other files
+// of this package whenever possible).
+package release
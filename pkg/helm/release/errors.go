@@ -0,0 +1,15 @@
+package release
+
+// RolledBackError is the error returned by UpdateRelease when the upgrade failed after
+// recording the new release, and the release has been rolled back to the previous revision
+type RolledBackError struct {
+	Err error
+}
+
+func (e *RolledBackError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RolledBackError) Unwrap() error {
+	return e.Err
+}
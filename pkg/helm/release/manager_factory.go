@@ -0,0 +1,340 @@
+// Copyright 2018 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file is a fork of pkg/helm/release/manager_factory.go from the Operator SDK v0.15.0
+// (github.com/operator-framework/operator-sdk@v0.15.0). See doc.go for the reasons
+// of the fork and the changes made to the upstream code.
+
+package release
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	helm2to3 "github.com/helm/helm-2to3/pkg/v3"
+	"github.com/martinlindhe/base36"
+	"github.com/operator-framework/operator-sdk/pkg/helm/client"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/kube"
+	helmreleasev3 "helm.sh/helm/v3/pkg/release"
+	storagev3 "helm.sh/helm/v3/pkg/storage"
+	driverv3 "helm.sh/helm/v3/pkg/storage/driver"
+	"helm.sh/helm/v3/pkg/strvals"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	helmreleasev2 "k8s.io/helm/pkg/proto/hapi/release"
+	storagev2 "k8s.io/helm/pkg/storage"
+	driverv2 "k8s.io/helm/pkg/storage/driver"
+	crmanager "sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// ManagerOptions are some options for creating a new Manager
+type ManagerOptions struct {
+	// ReleaseName is the name of the release. When empty, the name will be
+	// obtained from the custom resource.
+	ReleaseName string
+
+	// Namespace is the namespace where the release will be installed. When
+	// empty, the namespace of the custom resource is used (so it is required
+	// for cluster-scoped custom resources). Resources installed in a namespace
+	// different to the custom resource's are not owned through owner references
+	// but with some labels (see OwnerLabels).
+	Namespace string
+
+	// PostRenderers modify the resources rendered by the chart before
+	// they are sent to the cluster (see NewPostRenderingClient).
+	PostRenderers []PostRenderer
+
+	// StalePendingThreshold is the time after which a release in a pending status
+	// is recovered (see DefaultStalePendingThreshold, used when it is zero).
+	StalePendingThreshold time.Duration
+}
+
+// ManagerFactory creates Managers that are specific to custom resources. It is
+// used by the reconciler during resource reconciliation, and it improves
+// decoupling between reconciliation logic and the Helm backend components
+// used to manage releases.
+type ManagerFactory interface {
+	NewManager(r *unstructured.Unstructured, overrideValues map[string]string, options ManagerOptions) (Manager, error)
+}
+
+type managerFactory struct {
+	mgr      crmanager.Manager
+	chartDir string
+}
+
+// NewManagerFactory returns a new Helm manager factory capable of installing and uninstalling releases.
+func NewManagerFactory(mgr crmanager.Manager, chartDir string) ManagerFactory {
+	return &managerFactory{mgr, chartDir}
+}
+
+func (f managerFactory) NewManager(cr *unstructured.Unstructured, overrideValues map[string]string, options ManagerOptions) (Manager, error) {
+	namespace := options.Namespace
+	if namespace == "" {
+		namespace = cr.GetNamespace()
+	}
+	if namespace == "" {
+		return nil, fmt.Errorf("no namespace provided for the release")
+	}
+
+	// Get both v2 and v3 storage backends
+	clientv1, err := v1.NewForConfig(f.mgr.GetConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get core/v1 client: %w", err)
+	}
+	storageBackendV3 := storagev3.Init(driverv3.NewSecrets(clientv1.Secrets(namespace)))
+
+	// Automatically convert V2 releases to V3 releases. This is required to
+	// maintain backward compatibility with old releases now that the
+	// operator reconciliation loop expects Helm V3 releases.
+	// V2 releases can only exist in the namespace of the custom resource.
+	if namespace == cr.GetNamespace() {
+		storageBackendV2 := storagev2.Init(driverv2.NewSecrets(clientv1.Secrets(namespace)))
+		if err := convertV2ToV3(storageBackendV2, storageBackendV3, cr); err != nil {
+			return nil, fmt.Errorf("failed to convert releases from v2 to v3: %w", err)
+		}
+	}
+
+	// Get the necessary clients and client getters. Use a client that injects the CR
+	// as an owner reference into all resources templated by the chart (or some labels
+	// pointing to the CR when the release is installed in some other namespace).
+	rcg, err := client.NewRESTClientGetter(f.mgr, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get REST client getter from manager: %w", err)
+	}
+	kubeClient := kube.New(rcg)
+	var ownerClient kube.Interface
+	switch {
+	case cr.GetNamespace() == "":
+		ownerClient = NewClusterOwnerInjectingClient(*kubeClient, cr)
+	case namespace == cr.GetNamespace():
+		ownerRef := metav1.NewControllerRef(cr, cr.GroupVersionKind())
+		ownerClient = client.NewOwnerRefInjectingClient(*kubeClient, *ownerRef)
+	default:
+		ownerClient = NewOwnerLabelsInjectingClient(*kubeClient, cr)
+	}
+	ownerClient = NewPostRenderingClient(ownerClient, options.PostRenderers...)
+
+	crChart, err := loader.LoadDir(f.chartDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart dir: %w", err)
+	}
+
+	releaseName, err := getReleaseName(storageBackendV3, crChart.Name(), cr, options.ReleaseName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get helm release name: %w", err)
+	}
+
+	crValues, ok := cr.Object["spec"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("failed to get spec: expected map[string]interface{}")
+	}
+
+	expOverrides, err := parseOverrides(overrideValues)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse override values: %w", err)
+	}
+	values := mergeMaps(crValues, expOverrides)
+
+	stalePendingThreshold := options.StalePendingThreshold
+	if stalePendingThreshold <= 0 {
+		stalePendingThreshold = DefaultStalePendingThreshold
+	}
+
+	actionConfig := &action.Configuration{
+		RESTClientGetter: rcg,
+		Releases:         storageBackendV3,
+		KubeClient:       ownerClient,
+		Log:              func(_ string, _ ...interface{}) {},
+	}
+
+	return &manager{
+		actionConfig:   actionConfig,
+		storageBackend: storageBackendV3,
+		kubeClient:     ownerClient,
+
+		releaseName: releaseName,
+		namespace:   namespace,
+
+		stalePendingThreshold: stalePendingThreshold,
+
+		chart:  crChart,
+		values: values,
+	}, nil
+}
+
+func convertV2ToV3(storageBackendV2 *storagev2.Storage, storageBackendV3 *storagev3.Storage, cr *unstructured.Unstructured) error {
+	// If a v2 release with the legacy name exists, convert it to v3.
+	legacyName := getLegacyName(cr)
+	legacyHistoryV2, legacyExistsV2, err := releaseHistoryV2(storageBackendV2, legacyName)
+	if err != nil {
+		return err
+	}
+	if legacyExistsV2 {
+		return convertHistoryToV3(legacyHistoryV2, storageBackendV2, storageBackendV3)
+	}
+
+	// If a v2 release with the CR name exists, convert it to v3.
+	releaseName := cr.GetName()
+	historyV2, existsV2, err := releaseHistoryV2(storageBackendV2, releaseName)
+	if err != nil {
+		return err
+	}
+	if existsV2 {
+		return convertHistoryToV3(historyV2, storageBackendV2, storageBackendV3)
+	}
+	return nil
+}
+
+func convertHistoryToV3(history []*helmreleasev2.Release, storageBackendV2 *storagev2.Storage, storageBackendV3 *storagev3.Storage) error {
+	for _, relV2 := range history {
+		relV3, err := helm2to3.CreateRelease(relV2)
+		if err != nil {
+			return fmt.Errorf("generate v3 release: %w", err)
+		}
+		if err := storageBackendV3.Create(relV3); err != nil {
+			return fmt.Errorf("create v3 release: %w", err)
+		}
+		if _, err := storageBackendV2.Delete(relV2.GetName(), relV2.GetVersion()); err != nil {
+			return fmt.Errorf("delete v2 release: %w", err)
+		}
+	}
+	return nil
+}
+
+func getLegacyName(cr *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s-%s", cr.GetName(), shortenUID(cr.GetUID()))
+}
+
+// getReleaseName returns a release name for the CR.
+//
+// When a release name is explicitly requested, that name is used. Otherwise,
+// if a release for the legacy name (`<CR name>-<short UID>`) exists, the legacy
+// name is returned. This ensures backwards-compatibility for pre-existing CRs.
+//
+// If no releases are found with the legacy name, getReleaseName searches for
+// a release using the CR name. If a release cannot be found, or if it is found
+// and was created by the chart managed by this manager, the CR name is
+// returned.
+//
+// If a release is found but it was created by another chart, that means we
+// have a release name collision, so return an error.
+func getReleaseName(storageBackend *storagev3.Storage, crChartName string, cr *unstructured.Unstructured, requested string) (string, error) {
+	releaseName := requested
+	if releaseName == "" {
+		// If a release with the legacy name exists as a v3 release,
+		// return the legacy name.
+		legacyName := getLegacyName(cr)
+		_, legacyExists, err := releaseHistoryV3(storageBackend, legacyName)
+		if err != nil {
+			return "", err
+		}
+		if legacyExists {
+			return legacyName, nil
+		}
+
+		releaseName = cr.GetName()
+	}
+
+	// If a release with this name does not exist, return the name.
+	history, exists, err := releaseHistoryV3(storageBackend, releaseName)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return releaseName, nil
+	}
+
+	// If a release name with the same name exists, but the release's chart is
+	// different than the chart managed by this operator, return an error
+	// because something else created the existing release.
+	if history[0].Chart == nil {
+		return "", fmt.Errorf("could not find chart metadata in release with name %q", releaseName)
+	}
+	existingChartName := history[0].Chart.Name()
+	if existingChartName != crChartName {
+		return "", fmt.Errorf("duplicate release name: found existing release with name %q for chart %q", releaseName, existingChartName)
+	}
+
+	return releaseName, nil
+}
+
+func releaseHistoryV2(storageBackend *storagev2.Storage, releaseName string) ([]*helmreleasev2.Release, bool, error) {
+	releaseHistory, err := storageBackend.History(releaseName)
+	if err != nil {
+		if notFoundErr(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return releaseHistory, len(releaseHistory) > 0, nil
+}
+
+func releaseHistoryV3(storageBackend *storagev3.Storage, releaseName string) ([]*helmreleasev3.Release, bool, error) {
+	releaseHistory, err := storageBackend.History(releaseName)
+	if err != nil {
+		if notFoundErr(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return releaseHistory, len(releaseHistory) > 0, nil
+}
+
+func shortenUID(uid apitypes.UID) string {
+	u, err := uuid.Parse(string(uid))
+	if err != nil {
+		return strings.Replace(string(uid), "-", "", -1)
+	}
+	uidBytes, err := u.MarshalBinary()
+	if err != nil {
+		return strings.Replace(string(uid), "-", "", -1)
+	}
+	return strings.ToLower(base36.EncodeBytes(uidBytes))
+}
+
+func parseOverrides(in map[string]string) (map[string]interface{}, error) {
+	out := make(map[string]interface{})
+	for k, v := range in {
+		val := fmt.Sprintf("%s=%s", k, v)
+		if err := strvals.ParseIntoString(val, out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func mergeMaps(a, b map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(a))
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, v := range b {
+		if v, ok := v.(map[string]interface{}); ok {
+			if bv, ok := out[k]; ok {
+				if bv, ok := bv.(map[string]interface{}); ok {
+					out[k] = mergeMaps(bv, v)
+					continue
+				}
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+package release
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/kube"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// PostRenderer modifies a resource rendered by the chart before it is sent to the cluster
+type PostRenderer interface {
+	PostRender(u *unstructured.Unstructured) error
+}
+
+// PostRendererFunc is a function that implements PostRenderer
+type PostRendererFunc func(u *unstructured.Unstructured) error
+
+// PostRender runs the function
+func (f PostRendererFunc) PostRender(u *unstructured.Unstructured) error {
+	return f(u)
+}
+
+// NewPostRenderingClient returns a client that runs some post-renderers on all the resources
+// built from the manifests, so they are applied when installing, upgrading and reconciling a
+// release. Note that the manifest recorded in the release is the manifest rendered by the chart.
+func NewPostRenderingClient(base kube.Interface, postRenderers ...PostRenderer) kube.Interface {
+	if len(postRenderers) == 0 {
+		return base
+	}
+	return &postRenderingClient{Interface: base, postRenderers: postRenderers}
+}
+
+var _ kube.Interface = &postRenderingClient{}
+
+type postRenderingClient struct {
+	kube.Interface
+	postRenderers []PostRenderer
+}
+
+func (c *postRenderingClient) Build(reader io.Reader, validate bool) (kube.ResourceList, error) {
+	resourceList, err := c.Interface.Build(reader, validate)
+	if err != nil {
+		return resourceList, err
+	}
+	err = resourceList.Visit(func(r *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		objMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(r.Object)
+		if err != nil {
+			return err
+		}
+		u := &unstructured.Unstructured{Object: objMap}
+		for _, p := range c.postRenderers {
+			if err := p.PostRender(u); err != nil {
+				return err
+			}
+		}
+		// post-renderers can replace the whole content of the resource
+		if unstr, ok := r.Object.(runtime.Unstructured); ok {
+			unstr.SetUnstructuredContent(u.Object)
+			return nil
+		}
+		return runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, r.Object)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resourceList, nil
+}
+
+// PostRenderManifest runs some post-renderers on all the resources in a (multi-document)
+// manifest, returning the new manifest. This is used when the manifest is not sent to the
+// cluster (ie, when rendering the chart). Empty documents and comments are dropped.
+func PostRenderManifest(manifest string, postRenderers ...PostRenderer) (string, error) {
+	if len(postRenderers) == 0 {
+		return manifest, nil
+	}
+
+	var out bytes.Buffer
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(strings.NewReader(manifest)))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+
+		u := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal(doc, &u.Object); err != nil {
+			return "", err
+		}
+		if len(u.Object) == 0 {
+			continue
+		}
+		for _, p := range postRenderers {
+			if err := p.PostRender(u); err != nil {
+				return "", err
+			}
+		}
+
+		rendered, err := yaml.Marshal(u.Object)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString("---\n")
+		out.Write(rendered)
+	}
+	return out.String(), nil
+}
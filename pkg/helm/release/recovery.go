@@ -0,0 +1,137 @@
+package release
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// DefaultStalePendingThreshold is the time after which a release in a pending
+// status (ie, `pending-upgrade`) is considered stuck, as the operation that was
+// performing it (ie, an operator that was killed) will never finish it.
+const DefaultStalePendingThreshold = 15 * time.Minute
+
+// RecoveryAction is what has been done with a release stuck in a pending status
+type RecoveryAction string
+
+const (
+	// RecoveryRolledBack means the release has been rolled back to the last deployed release
+	RecoveryRolledBack RecoveryAction = "RolledBack"
+	// RecoveryCleared means the release has been removed (as there was no deployed
+	// release to roll back to), so it can be installed again
+	RecoveryCleared RecoveryAction = "Cleared"
+)
+
+// Recovery describes the recovery of a release stuck in a pending status
+type Recovery struct {
+	// Revision and Status of the release that was stuck
+	Revision int
+	Status   rpb.Status
+	// Since is the time the pending operation was started
+	Since time.Time
+
+	Action RecoveryAction
+	// Release is the release created by the rollback (only for RecoveryRolledBack)
+	Release *rpb.Release
+}
+
+// PendingReleaseError is the error returned by Sync when some operation is in progress
+// on the release (it has been in a pending status for less than the threshold)
+type PendingReleaseError struct {
+	Name     string
+	Revision int
+	Status   rpb.Status
+	Since    time.Time
+}
+
+func (e *PendingReleaseError) Error() string {
+	return fmt.Sprintf("another operation is in progress on release %s (revision %d has been %s since %s)",
+		e.Name, e.Revision, e.Status, e.Since.Format(time.RFC3339))
+}
+
+// isPending returns true when an operation was started on a release but it has not finished
+func isPending(rel *rpb.Release) bool {
+	switch rel.Info.Status {
+	case rpb.StatusPendingInstall, rpb.StatusPendingUpgrade, rpb.StatusPendingRollback:
+		return true
+	}
+	return false
+}
+
+// pendingSince returns the time a pending operation on a release was started
+func pendingSince(rel *rpb.Release) time.Time {
+	if !rel.Info.LastDeployed.IsZero() {
+		return rel.Info.LastDeployed.Time
+	}
+	return rel.Info.FirstDeployed.Time
+}
+
+// recoverPendingReleases looks for releases in a pending status. The releases pending for longer
+// than the threshold are rolled back to the last deployed release (or removed when there is none),
+// while a *PendingReleaseError is returned for any other pending release.
+func (m *manager) recoverPendingReleases(releases []*rpb.Release, now time.Time) (*Recovery, error) {
+	var stale *rpb.Release
+	for _, rel := range releases {
+		if rel.Info == nil || !isPending(rel) {
+			continue
+		}
+		since := pendingSince(rel)
+		if now.Sub(since) < m.stalePendingThreshold {
+			return nil, &PendingReleaseError{Name: rel.Name, Revision: rel.Version, Status: rel.Info.Status, Since: since}
+		}
+		if stale == nil || rel.Version > stale.Version {
+			stale = rel
+		}
+	}
+	if stale == nil {
+		return nil, nil
+	}
+
+	recovery := &Recovery{Revision: stale.Version, Status: stale.Info.Status, Since: pendingSince(stale)}
+
+	deployedRelease, err := m.getDeployedRelease()
+	if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, fmt.Errorf("failed to get deployed release: %w", err)
+	}
+	canRollback := deployedRelease != nil && deployedRelease.Version < stale.Version
+
+	// remove the pending releases (except the one rolled back)
+	for _, rel := range releases {
+		if rel.Info == nil || !isPending(rel) || (canRollback && rel.Version == stale.Version) {
+			continue
+		}
+		if _, err := m.storageBackend.Delete(rel.Name, rel.Version); err != nil && !notFoundErr(err) {
+			return nil, fmt.Errorf("failed to delete stale pending release: %w", err)
+		}
+	}
+	if !canRollback {
+		// nothing to roll back to: the release will be installed again
+		recovery.Action = RecoveryCleared
+		return recovery, nil
+	}
+
+	// mark the stuck release as failed, and revert whatever it applied
+	stale.Info.Status = rpb.StatusFailed
+	stale.Info.Description = fmt.Sprintf("Stuck in %s since %s: rolled back by the operator",
+		recovery.Status, recovery.Since.Format(time.RFC3339))
+	if err := m.storageBackend.Update(stale); err != nil {
+		return nil, fmt.Errorf("failed to update stale pending release: %w", err)
+	}
+
+	rollback := action.NewRollback(m.actionConfig)
+	rollback.Version = deployedRelease.Version
+	if err := rollback.Run(m.releaseName); err != nil {
+		return nil, fmt.Errorf("failed to roll back stale pending release: %w", err)
+	}
+	rolledBackRelease, err := m.storageBackend.Last(m.releaseName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the release after the rollback: %w", err)
+	}
+	recovery.Action = RecoveryRolledBack
+	recovery.Release = rolledBackRelease
+	return recovery, nil
+}
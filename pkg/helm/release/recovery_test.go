@@ -0,0 +1,98 @@
+package release
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	htime "helm.sh/helm/v3/pkg/time"
+)
+
+func newTestManager(releases ...*rpb.Release) *manager {
+	storageBackend := storage.Init(driver.NewMemory())
+	for _, rel := range releases {
+		_ = storageBackend.Create(rel)
+	}
+	kubeClient := &kubefake.PrintingKubeClient{Out: ioutil.Discard}
+	return &manager{
+		actionConfig: &action.Configuration{
+			Releases:   storageBackend,
+			KubeClient: kubeClient,
+			Log:        func(_ string, _ ...interface{}) {},
+		},
+		storageBackend:        storageBackend,
+		kubeClient:            kubeClient,
+		releaseName:           "ambassador",
+		namespace:             "ambassador",
+		stalePendingThreshold: DefaultStalePendingThreshold,
+	}
+}
+
+func newTestRelease(version int, status rpb.Status, started time.Time) *rpb.Release {
+	return &rpb.Release{
+		Name:      "ambassador",
+		Namespace: "ambassador",
+		Version:   version,
+		Chart:     newTestChart("ambassador", nil, ""),
+		Info:      &rpb.Info{Status: status, FirstDeployed: htime.Time{Time: started}, LastDeployed: htime.Time{Time: started}},
+	}
+}
+
+func TestRecoverPendingReleases(t *testing.T) {
+	now := time.Now()
+
+	// nothing to recover
+	m := newTestManager(newTestRelease(1, rpb.StatusDeployed, now.Add(-time.Hour)))
+	releases, _ := m.storageBackend.History(m.releaseName)
+	if recovery, err := m.recoverPendingReleases(releases, now); recovery != nil || err != nil {
+		t.Errorf("unexpected recovery %+v (%v)", recovery, err)
+	}
+
+	// an upgrade in progress is not touched
+	m = newTestManager(
+		newTestRelease(1, rpb.StatusDeployed, now.Add(-time.Hour)),
+		newTestRelease(2, rpb.StatusPendingUpgrade, now.Add(-time.Minute)),
+	)
+	releases, _ = m.storageBackend.History(m.releaseName)
+	_, err := m.recoverPendingReleases(releases, now)
+	var pendingErr *PendingReleaseError
+	if !errors.As(err, &pendingErr) || pendingErr.Revision != 2 {
+		t.Fatalf("expected a PendingReleaseError, got %v", err)
+	}
+	if last, _ := m.storageBackend.Last(m.releaseName); last.Info.Status != rpb.StatusPendingUpgrade {
+		t.Errorf("the pending release was modified: %s", last.Info.Status)
+	}
+
+	// a stale upgrade is rolled back to the deployed release
+	recovery, err := m.recoverPendingReleases(releases, now.Add(DefaultStalePendingThreshold))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if recovery.Action != RecoveryRolledBack || recovery.Revision != 2 || recovery.Status != rpb.StatusPendingUpgrade ||
+		recovery.Release == nil || recovery.Release.Version != 3 {
+		t.Fatalf("unexpected recovery %+v", recovery)
+	}
+	if deployed, err := m.storageBackend.Deployed(m.releaseName); err != nil || deployed.Version != 3 {
+		t.Errorf("unexpected deployed release %+v (%v)", deployed, err)
+	}
+	if stuck, _ := m.storageBackend.Get(m.releaseName, 2); stuck.Info.Status == rpb.StatusPendingUpgrade {
+		t.Errorf("the stale release is still pending")
+	}
+
+	// a stale installation is removed, so it can be installed again
+	m = newTestManager(newTestRelease(1, rpb.StatusPendingInstall, now.Add(-time.Hour)))
+	releases, _ = m.storageBackend.History(m.releaseName)
+	recovery, err = m.recoverPendingReleases(releases, now)
+	if err != nil || recovery.Action != RecoveryCleared || recovery.Revision != 1 {
+		t.Fatalf("unexpected recovery %+v (%v)", recovery, err)
+	}
+	if releases, _ := m.storageBackend.History(m.releaseName); len(releases) != 0 {
+		t.Errorf("the stale release was not removed: %+v", releases)
+	}
+}
@@ -0,0 +1,124 @@
+package release
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+	cpb "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// SchemaViolation is a value that does not match the JSON schema of a chart
+type SchemaViolation struct {
+	// Path is the JSON path of the value (ie, `$.service.ports[0].port`)
+	Path string
+	// Description describes the violation (ie, `Invalid type. Expected: integer, given: string`)
+	Description string
+}
+
+func (v SchemaViolation) String() string {
+	return v.Path + ": " + v.Description
+}
+
+// ValuesSchemaError is the error returned when the values do not match the JSON schemas
+// (`values.schema.json`) of a chart or its dependencies
+type ValuesSchemaError struct {
+	Violations []SchemaViolation
+}
+
+func (e *ValuesSchemaError) Error() string {
+	descriptions := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		descriptions = append(descriptions, v.String())
+	}
+	return "the values do not match the schema of the chart: " + strings.Join(descriptions, "; ")
+}
+
+// ValidateValues validates some values, merged with the default values of the chart, against
+// the JSON schemas (`values.schema.json`) of the chart and its dependencies. It returns a
+// *ValuesSchemaError with all the violations found.
+func ValidateValues(chart *cpb.Chart, values map[string]interface{}) error {
+	merged, err := chartutil.CoalesceValues(chart, values)
+	if err != nil {
+		return fmt.Errorf("could not merge the values with the chart values: %w", err)
+	}
+
+	violations, err := schemaViolations(chart, merged, "$")
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return &ValuesSchemaError{Violations: violations}
+	}
+	return nil
+}
+
+// schemaViolations returns the violations of the schemas in a chart (and in its dependencies,
+// with the values under the name of the dependency), with the paths prefixed with `prefix`
+func schemaViolations(chart *cpb.Chart, values map[string]interface{}, prefix string) ([]SchemaViolation, error) {
+	var violations []SchemaViolation
+	if chart.Schema != nil {
+		valuesJSON, err := json.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+		if string(valuesJSON) == "null" {
+			valuesJSON = []byte("{}")
+		}
+		result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(chart.Schema), gojsonschema.NewBytesLoader(valuesJSON))
+		if err != nil {
+			return nil, fmt.Errorf("could not validate the values against the schema of the chart %s: %w", chart.Name(), err)
+		}
+		for _, e := range result.Errors() {
+			violations = append(violations, SchemaViolation{
+				Path:        prefix + jsonPath(e.Context()),
+				Description: e.Description(),
+			})
+		}
+	}
+
+	for _, dep := range chart.Dependencies() {
+		depValues, _ := values[dep.Name()].(map[string]interface{})
+		depViolations, err := schemaViolations(dep, depValues, prefix+jsonPathElement(dep.Name()))
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, depViolations...)
+	}
+	return violations, nil
+}
+
+// separator used for splitting the context of an error (a string that will not be found in a key)
+const contextSeparator = "\x00"
+
+var (
+	indexRegexp      = regexp.MustCompile(`^[0-9]+$`)
+	identifierRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+)
+
+// jsonPath returns the JSON path (relative to the root) for the context of an error
+func jsonPath(context *gojsonschema.JsonContext) string {
+	if context == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, elem := range strings.Split(context.String(contextSeparator), contextSeparator)[1:] {
+		b.WriteString(jsonPathElement(elem))
+	}
+	return b.String()
+}
+
+// jsonPathElement returns the JSON path element for a key or an index
+func jsonPathElement(elem string) string {
+	switch {
+	case indexRegexp.MatchString(elem):
+		return "[" + elem + "]"
+	case identifierRegexp.MatchString(elem):
+		return "." + elem
+	default:
+		return fmt.Sprintf("[%q]", elem)
+	}
+}
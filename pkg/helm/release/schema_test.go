@@ -0,0 +1,96 @@
+package release
+
+import (
+	"reflect"
+	"testing"
+
+	cpb "helm.sh/helm/v3/pkg/chart"
+)
+
+const testSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "replicaCount": {"type": "integer", "minimum": 1},
+    "service": {
+      "type": "object",
+      "properties": {
+        "ports": {
+          "type": "array",
+          "items": {"type": "object", "properties": {"port": {"type": "integer"}}}
+        }
+      }
+    },
+    "podAnnotations": {
+      "type": "object",
+      "additionalProperties": {"type": "string"}
+    }
+  }
+}`
+
+func newTestChart(name string, values map[string]interface{}, schema string) *cpb.Chart {
+	c := &cpb.Chart{
+		Metadata: &cpb.Metadata{Name: name, Version: "1.0.0"},
+		Values:   values,
+	}
+	if len(schema) > 0 {
+		c.Schema = []byte(schema)
+	}
+	return c
+}
+
+func TestValidateValues(t *testing.T) {
+	chart := newTestChart("ambassador", map[string]interface{}{"replicaCount": 3}, testSchema)
+	sub := newTestChart("redis", map[string]interface{}{"port": 6379}, `{"properties": {"port": {"type": "integer"}}}`)
+	chart.AddDependency(sub)
+
+	if err := ValidateValues(chart, map[string]interface{}{}); err != nil {
+		t.Errorf("unexpected error for the default values: %v", err)
+	}
+
+	err := ValidateValues(chart, map[string]interface{}{
+		"replicaCount": 0,
+		"service": map[string]interface{}{
+			"ports": []interface{}{map[string]interface{}{"port": "http"}},
+		},
+		"podAnnotations": map[string]interface{}{"example.com/weight": 1},
+		"redis":          map[string]interface{}{"port": "6379"},
+	})
+	schemaErr, ok := err.(*ValuesSchemaError)
+	if !ok {
+		t.Fatalf("expected a ValuesSchemaError, got %v", err)
+	}
+
+	paths := map[string]bool{}
+	for _, v := range schemaErr.Violations {
+		paths[v.Path] = true
+	}
+	expected := map[string]bool{
+		"$.replicaCount":          true,
+		"$.service.ports[0].port": true,
+		"$.podAnnotations":        true, // additionalProperties are reported at the parent
+		"$.redis.port":            true,
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("got violations at %v, expected %v", paths, expected)
+	}
+}
+
+func TestValidateValuesWithoutSchema(t *testing.T) {
+	chart := newTestChart("ambassador", map[string]interface{}{"replicaCount": 3}, "")
+	if err := ValidateValues(chart, map[string]interface{}{"replicaCount": "many"}); err != nil {
+		t.Errorf("unexpected error for a chart without schema: %v", err)
+	}
+}
+
+func TestJSONPathElement(t *testing.T) {
+	for elem, expected := range map[string]string{
+		"service":            ".service",
+		"0":                  "[0]",
+		"example.com/weight": `["example.com/weight"]`,
+	} {
+		if res := jsonPathElement(elem); res != expected {
+			t.Errorf("jsonPathElement(%q) = %s, expected %s", elem, res, expected)
+		}
+	}
+}
@@ -39,6 +39,11 @@ func (cv ChartVersionRule) Allowed(s string) (bool, error) {
 	return false, nil
 }
 
+// IsEmpty returns True if the rule has not been initialized (so it would allow any version)
+func (cv ChartVersionRule) IsEmpty() bool {
+	return cv.constraint == nil
+}
+
 func (cv ChartVersionRule) String() string {
 	return cv.s
 }
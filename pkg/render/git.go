@@ -0,0 +1,174 @@
+package render
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DefaultGitBranch is the branch where the manifests are committed by default
+	DefaultGitBranch = "master"
+
+	// the author of the commits
+	gitAuthorName  = "Ambassador Operator"
+	gitAuthorEmail = "ambassador-operator@getambassador.io"
+
+	// the protocols allowed when talking to the repository (see GIT_ALLOW_PROTOCOL in git(1))
+	gitAllowedProtocols = "https:ssh"
+)
+
+// scpURLRegexp matches the scp-like syntax for SSH repositories (ie, `git@github.com:org/repo.git`)
+var scpURLRegexp = regexp.MustCompile(`^([A-Za-z0-9._~-]+@)?[A-Za-z0-9][A-Za-z0-9.-]*:[^:].*$`)
+
+// ValidateGitURL checks that the URL of a repository uses HTTPS or SSH (including the
+// scp-like syntax), so it cannot be taken by git as an option or as a local repository.
+func ValidateGitURL(u string) error {
+	if len(u) == 0 {
+		return fmt.Errorf("no URL provided for the Git repository")
+	}
+	if strings.HasPrefix(u, "-") {
+		return fmt.Errorf("the URL of the Git repository cannot start with '-'")
+	}
+	if !strings.Contains(u, "://") {
+		if scpURLRegexp.MatchString(u) {
+			return nil
+		}
+		return fmt.Errorf("the URL of the Git repository must be an https, ssh or scp-like URL")
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return fmt.Errorf("invalid URL for the Git repository: %w", err)
+	}
+	if parsed.Scheme != "https" && parsed.Scheme != "ssh" {
+		return fmt.Errorf("unsupported scheme %q for the Git repository: only https and ssh are supported", parsed.Scheme)
+	}
+	if len(parsed.Host) == 0 || strings.HasPrefix(parsed.Host, "-") {
+		return fmt.Errorf("invalid host in the URL of the Git repository")
+	}
+	return nil
+}
+
+// GitOptions are the options for a Git sink
+type GitOptions struct {
+	// URL is the URL of the repository
+	URL string
+	// Branch is the branch where the manifests are committed (DefaultGitBranch when empty)
+	Branch string
+	// Path is the directory in the repository where the manifests are written
+	Path string
+}
+
+// NewGitSink returns a sink that commits the manifests to a Git repository and pushes
+// them. It uses the `git` CLI, so the usual Git configuration (ie, credentials helpers or
+// SSH keys) is honored.
+func NewGitSink(options GitOptions) (Sink, error) {
+	if err := ValidateGitURL(options.URL); err != nil {
+		return nil, err
+	}
+	if len(options.Branch) == 0 {
+		options.Branch = DefaultGitBranch
+	}
+	if filepath.IsAbs(options.Path) || strings.HasPrefix(filepath.Clean(options.Path), "..") {
+		return nil, fmt.Errorf("the path %q must be relative to the root of the Git repository", options.Path)
+	}
+	return gitSink{options: options, allowedProtocols: gitAllowedProtocols}, nil
+}
+
+type gitSink struct {
+	options GitOptions
+	// the value for GIT_ALLOW_PROTOCOL
+	allowedProtocols string
+}
+
+// Write commits the manifest in the branch (creating the branch when it does not exist)
+// and pushes it. Nothing is committed when the manifest has not changed.
+func (s gitSink) Write(ctx context.Context, file string, manifest []byte, message string) (string, error) {
+	dir, err := ioutil.TempDir("", "amb-render-")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	git := func(args ...string) (string, error) {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ALLOW_PROTOCOL="+s.allowedProtocols)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, redactURLs(strings.TrimSpace(string(out)), s.options.URL))
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	if _, err := git("init", "-q"); err != nil {
+		return "", err
+	}
+	branchRef := "refs/heads/" + s.options.Branch
+	heads, err := git("ls-remote", "--heads", "--", s.options.URL, branchRef)
+	if err != nil {
+		return "", err
+	}
+	if len(heads) > 0 {
+		if _, err := git("fetch", "-q", "--depth=1", "--", s.options.URL, branchRef); err != nil {
+			return "", err
+		}
+		if _, err := git("checkout", "-q", "-B", s.options.Branch, "FETCH_HEAD"); err != nil {
+			return "", err
+		}
+	} else if _, err := git("symbolic-ref", "HEAD", branchRef); err != nil {
+		// the branch does not exist yet: the first commit will create it
+		return "", err
+	}
+
+	name := filepath.Join(s.options.Path, file)
+	if err := writeFileAtomic(filepath.Join(dir, name), manifest); err != nil {
+		return "", err
+	}
+	if _, err := git("add", "--", name); err != nil {
+		return "", err
+	}
+	changes, err := git("status", "--porcelain")
+	if err != nil {
+		return "", err
+	}
+	if len(changes) > 0 {
+		if _, err := git("-c", "user.name="+gitAuthorName, "-c", "user.email="+gitAuthorEmail,
+			"commit", "-q", "-m", message); err != nil {
+			return "", err
+		}
+		if _, err := git("push", "-q", "--", s.options.URL, "HEAD:"+branchRef); err != nil {
+			return "", err
+		}
+	}
+
+	commit, err := git("rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("git:%s#%s:%s@%s", redactURL(s.options.URL), s.options.Branch, filepath.ToSlash(name), commit), nil
+}
+
+// redactURL removes the credentials (if any) from a URL
+func redactURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.User == nil {
+		return u
+	}
+	parsed.User = nil
+	return parsed.String()
+}
+
+// redactURLs removes the credentials of a URL from some text (ie, the output of a command)
+func redactURLs(text string, u string) string {
+	if redacted := redactURL(u); redacted != u {
+		return strings.Replace(text, u, redacted, -1)
+	}
+	return text
+}
@@ -0,0 +1,62 @@
+// Package render implements the destinations where the manifests rendered by the operator
+// (in the `render` mode) are written: a directory (ie, a volume mounted in the operator) or
+// a Git repository, so they can be applied by some other tool (ie, a GitOps controller).
+package render
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Sink is a destination for the rendered manifests
+type Sink interface {
+	// Write writes the manifest to a file (a relative path, ie, `<namespace>/<release>.yaml`),
+	// returning the location where it has been written. The message describes the change.
+	Write(ctx context.Context, file string, manifest []byte, message string) (string, error)
+}
+
+// NewDirectorySink returns a sink that writes the manifests in a directory
+func NewDirectorySink(dir string) Sink {
+	return directorySink{dir: dir}
+}
+
+type directorySink struct {
+	dir string
+}
+
+func (s directorySink) Write(_ context.Context, file string, manifest []byte, _ string) (string, error) {
+	path := filepath.Join(s.dir, file)
+	if err := writeFileAtomic(path, manifest); err != nil {
+		return "", err
+	}
+	return "file:" + path, nil
+}
+
+// writeFileAtomic writes a file (creating the parent directories), replacing any previous
+// content atomically, so readers never see a partial manifest
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("could not create directory %q: %w", dir, err)
+	}
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,186 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/datawire/ambassador-operator/version"
+)
+
+var log = logf.Log.WithName("tracing")
+
+const (
+	// path where traces are sent to in an OTLP/HTTP endpoint
+	otlpTracesPath = "/v1/traces"
+
+	// timeout for exporting a trace
+	exportTimeout = 10 * time.Second
+
+	// the kind of all the spans (SPAN_KIND_INTERNAL)
+	otlpSpanKindInternal = 1
+)
+
+// OTLPExporter sends the spans to an OTLP/HTTP endpoint (ie, an OpenTelemetry
+// collector), using the JSON encoding
+type OTLPExporter struct {
+	// The OTLP/HTTP endpoint (ie, `http://otel-collector:4318`). The spans are sent to `/v1/traces`.
+	Endpoint string
+	// An (optional) HTTP client.
+	Client *http.Client
+}
+
+// Export sends the spans to the OTLP/HTTP endpoint
+func (o *OTLPExporter) Export(ctx context.Context, spans []SpanData) error {
+	otlpSpans := make([]interface{}, 0, len(spans))
+	for _, s := range spans {
+		span := map[string]interface{}{
+			"traceId":           s.TraceID.String(),
+			"spanId":            s.SpanID.String(),
+			"name":              s.Name,
+			"kind":              otlpSpanKindInternal,
+			"startTimeUnixNano": strconv.FormatInt(s.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.End.UnixNano(), 10),
+			"attributes":        OTLPAttributes(s.Attributes),
+			"status":            map[string]interface{}{"code": int(s.Status), "message": s.StatusMessage},
+		}
+		if s.ParentID != (SpanID{}) {
+			span["parentSpanId"] = s.ParentID.String()
+		}
+		otlpSpans = append(otlpSpans, span)
+	}
+
+	traces := map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []interface{}{
+						OTLPAttribute("service.name", serviceName),
+						OTLPAttribute("service.version", version.Version),
+					},
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": serviceName},
+						"spans": otlpSpans,
+					},
+				},
+			},
+		},
+	}
+
+	data, err := json.Marshal(traces)
+	if err != nil {
+		return err
+	}
+
+	client := o.Client
+	if client == nil {
+		client = &http.Client{Timeout: exportTimeout}
+	}
+	url := strings.TrimSuffix(o.Endpoint, "/") + otlpTracesPath
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response from %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// JSONExporter writes the spans to a writer (ie, os.Stdout), as JSON (one span per line)
+type JSONExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONExporter creates a new exporter that writes the spans in a writer
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{w: w}
+}
+
+// Export writes the spans as JSON
+func (j *JSONExporter) Export(_ context.Context, spans []SpanData) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, s := range spans {
+		data, err := json.Marshal(map[string]interface{}{
+			"traceID":    s.TraceID.String(),
+			"spanID":     s.SpanID.String(),
+			"parentID":   s.ParentID.String(),
+			"name":       s.Name,
+			"start":      s.Start,
+			"duration":   s.End.Sub(s.Start).String(),
+			"attributes": s.Attributes,
+			"error":      s.StatusMessage,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := j.w.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OTLPAttributes returns the OTLP key/value attributes for some attributes, sorted by key
+func OTLPAttributes(attributes map[string]interface{}) []interface{} {
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	res := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, OTLPAttribute(k, attributes[k]))
+	}
+	return res
+}
+
+// OTLPAttribute returns an OTLP key/value attribute
+func OTLPAttribute(key string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{"key": key, "value": OTLPValue(value)}
+}
+
+// OTLPValue returns an OTLP AnyValue for some value
+func OTLPValue(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case string:
+		return map[string]interface{}{"stringValue": v}
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case int:
+		// note: 64 bits integers are encoded as strings in the JSON encoding
+		return map[string]interface{}{"intValue": strconv.Itoa(v)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": v}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprintf("%v", v)}
+	}
+}
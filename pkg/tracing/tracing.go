@@ -0,0 +1,305 @@
+// Package tracing implements a minimal tracer for the operator. Spans are collected
+// per trace, and exported when the root span of the trace ends (ie, when a reconciliation
+// finishes), to an OTLP/HTTP endpoint (ie, an OpenTelemetry collector) or to the standard output.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ExporterEnvVar is the environment variable with the exporter used for the spans:
+	// `otlp`, `stdout` or `none` (the default).
+	ExporterEnvVar = "AMB_TRACING_EXPORTER"
+
+	// OTLPEndpointEnvVar is the environment variable with the OTLP/HTTP endpoint
+	// (ie, `http://otel-collector:4318`) used by the `otlp` exporter
+	OTLPEndpointEnvVar = "AMB_TRACING_OTLP_ENDPOINT"
+)
+
+// the exporters available
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// the name of the service in the traces
+const serviceName = "ambassador-operator"
+
+// TraceID is the ID of a trace
+type TraceID [16]byte
+
+// String returns the hex representation of the ID
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// SpanID is the ID of a span
+type SpanID [8]byte
+
+// String returns the hex representation of the ID
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// StatusCode is the status of a span
+type StatusCode int
+
+// the status codes, with the same values as in OTLP
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// SpanData is the data of a span that has ended, as sent to the exporters
+type SpanData struct {
+	TraceID       TraceID
+	SpanID        SpanID
+	ParentID      SpanID
+	Name          string
+	Start         time.Time
+	End           time.Time
+	Attributes    map[string]interface{}
+	Status        StatusCode
+	StatusMessage string
+}
+
+// Exporter sends the spans of a trace somewhere
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+// Tracer creates spans and exports them when the traces are done
+type Tracer struct {
+	exporter Exporter
+
+	mu      sync.Mutex
+	pending map[TraceID][]SpanData
+	wg      sync.WaitGroup
+}
+
+// NewTracer creates a new tracer that exports the spans with an exporter
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter, pending: map[TraceID][]SpanData{}}
+}
+
+// Flush waits until all the traces finished have been exported
+func (t *Tracer) Flush() {
+	if t != nil {
+		t.wg.Wait()
+	}
+}
+
+// the global tracer: nil when tracing is disabled
+var (
+	globalTracer      *Tracer
+	globalTracerMutex sync.RWMutex
+)
+
+// SetTracer sets the tracer used by Start (nil for disabling tracing)
+func SetTracer(t *Tracer) {
+	globalTracerMutex.Lock()
+	defer globalTracerMutex.Unlock()
+	globalTracer = t
+}
+
+// GetTracer returns the tracer used by Start
+func GetTracer() *Tracer {
+	globalTracerMutex.RLock()
+	defer globalTracerMutex.RUnlock()
+	return globalTracer
+}
+
+// SetupFromEnv sets the global tracer with the exporter configured in the environment,
+// returning the tracer (or nil when tracing is disabled)
+func SetupFromEnv() (*Tracer, error) {
+	var exporter Exporter
+	switch e := strings.TrimSpace(os.Getenv(ExporterEnvVar)); e {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		exporter = NewJSONExporter(os.Stdout)
+	case ExporterOTLP:
+		endpoint := os.Getenv(OTLPEndpointEnvVar)
+		if len(endpoint) == 0 {
+			return nil, fmt.Errorf("no endpoint provided in %s for the %q exporter", OTLPEndpointEnvVar, e)
+		}
+		exporter = &OTLPExporter{Endpoint: endpoint}
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q in %s", e, ExporterEnvVar)
+	}
+
+	t := NewTracer(exporter)
+	SetTracer(t)
+	return t, nil
+}
+
+// Span is an operation being traced. A nil Span (returned when tracing is
+// disabled) can be used safely: all its methods do nothing.
+type Span struct {
+	tracer *Tracer
+	root   *Span
+
+	mu   sync.Mutex
+	data SpanData
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns the current span in a context (or nil)
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// RootSpanFromContext returns the root span of the trace in a context (or nil)
+func RootSpanFromContext(ctx context.Context) *Span {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.root
+	}
+	return nil
+}
+
+// Start starts a new span with the global tracer, as a child of the span in
+// the context (if any). The span must be ended with End().
+func Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	t := GetTracer()
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		tracer: t,
+		data: SpanData{
+			SpanID:     newSpanID(),
+			Name:       name,
+			Start:      time.Now(),
+			Attributes: map[string]interface{}{},
+		},
+	}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.data.TraceID = parent.data.TraceID
+		span.data.ParentID = parent.data.SpanID
+		span.root = parent.root
+	} else {
+		span.data.TraceID = newTraceID()
+		span.root = span
+	}
+	span.SetAttributes(attributes...)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// TraceID returns the ID of the trace of this span (ie, for correlating it with the logs)
+func (s *Span) TraceID() (TraceID, bool) {
+	if s == nil {
+		return TraceID{}, false
+	}
+	return s.data.TraceID, true
+}
+
+// Attribute is a key/value pair in a span
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute
+func String(key, value string) Attribute { return Attribute{Key: key, Value: value} }
+
+// Bool returns a boolean attribute
+func Bool(key string, value bool) Attribute { return Attribute{Key: key, Value: value} }
+
+// Int returns an integer attribute
+func Int(key string, value int) Attribute { return Attribute{Key: key, Value: value} }
+
+// SetAttributes sets some attributes in the span
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range attributes {
+		s.data.Attributes[a.Key] = a.Value
+	}
+}
+
+// RecordError marks the span as failed with an error. It does nothing for a nil error.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Status = StatusError
+	s.data.StatusMessage = err.Error()
+}
+
+// End ends the span. When this is the root span of the trace, all the spans
+// of the trace are exported (in the background: see Tracer.Flush).
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	s.tracer.finish(data, s.root)
+}
+
+// ended returns true if the span has ended
+func (s *Span) ended() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.data.End.IsZero()
+}
+
+// finish records a span that has ended, exporting the trace when it is the root span.
+// Spans that end after the root span of their trace are exported on their own.
+func (t *Tracer) finish(data SpanData, root *Span) {
+	t.mu.Lock()
+	var spans []SpanData
+	switch {
+	case data.ParentID == (SpanID{}):
+		spans = append(t.pending[data.TraceID], data)
+		delete(t.pending, data.TraceID)
+	case root != nil && root.ended():
+		spans = []SpanData{data}
+	default:
+		t.pending[data.TraceID] = append(t.pending[data.TraceID], data)
+		t.mu.Unlock()
+		return
+	}
+	t.mu.Unlock()
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		if err := t.exporter.Export(context.Background(), spans); err != nil {
+			log.Info("Could not export trace", "traceID", data.TraceID.String(), "error", err.Error())
+		}
+	}()
+}
+
+func newTraceID() (id TraceID) {
+	_, _ = rand.Read(id[:])
+	return
+}
+
+func newSpanID() (id SpanID) {
+	_, _ = rand.Read(id[:])
+	return
+}
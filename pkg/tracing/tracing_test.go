@@ -0,0 +1,293 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// testCollector is a local stand-in for an OpenTelemetry collector, recording the traces received
+type testCollector struct {
+	*httptest.Server
+	mu     sync.Mutex
+	paths  []string
+	bodies []string
+}
+
+func newTestCollector(t *testing.T) *testCollector {
+	c := &testCollector{}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := &bytes.Buffer{}
+		if _, err := buf.ReadFrom(r.Body); err != nil {
+			t.Errorf("could not read request: %v", err)
+		}
+		c.mu.Lock()
+		c.paths = append(c.paths, r.URL.Path)
+		c.bodies = append(c.bodies, buf.String())
+		c.mu.Unlock()
+		_, _ = w.Write([]byte("{}"))
+	}))
+	return c
+}
+
+func TestDisabledTracing(t *testing.T) {
+	SetTracer(nil)
+
+	ctx, span := Start(context.Background(), "Reconcile", String("installation.name", "ambassador"))
+	if span != nil {
+		t.Fatalf("expected a nil span when tracing is disabled")
+	}
+	if _, ok := span.TraceID(); ok {
+		t.Errorf("a nil span must not have a trace ID")
+	}
+	// all these must be safe with a nil span
+	span.SetAttributes(Bool("deleted", false))
+	span.RecordError(errors.New("failed"))
+	span.End()
+	if SpanFromContext(ctx) != nil || RootSpanFromContext(ctx) != nil {
+		t.Errorf("unexpected span in the context")
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	collector := newTestCollector(t)
+	defer collector.Close()
+
+	tracer := NewTracer(&OTLPExporter{Endpoint: collector.URL + "/"})
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	ctx, root := Start(context.Background(), "Reconcile", String("installation.name", "ambassador"))
+	childCtx, child := Start(ctx, "download")
+	RootSpanFromContext(childCtx).SetAttributes(String("chart.version", "6.4.0"))
+	child.RecordError(errors.New("download failed"))
+	child.End()
+
+	// nothing is exported until the root span ends
+	tracer.Flush()
+	if len(collector.paths) != 0 {
+		t.Fatalf("trace exported before the root span ended")
+	}
+	root.End()
+	tracer.Flush()
+
+	if strings.Join(collector.paths, ",") != otlpTracesPath {
+		t.Fatalf("unexpected requests to %v", collector.paths)
+	}
+	traceID, _ := root.TraceID()
+	body := collector.bodies[0]
+	for _, expected := range []string{
+		`"name":"Reconcile"`,
+		`"name":"download"`,
+		`"traceId":"` + traceID.String() + `"`,
+		`"parentSpanId":"` + root.data.SpanID.String() + `"`,
+		`{"key":"installation.name","value":{"stringValue":"ambassador"}}`,
+		`{"key":"chart.version","value":{"stringValue":"6.4.0"}}`,
+		`"status":{"code":2,"message":"download failed"}`,
+		`{"key":"service.name","value":{"stringValue":"ambassador-operator"}}`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("trace does not contain %s: %s", expected, body)
+		}
+	}
+	if strings.Count(body, `"parentSpanId"`) != 1 {
+		t.Errorf("only the child span should have a parent: %s", body)
+	}
+}
+
+// otlpIDsToBase64 replaces the hex trace and span IDs by base64 strings: the OTLP/JSON
+// encoding uses hex for IDs, while the standard protobuf JSON mapping uses base64
+func otlpIDsToBase64(t *testing.T, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if s, ok := e.(string); ok && (k == "traceId" || k == "spanId" || k == "parentSpanId") {
+				id, err := hex.DecodeString(s)
+				if err != nil {
+					t.Fatalf("%s is not a hex string: %q", k, s)
+				}
+				v[k] = base64.StdEncoding.EncodeToString(id)
+				continue
+			}
+			otlpIDsToBase64(t, e)
+		}
+	case []interface{}:
+		for _, e := range v {
+			otlpIDsToBase64(t, e)
+		}
+	}
+}
+
+func TestOTLPExporterWireFormat(t *testing.T) {
+	collector := newTestCollector(t)
+	defer collector.Close()
+
+	tracer := NewTracer(&OTLPExporter{Endpoint: collector.URL})
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	ctx, root := Start(context.Background(), "Reconcile", String("installation.name", "ambassador"),
+		Int("bytes", 1024), Bool("installation.deleted", false))
+	_, child := Start(ctx, "download")
+	child.RecordError(errors.New("download failed"))
+	child.End()
+	root.End()
+	tracer.Flush()
+
+	if len(collector.bodies) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(collector.bodies))
+	}
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(collector.bodies[0]), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	otlpIDsToBase64(t, decoded)
+	data, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// unknown fields and wrong types are rejected by the official OTLP types
+	request := &coltracepb.ExportTraceServiceRequest{}
+	if err := protojson.Unmarshal(data, request); err != nil {
+		t.Fatalf("the request does not match the OTLP schema: %v\n%s", err, collector.bodies[0])
+	}
+
+	if len(request.ResourceSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request %v", request)
+	}
+	spans := request.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %v", spans)
+	}
+	traceID, _ := root.TraceID()
+	for _, span := range spans {
+		if !bytes.Equal(span.TraceId, traceID[:]) || span.Kind != tracepb.Span_SPAN_KIND_INTERNAL ||
+			span.StartTimeUnixNano == 0 || span.EndTimeUnixNano < span.StartTimeUnixNano {
+			t.Errorf("unexpected span %v", span)
+		}
+		switch span.Name {
+		case "Reconcile":
+			// the attributes are sorted by key
+			attributes := span.Attributes
+			if len(attributes) != 3 ||
+				attributes[0].Key != "bytes" || attributes[0].Value.GetIntValue() != 1024 ||
+				attributes[1].Key != "installation.deleted" || attributes[1].Value.GetValue() == nil ||
+				attributes[2].Key != "installation.name" || attributes[2].Value.GetStringValue() != "ambassador" {
+				t.Errorf("unexpected attributes %v", attributes)
+			}
+		case "download":
+			if !bytes.Equal(span.ParentSpanId, root.data.SpanID[:]) || span.Status.Code != tracepb.Status_STATUS_CODE_ERROR {
+				t.Errorf("unexpected span %v", span)
+			}
+		}
+	}
+}
+
+func TestJSONExporter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	tracer := NewTracer(NewJSONExporter(buf))
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	ctx, root := Start(context.Background(), "Reconcile")
+	_, child := Start(ctx, "sync", Int("attempt", 1))
+	child.End()
+	root.End()
+	tracer.Flush()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 spans, got %q", buf.String())
+	}
+	span := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[0]), &span); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	traceID, _ := root.TraceID()
+	if span["name"] != "sync" || span["traceID"] != traceID.String() {
+		t.Errorf("unexpected span: %v", span)
+	}
+}
+
+func TestLateSpan(t *testing.T) {
+	buf := &bytes.Buffer{}
+	tracer := NewTracer(NewJSONExporter(buf))
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	ctx, root := Start(context.Background(), "Reconcile")
+	_, child := Start(ctx, "verify")
+	root.End()
+	tracer.Flush()
+	// the child span ends after the trace has been exported
+	child.End()
+	tracer.Flush()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], `"name":"verify"`) {
+		t.Fatalf("the late span was not exported: %q", buf.String())
+	}
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	if len(tracer.pending) != 0 {
+		t.Errorf("spans pending after the trace has been exported: %v", tracer.pending)
+	}
+}
+
+func TestSetupFromEnv(t *testing.T) {
+	setEnv := func(env map[string]string) {
+		_ = os.Unsetenv(ExporterEnvVar)
+		_ = os.Unsetenv(OTLPEndpointEnvVar)
+		for k, v := range env {
+			_ = os.Setenv(k, v)
+		}
+	}
+	defer setEnv(nil)
+	defer SetTracer(nil)
+
+	tests := []struct {
+		env     map[string]string
+		enabled bool
+		wantErr bool
+	}{
+		{env: map[string]string{}},
+		{env: map[string]string{ExporterEnvVar: "none"}},
+		{env: map[string]string{ExporterEnvVar: "stdout"}, enabled: true},
+		{env: map[string]string{ExporterEnvVar: "otlp", OTLPEndpointEnvVar: "http://localhost:4318"}, enabled: true},
+		{env: map[string]string{ExporterEnvVar: "otlp"}, wantErr: true},
+		{env: map[string]string{ExporterEnvVar: "zipkin"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		SetTracer(nil)
+		setEnv(test.env)
+		tracer, err := SetupFromEnv()
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%v: expected error", test.env)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.env, err)
+			continue
+		}
+		if (tracer != nil) != test.enabled || (GetTracer() != nil) != test.enabled {
+			t.Errorf("%v: expected enabled=%v", test.env, test.enabled)
+		}
+	}
+}